@@ -0,0 +1,134 @@
+// Package calendar counts working days, skipping weekends and public holidays.
+package calendar
+
+import (
+	"sync"
+	"time"
+)
+
+// dateLayout identifies a calendar day regardless of the time of day
+const dateLayout = "2006-01-02"
+
+// Calendar knows the holidays on which no work is done. Saturdays and Sundays are never working days.
+// Days start at midnight in the calendar's location, whatever zone the times passed in are in. A nil
+// Calendar only skips weekends, in UTC.
+type Calendar struct {
+	location *time.Location
+	holidays map[string]bool
+}
+
+// New creates a calendar for the given location with the given holidays; only their dates matter. A nil
+// location means UTC.
+func New(location *time.Location, holidays []time.Time) *Calendar {
+	if location == nil {
+		location = time.UTC
+	}
+	c := &Calendar{location: location, holidays: make(map[string]bool, len(holidays))}
+	for _, holiday := range holidays {
+		c.holidays[holiday.Format(dateLayout)] = true
+	}
+	return c
+}
+
+// in returns t in the calendar's location
+func (c *Calendar) in(t time.Time) time.Time {
+	if c == nil {
+		return t.UTC()
+	}
+	return t.In(c.location)
+}
+
+// IsWorkingDay reports whether t falls on a weekday that is not a holiday
+func (c *Calendar) IsWorkingDay(t time.Time) bool {
+	t = c.in(t)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return c == nil || !c.holidays[t.Format(dateLayout)]
+}
+
+// AddWorkingDays returns the time the given number of working days after t, at the same time of day, in
+// the calendar's location. A start on a day off counts from the start of the next working day.
+func (c *Calendar) AddWorkingDays(t time.Time, days int) time.Time {
+	t = c.in(t)
+	for !c.IsWorkingDay(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	}
+	for days > 0 {
+		t = t.AddDate(0, 0, 1)
+		if c.IsWorkingDay(t) {
+			days--
+		}
+	}
+	return t
+}
+
+// WorkingDaysBetween counts the working days after from's day up to and including to's day; it is
+// negative when to comes before from. For t on a working day, WorkingDaysBetween(t, AddWorkingDays(t, n)) is n.
+func (c *Calendar) WorkingDaysBetween(from, to time.Time) int {
+	from, to = c.in(from), c.in(to)
+	sign := 1
+	if to.Before(from) {
+		from, to = to, from
+		sign = -1
+	}
+
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	last := to.Format(dateLayout)
+	count := 0
+	for day.Format(dateLayout) < last {
+		day = day.AddDate(0, 0, 1)
+		if c.IsWorkingDay(day) {
+			count++
+		}
+	}
+	return sign * count
+}
+
+// Store provides the holiday dates. Repository implements it on PostgreSQL.
+type Store interface {
+	GetHolidayDates() ([]time.Time, error)
+}
+
+// maxAge bounds how long a loaded calendar is used, so holidays changed on another replica show up
+const maxAge = 10 * time.Minute
+
+// Provider loads the calendar from a Store and keeps it until it is invalidated or gets too old
+type Provider struct {
+	store    Store
+	location *time.Location
+
+	mu       sync.Mutex
+	current  *Calendar
+	loadedAt time.Time
+}
+
+// NewProvider creates a provider of calendars that count days in location
+func NewProvider(store Store, location *time.Location) *Provider {
+	return &Provider{store: store, location: location}
+}
+
+// Calendar returns the current calendar, loading it when needed
+func (p *Provider) Calendar() (*Calendar, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current != nil && time.Since(p.loadedAt) < maxAge {
+		return p.current, nil
+	}
+
+	holidays, err := p.store.GetHolidayDates()
+	if err != nil {
+		return nil, err
+	}
+	p.current, p.loadedAt = New(p.location, holidays), time.Now()
+	return p.current, nil
+}
+
+// Invalidate makes the next Calendar call reload the holidays; call it after changing them
+func (p *Provider) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.current = nil
+}
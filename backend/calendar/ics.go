@@ -0,0 +1,149 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"web-work-request-backend/models"
+)
+
+// maxEventDays bounds how many days a single event may cover, so a broken DTEND cannot flood the table
+const maxEventDays = 31
+
+// ErrNoHolidays is returned when an ICS file contains no usable events
+var ErrNoHolidays = errors.New("the calendar file contains no events")
+
+// ParseICS reads the all-day events of an iCalendar file, such as a published list of Indonesian public
+// holidays, as holidays. Events spanning several days give one holiday per day; cancelled events are
+// skipped and recurrence rules are not expanded. When two events fall on the same day the first one wins.
+func ParseICS(r io.Reader) ([]models.Holiday, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := make(map[string]models.Holiday)
+	var event map[string]string
+	for n, line := range lines {
+		switch {
+		case line == "BEGIN:VEVENT":
+			event = make(map[string]string)
+		case line == "END:VEVENT":
+			if event == nil {
+				continue
+			}
+			days, err := eventDays(event)
+			if err != nil {
+				return nil, fmt.Errorf("event ending on line %d: %w", n+1, err)
+			}
+			for _, day := range days {
+				key := day.Format(dateLayout)
+				if _, exists := byDate[key]; !exists {
+					byDate[key] = models.Holiday{Date: day, Name: event["SUMMARY"]}
+				}
+			}
+			event = nil
+		case event != nil:
+			name, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			// Parameters such as ;VALUE=DATE or ;LANGUAGE=id follow the property name and are not needed
+			name, _, _ = strings.Cut(name, ";")
+			name = strings.ToUpper(name)
+			if name == "SUMMARY" {
+				value = unescapeText(value)
+			}
+			event[name] = value
+		}
+	}
+
+	if len(byDate) == 0 {
+		return nil, ErrNoHolidays
+	}
+
+	holidays := make([]models.Holiday, 0, len(byDate))
+	for _, holiday := range byDate {
+		holidays = append(holidays, holiday)
+	}
+	sort.Slice(holidays, func(i, j int) bool {
+		return holidays[i].Date.Before(holidays[j].Date)
+	})
+	return holidays, nil
+}
+
+// unfoldLines splits the file into content lines, joining folded continuation lines
+func unfoldLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// eventDays returns the days an event covers. An all-day DTEND is exclusive; a DTEND with a time
+// includes its day unless it is midnight.
+func eventDays(event map[string]string) ([]time.Time, error) {
+	if strings.EqualFold(event["STATUS"], "CANCELLED") {
+		return nil, nil
+	}
+
+	start, _, err := parseEventDate(event["DTSTART"])
+	if err != nil {
+		return nil, fmt.Errorf("DTSTART: %w", err)
+	}
+	if event["SUMMARY"] == "" {
+		return nil, errors.New("SUMMARY is missing")
+	}
+
+	end := start.AddDate(0, 0, 1)
+	if value, ok := event["DTEND"]; ok {
+		endDate, inclusive, err := parseEventDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("DTEND: %w", err)
+		}
+		if inclusive {
+			endDate = endDate.AddDate(0, 0, 1)
+		}
+		if endDate.After(start) {
+			end = endDate
+		}
+	}
+
+	var days []time.Time
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if len(days) == maxEventDays {
+			return nil, fmt.Errorf("the event is longer than %d days", maxEventDays)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// parseEventDate reads the day of a DATE (20260101) or DATE-TIME (20260101T090000Z) value and reports
+// whether it has a time other than midnight
+func parseEventDate(value string) (time.Time, bool, error) {
+	datePart, timePart, _ := strings.Cut(value, "T")
+	day, err := time.Parse("20060102", datePart)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+	}
+	timePart = strings.TrimSuffix(timePart, "Z")
+	return day, timePart != "" && timePart != "000000", nil
+}
+
+// unescapeText decodes the backslash escapes of iCalendar text values
+func unescapeText(value string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ")
+	return strings.TrimSpace(replacer.Replace(value))
+}
@@ -1,12 +1,25 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// envFile is read for settings that are not set in the environment
+const envFile = "config.env"
+
+// Sources a setting can come from, in order of precedence
+const (
+	SourceEnvironment = "environment"
+	SourceFile        = envFile
+	SourceDefault     = "default"
+)
+
 type Config struct {
 	DBHost     string
 	DBPort     int
@@ -16,37 +29,264 @@ type Config struct {
 	DBSSLMode  string
 	JWTSecret  string
 	JWTExpiry  string
-	ServerHost string
-	ServerPort string
-	ServerMode string
-	CORS       string
+	// JWTKeyID names the key that signs new tokens; with JWTKeysDir it is a file name without .pem
+	JWTKeyID string
+	// JWTKeysDir holds <kid>.pem RSA or Ed25519 keys; when empty, tokens are signed with JWTSecret (HS256)
+	JWTKeysDir string
+	// RefreshTokenExpiry is how long a refresh token can be used to obtain new access tokens
+	RefreshTokenExpiry string
+	ServerHost         string
+	ServerPort         string
+	ServerMode         string
+	CORS               string
+	// CORSAllowedOrigins are the frontend origins allowed to call the API with credentials
+	CORSAllowedOrigins []string
+	// TrustedProxies are the proxy addresses or CIDRs whose X-Forwarded-For and X-Forwarded-Proto headers
+	// are used for the client IP and scheme
+	TrustedProxies []string
+	// LoginMaxFailures locks an account after this many failed logins; LoginLockoutDuration is how long
+	LoginMaxFailures     int
+	LoginLockoutDuration string
+	// Password policy: minimum length, required character classes (lower, upper, digit, symbol)
+	// and how many previous passwords cannot be reused
+	PasswordMinLength       int
+	PasswordRequiredClasses []string
+	PasswordHistory         int
+	// PasswordResetExpiry is how long an operator-issued reset token stays valid
+	PasswordResetExpiry string
+	// TOTPIssuer is the account issuer shown in authenticator apps
+	TOTPIssuer string
+	// TOTPRequiredRoles must complete TOTP 2FA at login; other roles may enable it
+	TOTPRequiredRoles []string
+	// SelfRegistration allows anyone to sign up; new accounts wait for an operator to approve them
+	SelfRegistration bool
+	// AuthProviders are tried in order at login: local (bcrypt passwords) and ldap
+	AuthProviders []string
+	// LDAP directory: LDAPURL is ldap://host:389 or ldaps://host:636; users are found under LDAPBaseDN
+	// with LDAPUserFilter, where %s is replaced by the escaped username
+	LDAPURL          string
+	LDAPStartTLS     bool
+	LDAPBindDN       string
+	LDAPBindPassword string
+	LDAPBaseDN       string
+	LDAPUserFilter   string
+	// Attributes read from the user entry
+	LDAPUsernameAttribute   string
+	LDAPNameAttribute       string
+	LDAPEmailAttribute      string
+	LDAPDepartmentAttribute string
+	LDAPGroupAttribute      string
+	// LDAPGroupRoles maps groups to roles as role:group pairs separated by ";", for example
+	// "operator:CN=IT Support,OU=Groups,DC=corp,DC=local"; the first matching pair wins and
+	// users in no mapped group get LDAPDefaultRole
+	LDAPGroupRoles  string
+	LDAPDefaultRole string
+	// OpenID Connect single sign-on is enabled when OIDCIssuer is set. OIDCRedirectURL is the callback
+	// registered with the provider. Claims are mapped onto users; OIDCGroupRoles uses the LDAPGroupRoles format.
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        []string
+	OIDCUsernameClaim string
+	OIDCUnitClaim     string
+	OIDCGroupsClaim   string
+	OIDCGroupRoles    string
+	OIDCDefaultRole   string
+	// SLAEscalation runs a background job every SLAEscalationInterval that escalates requests past their
+	// SLA target to the next approver level
+	SLAEscalation         bool
+	SLAEscalationInterval string
+	// TimeZone is the IANA zone working days are counted in; due dates and weekends follow its midnight
+	TimeZone string
+
+	// settings records where every value came from, in load order
+	settings []Setting
+}
+
+// Setting is a single configuration value and where it was loaded from
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool
+}
+
+// loader resolves settings from the environment, then config.env, then the default
+type loader struct {
+	file     map[string]string
+	settings []Setting
+}
+
+func (l *loader) get(key, defaultValue string, secret bool) string {
+	setting := Setting{Key: key, Value: defaultValue, Source: SourceDefault, Secret: secret}
+
+	if value := os.Getenv(key); value != "" {
+		setting.Value, setting.Source = value, SourceEnvironment
+	} else if value := l.file[key]; value != "" {
+		setting.Value, setting.Source = value, SourceFile
+	}
+
+	l.settings = append(l.settings, setting)
+	return setting.Value
+}
+
+func (l *loader) getInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(l.get(key, strconv.Itoa(defaultValue), false))
+	if err != nil {
+		return defaultValue
+	}
+	return value
 }
 
 func Load() *Config {
-	// Load .env file if it exists
-	godotenv.Load("config.env")
+	// Read config.env if it exists; variables already set in the environment take precedence
+	file, _ := godotenv.Read(envFile)
+	l := &loader{file: file}
 
-	port, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
+	cfg := &Config{
+		DBHost:             l.get("DB_HOST", "localhost", false),
+		DBPort:             l.getInt("DB_PORT", 5432),
+		DBUser:             l.get("DB_USER", "postgres", false),
+		DBPassword:         l.get("DB_PASSWORD", defaultDBPassword, true),
+		DBName:             l.get("DB_NAME", "web_work_request", false),
+		DBSSLMode:          l.get("DB_SSLMODE", "disable", false),
+		JWTSecret:          l.get("JWT_SECRET", defaultJWTSecret, true),
+		JWTExpiry:          l.get("JWT_EXPIRY", "15m", false),
+		JWTKeyID:           l.get("JWT_KEY_ID", "default", false),
+		JWTKeysDir:         l.get("JWT_KEYS_DIR", "", false),
+		RefreshTokenExpiry: l.get("REFRESH_TOKEN_EXPIRY", "720h", false),
+		ServerHost:         l.get("SERVER_HOST", "0.0.0.0", false),
+		ServerPort:         l.get("SERVER_PORT", "8080", false),
+		ServerMode:         l.get("SERVER_MODE", ModeDebug, false),
+		CORS:               l.get("CORS", "false", false),
+		CORSAllowedOrigins: splitList(l.get("CORS_ALLOWED_ORIGINS", "http://localhost:3000", false)),
+		// Private ranges cover the nginx frontend container in the docker setups
+		TrustedProxies:          splitList(l.get("TRUSTED_PROXIES", "127.0.0.1,::1,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16", false)),
+		LoginMaxFailures:        l.getInt("LOGIN_MAX_FAILURES", 10),
+		LoginLockoutDuration:    l.get("LOGIN_LOCKOUT_DURATION", "15m", false),
+		PasswordMinLength:       l.getInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordRequiredClasses: splitList(l.get("PASSWORD_REQUIRED_CLASSES", "lower,upper,digit", false)),
+		PasswordHistory:         l.getInt("PASSWORD_HISTORY", 5),
+		PasswordResetExpiry:     l.get("PASSWORD_RESET_EXPIRY", "24h", false),
+		TOTPIssuer:              l.get("TOTP_ISSUER", "Web Work Request", false),
+		TOTPRequiredRoles:       splitList(l.get("TOTP_REQUIRED_ROLES", "", false)),
+		SelfRegistration:        l.get("SELF_REGISTRATION", "true", false) == "true",
+		AuthProviders:           splitList(l.get("AUTH_PROVIDERS", AuthProviderLocal, false)),
+		LDAPURL:                 l.get("LDAP_URL", "", false),
+		LDAPStartTLS:            l.get("LDAP_START_TLS", "false", false) == "true",
+		LDAPBindDN:              l.get("LDAP_BIND_DN", "", false),
+		LDAPBindPassword:        l.get("LDAP_BIND_PASSWORD", "", true),
+		LDAPBaseDN:              l.get("LDAP_BASE_DN", "", false),
+		LDAPUserFilter:          l.get("LDAP_USER_FILTER", "(uid=%s)", false),
+		LDAPUsernameAttribute:   l.get("LDAP_USERNAME_ATTRIBUTE", "uid", false),
+		LDAPNameAttribute:       l.get("LDAP_NAME_ATTRIBUTE", "displayName", false),
+		LDAPEmailAttribute:      l.get("LDAP_EMAIL_ATTRIBUTE", "mail", false),
+		LDAPDepartmentAttribute: l.get("LDAP_DEPARTMENT_ATTRIBUTE", "department", false),
+		LDAPGroupAttribute:      l.get("LDAP_GROUP_ATTRIBUTE", "memberOf", false),
+		LDAPGroupRoles:          l.get("LDAP_GROUP_ROLES", "", false),
+		LDAPDefaultRole:         l.get("LDAP_DEFAULT_ROLE", "user", false),
+		OIDCIssuer:              l.get("OIDC_ISSUER", "", false),
+		OIDCClientID:            l.get("OIDC_CLIENT_ID", "", false),
+		OIDCClientSecret:        l.get("OIDC_CLIENT_SECRET", "", true),
+		OIDCRedirectURL:         l.get("OIDC_REDIRECT_URL", "", false),
+		OIDCScopes:              splitList(l.get("OIDC_SCOPES", "openid,profile,email", false)),
+		OIDCUsernameClaim:       l.get("OIDC_USERNAME_CLAIM", "preferred_username", false),
+		OIDCUnitClaim:           l.get("OIDC_UNIT_CLAIM", "department", false),
+		OIDCGroupsClaim:         l.get("OIDC_GROUPS_CLAIM", "groups", false),
+		OIDCGroupRoles:          l.get("OIDC_GROUP_ROLES", "", false),
+		OIDCDefaultRole:         l.get("OIDC_DEFAULT_ROLE", "user", false),
+		SLAEscalation:           l.get("SLA_ESCALATION", "true", false) == "true",
+		SLAEscalationInterval:   l.get("SLA_ESCALATION_INTERVAL", "15m", false),
+		TimeZone:                l.get("TIME_ZONE", "Asia/Jakarta", false),
+	}
+	cfg.settings = l.settings
+
+	return cfg
+}
+
+// LoginLockoutTTL returns how long an account stays locked, falling back to 15 minutes when invalid
+func (c *Config) LoginLockoutTTL() time.Duration {
+	return parseDuration(c.LoginLockoutDuration, 15*time.Minute)
+}
 
-	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     port,
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "password"),
-		DBName:     getEnv("DB_NAME", "web_work_request"),
-		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
-		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key-here"),
-		JWTExpiry:  getEnv("JWT_EXPIRY", "24h"),
-		ServerHost: getEnv("SERVER_HOST", "0.0.0.0"),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		ServerMode: getEnv("SERVER_MODE", "debug"),
-		CORS:       getEnv("CORS", "false"),
+// PasswordResetTTL returns how long reset tokens are valid, falling back to 24 hours when invalid
+func (c *Config) PasswordResetTTL() time.Duration {
+	return parseDuration(c.PasswordResetExpiry, 24*time.Hour)
+}
+
+// SLAEscalationEvery returns how often overdue requests are escalated, falling back to 15 minutes when invalid
+func (c *Config) SLAEscalationEvery() time.Duration {
+	return parseDuration(c.SLAEscalationInterval, 15*time.Minute)
+}
+
+// Location returns the zone working days are counted in, falling back to UTC when TIME_ZONE is invalid
+func (c *Config) Location() *time.Location {
+	location, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC
 	}
+	return location
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Settings returns every loaded setting with its source; secret values are redacted
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, len(c.settings))
+	for i, setting := range c.settings {
+		if setting.Secret {
+			setting.Value = Redact(setting.Value)
+		}
+		settings[i] = setting
+	}
+	return settings
+}
+
+// Redact hides a secret value while still showing whether it is set
+func Redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "********"
+}
+
+// plainConfig has the fields of Config without its String method
+type plainConfig Config
+
+// String formats the configuration with secrets redacted, so it is safe to log
+func (c *Config) String() string {
+	redacted := plainConfig(*c)
+	redacted.DBPassword = Redact(c.DBPassword)
+	redacted.JWTSecret = Redact(c.JWTSecret)
+	redacted.LDAPBindPassword = Redact(c.LDAPBindPassword)
+	redacted.OIDCClientSecret = Redact(c.OIDCClientSecret)
+	redacted.settings = nil
+	return fmt.Sprintf("%+v", redacted)
+}
+
+// AccessTokenTTL returns the lifetime of access tokens, falling back to 15 minutes when JWTExpiry is invalid
+func (c *Config) AccessTokenTTL() time.Duration {
+	return parseDuration(c.JWTExpiry, 15*time.Minute)
+}
+
+// RefreshTokenTTL returns the lifetime of refresh tokens, falling back to 30 days when RefreshTokenExpiry is invalid
+func (c *Config) RefreshTokenTTL() time.Duration {
+	return parseDuration(c.RefreshTokenExpiry, 30*24*time.Hour)
+}
+
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fallback
 	}
-	return defaultValue
+	return d
 }
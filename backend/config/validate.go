@@ -0,0 +1,204 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Server modes; they match Gin's modes
+const (
+	ModeDebug   = "debug"
+	ModeRelease = "release"
+	ModeTest    = "test"
+)
+
+// Defaults that are only acceptable for local development
+const (
+	defaultDBPassword = "password"
+	defaultJWTSecret  = "your-secret-key-here"
+)
+
+// MinJWTSecretLength is the shortest HS256 secret accepted in production
+const MinJWTSecretLength = 32
+
+// placeholderSecrets are example values shipped in the repository's env files
+var placeholderSecrets = map[string]bool{
+	defaultJWTSecret: true,
+	"your_super_secret_jwt_key_change_in_production": true,
+	"your-secret-key-here-change-in-production":      true,
+}
+
+// isPlaceholderSecret reports whether secret is a shipped example or still asks to be changed
+func isPlaceholderSecret(secret string) bool {
+	lower := strings.ToLower(secret)
+	return placeholderSecrets[secret] ||
+		strings.Contains(lower, "change-in-production") || strings.Contains(lower, "change_in_production")
+}
+
+// Login providers AUTH_PROVIDERS may list
+const (
+	AuthProviderLocal = "local"
+	AuthProviderLDAP  = "ldap"
+)
+
+// UsesAuthProvider reports whether AUTH_PROVIDERS enables the provider
+func (c *Config) UsesAuthProvider(name string) bool {
+	for _, provider := range c.AuthProviders {
+		if provider == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCEnabled reports whether OpenID Connect single sign-on is configured
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuer != ""
+}
+
+// AllowsOrigin reports whether CORS_ALLOWED_ORIGINS lists the browser origin
+func (c *Config) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.CORSAllowedOrigins {
+		if origin != "" && strings.TrimSuffix(allowed, "/") == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// origin returns the scheme and host of an absolute URL, or "" when it has none
+func origin(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// passwordClasses are the character classes PASSWORD_REQUIRED_CLASSES may list
+var passwordClasses = map[string]bool{"lower": true, "upper": true, "digit": true, "symbol": true}
+
+// ValidationError lists every setting that prevents the server from starting
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "insecure configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// IsProduction reports whether the server runs in release mode
+func (c *Config) IsProduction() bool {
+	return c.ServerMode == ModeRelease
+}
+
+// Problems lists settings that are invalid or not safe for production
+func (c *Config) Problems() []string {
+	var problems []string
+
+	switch c.ServerMode {
+	case ModeDebug, ModeRelease, ModeTest:
+	default:
+		problems = append(problems, "SERVER_MODE must be one of debug, release or test")
+	}
+
+	if c.DBPassword == "" || c.DBPassword == defaultDBPassword {
+		problems = append(problems, "DB_PASSWORD is empty or uses the default value")
+	}
+
+	// The HMAC secret only matters when no asymmetric keys are configured
+	if c.JWTKeysDir == "" {
+		if isPlaceholderSecret(c.JWTSecret) {
+			problems = append(problems, "JWT_SECRET uses a default placeholder value")
+		} else if len(c.JWTSecret) < MinJWTSecretLength {
+			problems = append(problems, "JWT_SECRET must be at least 32 characters long")
+		}
+	}
+
+	if !validDuration(c.JWTExpiry) {
+		problems = append(problems, "JWT_EXPIRY is not a valid duration")
+	}
+	if !validDuration(c.RefreshTokenExpiry) {
+		problems = append(problems, "REFRESH_TOKEN_EXPIRY is not a valid duration")
+	}
+	if !validDuration(c.LoginLockoutDuration) {
+		problems = append(problems, "LOGIN_LOCKOUT_DURATION is not a valid duration")
+	}
+	if !validDuration(c.PasswordResetExpiry) {
+		problems = append(problems, "PASSWORD_RESET_EXPIRY is not a valid duration")
+	}
+	if c.SLAEscalation && !validDuration(c.SLAEscalationInterval) {
+		problems = append(problems, "SLA_ESCALATION_INTERVAL is not a valid duration")
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil || c.TimeZone == "" {
+		problems = append(problems, "TIME_ZONE is not a known time zone")
+	}
+	for _, class := range c.PasswordRequiredClasses {
+		if !passwordClasses[class] {
+			problems = append(problems, "PASSWORD_REQUIRED_CLASSES contains unknown class "+class)
+		}
+	}
+	if c.LoginMaxFailures <= 0 {
+		problems = append(problems, "LOGIN_MAX_FAILURES must be a positive number")
+	}
+
+	// Credentialed requests are only answered for an exact origin, never for *
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			problems = append(problems, "CORS_ALLOWED_ORIGINS must list origins instead of *")
+		}
+	}
+
+	if len(c.AuthProviders) == 0 {
+		problems = append(problems, "AUTH_PROVIDERS must list at least one provider")
+	}
+	for _, provider := range c.AuthProviders {
+		if provider != AuthProviderLocal && provider != AuthProviderLDAP {
+			problems = append(problems, "AUTH_PROVIDERS contains unknown provider "+provider)
+		}
+	}
+	if c.UsesAuthProvider(AuthProviderLDAP) {
+		if c.LDAPURL == "" || c.LDAPBaseDN == "" {
+			problems = append(problems, "LDAP_URL and LDAP_BASE_DN are required when AUTH_PROVIDERS includes ldap")
+		}
+		// Directory passwords would otherwise cross the network in clear text
+		if strings.HasPrefix(c.LDAPURL, "ldap://") && !c.LDAPStartTLS {
+			problems = append(problems, "LDAP_URL uses ldap:// without LDAP_START_TLS")
+		}
+		if !strings.Contains(c.LDAPUserFilter, "%s") {
+			problems = append(problems, "LDAP_USER_FILTER must contain %s for the username")
+		}
+	}
+	if c.OIDCEnabled() {
+		if c.OIDCClientID == "" || c.OIDCRedirectURL == "" {
+			problems = append(problems, "OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+		} else if !c.AllowsOrigin(origin(c.OIDCRedirectURL)) {
+			// The callback page completes the login with a credentialed request to the API
+			problems = append(problems, "OIDC_REDIRECT_URL must be on an origin listed in CORS_ALLOWED_ORIGINS")
+		}
+		// Plain http is only useful against a local test issuer
+		if !strings.HasPrefix(c.OIDCIssuer, "https://") && c.IsProduction() {
+			problems = append(problems, "OIDC_ISSUER must use https")
+		}
+	}
+
+	return problems
+}
+
+// Validate refuses insecure settings in production; in other modes problems are only reported by Problems
+func (c *Config) Validate() error {
+	if !c.IsProduction() {
+		return nil
+	}
+
+	if problems := c.Problems(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validDuration(value string) bool {
+	d, err := time.ParseDuration(value)
+	return err == nil && d > 0
+}
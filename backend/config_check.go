@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"web-work-request-backend/config"
+	"web-work-request-backend/utils"
+)
+
+// runConfigCheck prints the effective configuration and where each value came from, followed by any
+// problems. It returns the process exit code: 1 when the server would refuse to start.
+func runConfigCheck(w io.Writer, cfg *config.Config) int {
+	fmt.Fprintf(w, "Server mode: %s (production: %t)\n\n", cfg.ServerMode, cfg.IsProduction())
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, setting := range cfg.Settings() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+	}
+	tw.Flush()
+
+	problems := cfg.Problems()
+	if _, err := utils.NewKeyManager(cfg); err != nil {
+		problems = append(problems, "JWT signing keys: "+err.Error())
+	}
+
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "\nNo problems found")
+		return 0
+	}
+
+	fmt.Fprintln(w, "\nProblems:")
+	for _, problem := range problems {
+		fmt.Fprintf(w, "  - %s\n", problem)
+	}
+
+	// Outside production the server starts anyway and only logs these problems
+	if cfg.IsProduction() {
+		return 1
+	}
+	return 0
+}
@@ -91,10 +91,589 @@ func createTables(db *sql.DB) error {
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
 
+	// Multi-item array columns, added separately so existing databases pick them up
+	addRequestArrayColumns := `
+	ALTER TABLE request
+		ADD COLUMN IF NOT EXISTS nama_barang_array TEXT[],
+		ADD COLUMN IF NOT EXISTS type_model_array TEXT[],
+		ADD COLUMN IF NOT EXISTS jumlah_array INTEGER[],
+		ADD COLUMN IF NOT EXISTS keterangan_array TEXT[],
+		ADD COLUMN IF NOT EXISTS nama_barang_perbaikan_array TEXT[],
+		ADD COLUMN IF NOT EXISTS type_model_perbaikan_array TEXT[],
+		ADD COLUMN IF NOT EXISTS jumlah_perbaikan_array INTEGER[],
+		ADD COLUMN IF NOT EXISTS jenis_pekerjaan_array TEXT[],
+		ADD COLUMN IF NOT EXISTS lokasi_perbaikan_array TEXT[],
+		ADD COLUMN IF NOT EXISTS lokasi_peminjaman_array TEXT[],
+		ADD COLUMN IF NOT EXISTS kegunaan_array TEXT[],
+		ADD COLUMN IF NOT EXISTS tgl_peminjaman_array DATE[],
+		ADD COLUMN IF NOT EXISTS tgl_pengembalian_array DATE[];`
+
+	// Request line items table
+	createRequestItemsTable := `
+	CREATE TABLE IF NOT EXISTS request_items (
+		id BIGSERIAL PRIMARY KEY,
+		request_id BIGINT NOT NULL REFERENCES request(id) ON DELETE CASCADE,
+		item_no INT NOT NULL,
+		nama_barang VARCHAR(200),
+		type_model VARCHAR(100),
+		jenis_pekerjaan TEXT,
+		lokasi VARCHAR(200),
+		kegunaan TEXT,
+		tgl_peminjaman DATE,
+		tgl_pengembalian DATE,
+		jumlah_diminta INT NOT NULL DEFAULT 1,
+		jumlah_disetujui INT,
+		keterangan TEXT,
+		catatan TEXT,
+		status_item VARCHAR(50) NOT NULL DEFAULT 'DIAJUKAN',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (request_id, item_no)
+	);
+	CREATE INDEX IF NOT EXISTS idx_request_items_request_id ON request_items(request_id);`
+
+	// Move the items of requests created before request_items into it, from the array columns or the single
+	// item columns, and carry the request's decision over to them. Runs once, like
+	// scripts/migrate-to-request-items.sql did by hand.
+	backfillRequestItems := `
+	INSERT INTO request_items (request_id, item_no, nama_barang, type_model, jumlah_diminta, keterangan)
+	SELECT r.id, t.item_no, t.nama_barang, t.type_model, COALESCE(t.jumlah, 1), t.keterangan
+	FROM request r
+	CROSS JOIN LATERAL unnest(r.nama_barang_array, r.type_model_array, r.jumlah_array, r.keterangan_array)
+		WITH ORDINALITY AS t(nama_barang, type_model, jumlah, keterangan, item_no)
+	WHERE r.jenis_request = 'pengadaan'
+		AND COALESCE(array_length(r.nama_barang_array, 1), 0) > 0
+		AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+	INSERT INTO request_items (request_id, item_no, nama_barang, type_model, jumlah_diminta, jenis_pekerjaan, lokasi)
+	SELECT r.id, t.item_no, t.nama_barang, t.type_model, COALESCE(t.jumlah, 1), t.jenis_pekerjaan, t.lokasi
+	FROM request r
+	CROSS JOIN LATERAL unnest(r.nama_barang_perbaikan_array, r.type_model_perbaikan_array, r.jumlah_perbaikan_array,
+		r.jenis_pekerjaan_array, r.lokasi_perbaikan_array)
+		WITH ORDINALITY AS t(nama_barang, type_model, jumlah, jenis_pekerjaan, lokasi, item_no)
+	WHERE r.jenis_request = 'perbaikan'
+		AND COALESCE(array_length(r.nama_barang_perbaikan_array, 1), 0) > 0
+		AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+	INSERT INTO request_items (request_id, item_no, lokasi, kegunaan, tgl_peminjaman, tgl_pengembalian)
+	SELECT r.id, t.item_no, t.lokasi, t.kegunaan, t.tgl_peminjaman, t.tgl_pengembalian
+	FROM request r
+	CROSS JOIN LATERAL unnest(r.lokasi_peminjaman_array, r.kegunaan_array, r.tgl_peminjaman_array, r.tgl_pengembalian_array)
+		WITH ORDINALITY AS t(lokasi, kegunaan, tgl_peminjaman, tgl_pengembalian, item_no)
+	WHERE r.jenis_request = 'peminjaman'
+		AND COALESCE(array_length(r.lokasi_peminjaman_array, 1), 0) > 0
+		AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+	INSERT INTO request_items (request_id, item_no, nama_barang, type_model, jumlah_diminta, jenis_pekerjaan, lokasi,
+		kegunaan, tgl_peminjaman, tgl_pengembalian)
+	SELECT r.id, 1, r.nama_barang, r.type_model, COALESCE(r.jumlah, 1), r.jenis_pekerjaan, r.lokasi,
+		r.kegunaan, r.tgl_peminjaman, r.tgl_pengembalian
+	FROM request r
+	WHERE (r.nama_barang IS NOT NULL OR r.lokasi IS NOT NULL OR r.kegunaan IS NOT NULL)
+		AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+	UPDATE request_items i SET status_item = 'DITOLAK', jumlah_disetujui = 0
+	FROM request r
+	WHERE i.request_id = r.id AND r.status_request = 'DITOLAK' AND i.status_item = 'DIAJUKAN';
+
+	UPDATE request_items i SET status_item = 'DISETUJUI', jumlah_disetujui = i.jumlah_diminta
+	FROM request r
+	WHERE i.request_id = r.id AND r.status_request IN ('DISETUJUI', 'DIPROSES', 'SELESAI') AND i.status_item = 'DIAJUKAN';`
+
+	// Request status timeline
+	createRequestStatusHistoryTable := `
+	CREATE TABLE IF NOT EXISTS request_status_history (
+		id BIGSERIAL PRIMARY KEY,
+		request_id BIGINT NOT NULL REFERENCES request(id) ON DELETE CASCADE,
+		old_status VARCHAR(50),
+		new_status VARCHAR(50) NOT NULL,
+		changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		catatan TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_request_status_history_request_id ON request_status_history(request_id);`
+
+	// Link requests to users by ID; the name columns are kept for display
+	addRequestUserColumns := `
+	ALTER TABLE request
+		ADD COLUMN IF NOT EXISTS requested_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		ADD COLUMN IF NOT EXISTS approved_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		ADD COLUMN IF NOT EXISTS accepted_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+	CREATE INDEX IF NOT EXISTS idx_request_requested_by_id ON request(requested_by_id);`
+
+	// Backfill the user ID columns from the name columns where the name is unambiguous. Runs once: requests
+	// created later are linked by ID, and a request whose approver was cleared must stay cleared.
+	backfillRequestUserIDs := `
+	WITH unique_names AS (
+		SELECT name, (array_agg(id))[1] AS id FROM users GROUP BY name HAVING COUNT(*) = 1
+	)
+	UPDATE request r SET
+		requested_by_id = COALESCE(r.requested_by_id, (SELECT id FROM unique_names WHERE name = r.requested_by)),
+		approved_by_id = COALESCE(r.approved_by_id, (SELECT id FROM unique_names WHERE name = r.approved_by)),
+		accepted_by_id = COALESCE(r.accepted_by_id, (SELECT id FROM unique_names WHERE name = r.accepted_by))
+	WHERE (r.requested_by_id IS NULL AND r.requested_by IS NOT NULL)
+	   OR (r.approved_by_id IS NULL AND r.approved_by IS NOT NULL)
+	   OR (r.accepted_by_id IS NULL AND r.accepted_by IS NOT NULL);`
+
+	// Full-text search document, kept up to date by triggers on request, request_items and users
+	createRequestSearch := `
+	ALTER TABLE request
+		ADD COLUMN IF NOT EXISTS search_text TEXT,
+		ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+	CREATE INDEX IF NOT EXISTS idx_request_search_vector ON request USING GIN (search_vector);
+
+	CREATE OR REPLACE FUNCTION refresh_request_search(p_request_id BIGINT) RETURNS void AS $$
+		WITH doc AS (
+			SELECT r.id,
+				concat_ws(' ', r.nama_barang,
+					array_to_string(r.nama_barang_array, ' '),
+					array_to_string(r.nama_barang_perbaikan_array, ' '),
+					(SELECT string_agg(i.nama_barang, ' ') FROM request_items i WHERE i.request_id = r.id)) AS names,
+				concat_ws(' ', r.type_model, r.jenis_pekerjaan, r.kegunaan, r.lokasi,
+					array_to_string(r.type_model_array, ' '),
+					array_to_string(r.type_model_perbaikan_array, ' '),
+					array_to_string(r.jenis_pekerjaan_array, ' '),
+					array_to_string(r.lokasi_perbaikan_array, ' '),
+					array_to_string(r.lokasi_peminjaman_array, ' '),
+					array_to_string(r.kegunaan_array, ' '),
+					(SELECT string_agg(concat_ws(' ', i.type_model, i.jenis_pekerjaan, i.lokasi, i.kegunaan), ' ')
+					 FROM request_items i WHERE i.request_id = r.id)) AS details,
+				concat_ws(' ', r.keterangan,
+					array_to_string(r.keterangan_array, ' '),
+					(SELECT string_agg(i.keterangan, ' ') FROM request_items i WHERE i.request_id = r.id),
+					r.requested_by, u.name, r.unit) AS notes
+			FROM request r
+			LEFT JOIN users u ON u.id = r.requested_by_id
+			WHERE r.id = p_request_id
+		)
+		UPDATE request r SET
+			search_text = concat_ws(' ', doc.names, doc.details, doc.notes),
+			search_vector = setweight(to_tsvector('simple', doc.names), 'A') ||
+				setweight(to_tsvector('simple', doc.details), 'B') ||
+				setweight(to_tsvector('simple', doc.notes), 'C')
+		FROM doc
+		WHERE r.id = doc.id;
+	$$ LANGUAGE sql;
+
+	CREATE OR REPLACE FUNCTION request_search_trigger() RETURNS trigger AS $$
+	BEGIN
+		IF TG_TABLE_NAME = 'request_items' THEN
+			IF TG_OP = 'DELETE' THEN
+				PERFORM refresh_request_search(OLD.request_id);
+			ELSE
+				PERFORM refresh_request_search(NEW.request_id);
+			END IF;
+		ELSIF TG_TABLE_NAME = 'users' THEN
+			PERFORM refresh_request_search(id) FROM request WHERE requested_by_id = NEW.id;
+		ELSE
+			PERFORM refresh_request_search(NEW.id);
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	-- The column list excludes search_text/search_vector so the refresh does not retrigger itself
+	DROP TRIGGER IF EXISTS trg_request_search ON request;
+	CREATE TRIGGER trg_request_search
+		AFTER INSERT OR UPDATE OF unit, nama_barang, type_model, lokasi, jenis_pekerjaan, kegunaan, keterangan,
+			requested_by, requested_by_id, nama_barang_array, type_model_array, keterangan_array,
+			nama_barang_perbaikan_array, type_model_perbaikan_array, jenis_pekerjaan_array,
+			lokasi_perbaikan_array, lokasi_peminjaman_array, kegunaan_array
+		ON request FOR EACH ROW EXECUTE FUNCTION request_search_trigger();
+
+	DROP TRIGGER IF EXISTS trg_request_items_search ON request_items;
+	CREATE TRIGGER trg_request_items_search
+		AFTER INSERT OR UPDATE OR DELETE ON request_items
+		FOR EACH ROW EXECUTE FUNCTION request_search_trigger();
+
+	DROP TRIGGER IF EXISTS trg_users_request_search ON users;
+	CREATE TRIGGER trg_users_request_search
+		AFTER UPDATE OF name ON users
+		FOR EACH ROW EXECUTE FUNCTION request_search_trigger();
+
+	SELECT refresh_request_search(id) FROM request WHERE search_vector IS NULL;`
+
+	// Roles and their permissions
+	createRolesTables := `
+	CREATE TABLE IF NOT EXISTS roles (
+		name VARCHAR(20) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS permissions (
+		name VARCHAR(50) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role VARCHAR(20) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+		permission VARCHAR(50) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+		PRIMARY KEY (role, permission)
+	);`
+
+	// Default roles and permissions. Admins always hold every permission, including ones added later.
+	seedRolesAndPermissions := `
+	INSERT INTO permissions (name, description) VALUES
+		('request.create', 'Submit new requests'),
+		('request.view_all', 'View requests from every unit'),
+		('request.approve', 'Approve or reject requests'),
+		('request.process', 'Process and complete approved requests'),
+		('request.delete_any', 'Delete any request'),
+		('user.manage', 'Create, update and delete users'),
+		('report.view', 'View system-wide dashboard statistics'),
+		('role.manage', 'Manage roles and their permissions'),
+		('approval.manage', 'Configure approval chains'),
+		('sla.manage', 'Configure SLA targets')
+	ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO roles (name, description) VALUES
+		('user', 'Regular staff member'),
+		('operator', 'Handles and approves requests'),
+		('admin', 'Full system administrator')
+	ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO role_permissions (role, permission)
+	SELECT 'admin', name FROM permissions
+	ON CONFLICT DO NOTHING;`
+
+	// Default grants of the other roles. Runs once, so a role an admin has emptied stays empty; roles that
+	// already had grants before this ran are left as they are.
+	seedRoleGrants := `
+	INSERT INTO role_permissions (role, permission)
+	SELECT r.role, r.permission FROM (VALUES
+		('user', 'request.create'),
+		('operator', 'request.create'),
+		('operator', 'request.view_all'),
+		('operator', 'request.approve'),
+		('operator', 'request.process'),
+		('operator', 'request.delete_any'),
+		('operator', 'user.manage'),
+		('operator', 'report.view')
+	) AS r(role, permission)
+	WHERE NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role = r.role)
+	ON CONFLICT DO NOTHING;`
+
+	// Access tokens carry the user's token version; bumping it revokes them immediately
+	addUserTokenVersion := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;`
+
+	createRefreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		expires_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ,
+		replaced_by UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+	-- Tables created before expiry was compared with the server's clock used TIMESTAMP; existing values
+	-- are read in the session time zone
+	DO $$
+	BEGIN
+		IF (SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'refresh_tokens' AND column_name = 'expires_at')
+			= 'timestamp without time zone' THEN
+			ALTER TABLE refresh_tokens
+				ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+				ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+		END IF;
+	END $$;`
+
+	// Failed login counters are keyed by "user:<username>" or "ip:<address>"
+	createLoginAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		key VARCHAR(320) PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure TIMESTAMPTZ NOT NULL,
+		locked_until TIMESTAMPTZ
+	);`
+
+	// Previous password hashes, used to refuse reusing a recent password
+	createPasswordHistoryTable := `
+	CREATE TABLE IF NOT EXISTS password_history (
+		id BIGSERIAL PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		password_hash VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history(user_id, created_at DESC);`
+
+	createPasswordResetTokensTable := `
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		used_at TIMESTAMPTZ,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	addUserTOTPColumns := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;`
+
+	// Single-use 2FA recovery codes; only the SHA-256 hash of each code is stored
+	createRecoveryCodesTable := `
+	CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+		id BIGSERIAL PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		used_at TIMESTAMPTZ,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_user_id ON totp_recovery_codes(user_id);`
+
+	// Two-factor challenges that completed a login, kept until they expire so none completes a second one
+	createUsedChallengesTable := `
+	CREATE TABLE IF NOT EXISTS used_login_challenges (
+		id VARCHAR(64) PRIMARY KEY,
+		expires_at TIMESTAMPTZ NOT NULL
+	);`
+
+	// Accounts provisioned from a directory have no local password
+	addUserAuthSource := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS auth_source VARCHAR(20) NOT NULL DEFAULT 'local';`
+
+	// Single sign-on accounts are matched by the provider's subject; login states live for minutes
+	createOIDCTables := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_users_external_id ON users(auth_source, external_id) WHERE external_id IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS oidc_login_states (
+		state_hash VARCHAR(64) PRIMARY KEY,
+		nonce VARCHAR(64) NOT NULL,
+		code_verifier VARCHAR(128) NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Self-registered accounts are pending until an operator approves them; existing accounts are active
+	addUserStatus := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+	CREATE INDEX IF NOT EXISTS idx_users_status ON users(status);`
+
+	// Users are never removed: deleting one only marks it, so the requests they made and decided keep
+	// pointing at them
+	addUserDeletedAt := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;`
+
+	// Units form a tree; users and requests reference them, and keep the unit name for display and search
+	createUnitsTable := `
+	CREATE TABLE IF NOT EXISTS units (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name VARCHAR(100) NOT NULL,
+		cost_center VARCHAR(50),
+		parent_id UUID REFERENCES units(id),
+		head_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_units_name ON units(LOWER(name));
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_units_cost_center ON units(cost_center) WHERE cost_center IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_units_parent_id ON units(parent_id);
+
+	-- Names of units merged into another, so directory departments that still use them resolve
+	CREATE TABLE IF NOT EXISTS unit_aliases (
+		alias VARCHAR(100) NOT NULL,
+		unit_id UUID NOT NULL REFERENCES units(id) ON DELETE CASCADE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_unit_aliases_alias ON unit_aliases(LOWER(alias));
+
+	DO $$
+	BEGIN
+		IF (SELECT character_maximum_length FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'unit') < 100 THEN
+			ALTER TABLE users ALTER COLUMN unit TYPE VARCHAR(100);
+		END IF;
+	END $$;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS unit_id UUID REFERENCES units(id);
+	ALTER TABLE request ADD COLUMN IF NOT EXISTS unit_id UUID REFERENCES units(id);
+	CREATE INDEX IF NOT EXISTS idx_users_unit_id ON users(unit_id);
+	CREATE INDEX IF NOT EXISTS idx_request_unit_id ON request(unit_id);`
+
+	// Free-text units that differ only in case or spacing become one unit, named after the most used
+	// spelling. Synonyms such as "Finance" and "Keuangan" cannot be told apart here; operators merge them
+	// with POST /api/operator/units/:id/merge. Runs once, so a merged unit is not created again from the
+	// free text of users who were never linked.
+	backfillUnits := `
+	INSERT INTO units (name)
+	SELECT DISTINCT ON (key) name FROM (
+		SELECT LOWER(name) AS key, name, COUNT(*) AS uses
+		FROM (
+			SELECT REGEXP_REPLACE(TRIM(unit), '\s+', ' ', 'g') AS name FROM users WHERE unit_id IS NULL
+			UNION ALL
+			SELECT REGEXP_REPLACE(TRIM(unit), '\s+', ' ', 'g') FROM request WHERE unit_id IS NULL
+		) AS free_text
+		WHERE name <> ''
+		GROUP BY name
+	) AS spellings
+	ORDER BY key, uses DESC, name
+	ON CONFLICT ((LOWER(name))) DO NOTHING;
+
+	UPDATE users u SET unit_id = un.id, unit = un.name
+	FROM units un
+	WHERE u.unit_id IS NULL AND LOWER(REGEXP_REPLACE(TRIM(u.unit), '\s+', ' ', 'g')) = LOWER(un.name);
+
+	UPDATE request r SET unit_id = un.id, unit = un.name
+	FROM units un
+	WHERE r.unit_id IS NULL AND LOWER(REGEXP_REPLACE(TRIM(r.unit), '\s+', ' ', 'g')) = LOWER(un.name);`
+
+	// Approval chains are configured per request type, unit and estimated value; their steps are copied onto
+	// each request when it is submitted, so later changes to a chain do not affect requests in flight. The
+	// estimated value is the sum of the item prices.
+	createApprovalTables := `
+	ALTER TABLE request ADD COLUMN IF NOT EXISTS nilai_estimasi NUMERIC(15,2) NOT NULL DEFAULT 0;
+	ALTER TABLE request_items ADD COLUMN IF NOT EXISTS harga_satuan NUMERIC(15,2) CHECK (harga_satuan >= 0);
+
+	CREATE TABLE IF NOT EXISTS approval_chains (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		jenis_request VARCHAR(50) NOT NULL,
+		unit_id UUID REFERENCES units(id),
+		min_value NUMERIC(15,2) NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_approval_chains_jenis_request ON approval_chains(jenis_request);
+
+	CREATE TABLE IF NOT EXISTS approval_chain_steps (
+		chain_id INTEGER NOT NULL REFERENCES approval_chains(id) ON DELETE CASCADE,
+		step_no INTEGER NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		approver_type VARCHAR(20) NOT NULL,
+		approver_role VARCHAR(20),
+		approver_user_id UUID REFERENCES users(id),
+		PRIMARY KEY (chain_id, step_no)
+	);
+
+	CREATE TABLE IF NOT EXISTS request_approvals (
+		id BIGSERIAL PRIMARY KEY,
+		request_id BIGINT NOT NULL REFERENCES request(id) ON DELETE CASCADE,
+		step_no INTEGER NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		approver_type VARCHAR(20) NOT NULL,
+		approver_role VARCHAR(20),
+		approver_user_id UUID REFERENCES users(id),
+		decision VARCHAR(20) NOT NULL DEFAULT 'DIAJUKAN',
+		decided_by_id UUID REFERENCES users(id),
+		decided_at TIMESTAMP,
+		catatan TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (request_id, step_no)
+	);
+	CREATE INDEX IF NOT EXISTS idx_request_approvals_pending ON request_approvals(approver_user_id, approver_role) WHERE decision = 'DIAJUKAN';`
+
+	// Delegations let a substitute decide approvals for an absent approver between two dates, inclusive
+	createDelegationsTable := `
+	CREATE TABLE IF NOT EXISTS approval_delegations (
+		id BIGSERIAL PRIMARY KEY,
+		delegator_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		substitute_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		start_date DATE NOT NULL,
+		end_date DATE NOT NULL,
+		reason VARCHAR(255),
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CHECK (end_date >= start_date),
+		CHECK (delegator_id <> substitute_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_approval_delegations_substitute ON approval_delegations(substitute_id, end_date);
+	CREATE INDEX IF NOT EXISTS idx_approval_delegations_delegator ON approval_delegations(delegator_id, end_date);
+
+	ALTER TABLE request_status_history ADD COLUMN IF NOT EXISTS on_behalf_of UUID REFERENCES users(id) ON DELETE SET NULL;
+	ALTER TABLE request_approvals ADD COLUMN IF NOT EXISTS on_behalf_of UUID REFERENCES users(id) ON DELETE SET NULL;`
+
+	// SLA targets give each request type a number of working days per open status. status_since starts the
+	// clock and is backfilled from the status history; escalation_level counts the escalations since then.
+	createSLATables := `
+	CREATE TABLE IF NOT EXISTS sla_targets (
+		jenis_request VARCHAR(50) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		working_days INTEGER NOT NULL CHECK (working_days > 0),
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (jenis_request, status)
+	);
+
+	ALTER TABLE request
+		ADD COLUMN IF NOT EXISTS status_since TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS escalation_level INTEGER NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS escalated_to_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+	-- The SLA clock is compared with the server's clock, so it must not depend on the session time zone.
+	-- Existing values were written in the session time zone, which is how the conversion reads them.
+	DO $$
+	BEGIN
+		IF (SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'request' AND column_name = 'status_since')
+			= 'timestamp without time zone' THEN
+			ALTER TABLE request
+				ALTER COLUMN status_since TYPE TIMESTAMPTZ,
+				ALTER COLUMN escalated_at TYPE TIMESTAMPTZ;
+		END IF;
+	END $$;
+
+	UPDATE request r SET status_since = COALESCE(
+		(SELECT MAX(h.created_at) FROM request_status_history h
+		 WHERE h.request_id = r.id AND h.new_status = r.status_request),
+		r.created_at, CURRENT_TIMESTAMP)
+	WHERE r.status_since IS NULL;
+
+	ALTER TABLE request ALTER COLUMN status_since SET DEFAULT CURRENT_TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_request_open_status_since ON request(status_request, status_since)
+		WHERE status_request IN ('DIAJUKAN', 'DISETUJUI', 'DIPROSES');`
+
+	// Public holidays and other days off, skipped when counting working days
+	createHolidaysTable := `
+	CREATE TABLE IF NOT EXISTS holidays (
+		date DATE PRIMARY KEY,
+		name VARCHAR(200) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Data migrations that must not run again once applied; schema_migrations records which ones have
+	createSchemaMigrationsTable := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		name VARCHAR(100) PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
 	// Execute table creation
 	tables := []string{
 		createUsersTable,
 		createRequestsTable,
+		addRequestArrayColumns,
+		createRequestItemsTable,
+		createRequestStatusHistoryTable,
+		addRequestUserColumns,
+		createRequestSearch,
+		createRolesTables,
+		seedRolesAndPermissions,
+		addUserTokenVersion,
+		createRefreshTokensTable,
+		createLoginAttemptsTable,
+		createPasswordHistoryTable,
+		createPasswordResetTokensTable,
+		addUserTOTPColumns,
+		createRecoveryCodesTable,
+		createUsedChallengesTable,
+		addUserAuthSource,
+		createOIDCTables,
+		addUserStatus,
+		addUserDeletedAt,
+		createUnitsTable,
+		createApprovalTables,
+		createDelegationsTable,
+		createSLATables,
+		createHolidaysTable,
+		createSchemaMigrationsTable,
 	}
 
 	for _, table := range tables {
@@ -103,6 +682,53 @@ func createTables(db *sql.DB) error {
 		}
 	}
 
+	migrations := []migration{
+		{"seed_role_grants", seedRoleGrants},
+		{"backfill_request_items", backfillRequestItems},
+		{"backfill_request_user_ids", backfillRequestUserIDs},
+		{"backfill_units", backfillUnits},
+	}
+
+	for _, m := range migrations {
+		if err := runOnce(db, m); err != nil {
+			return fmt.Errorf("failed to run migration %s: %v", m.name, err)
+		}
+	}
+
 	log.Println("Database tables created successfully")
 	return nil
 }
+
+// migration is a data migration applied by runOnce
+type migration struct {
+	name  string
+	query string
+}
+
+// runOnce applies a migration unless schema_migrations records it as applied. The record is written in the
+// same transaction, so a failed migration is retried on the next start.
+func runOnce(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, m.name)
+	if err != nil {
+		return err
+	}
+	applied, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if applied == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.query); err != nil {
+		return err
+	}
+	log.Printf("Applied migration %s", m.name)
+	return tx.Commit()
+}
@@ -0,0 +1,157 @@
+// Package directory authenticates users against an LDAP or Active Directory server.
+package directory
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"web-work-request-backend/config"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrInvalidCredentials is returned for an unknown, ambiguous or wrong username and password
+var ErrInvalidCredentials = errors.New("invalid directory credentials")
+
+// timeout bounds connecting and every request, so a slow directory cannot hang logins
+const timeout = 10 * time.Second
+
+// Entry is what the directory knows about a user who logged in
+type Entry struct {
+	DN         string
+	Username   string
+	Name       string
+	Email      string
+	Department string
+	Groups     []string
+}
+
+// Client looks users up with a service account and verifies passwords by binding as the user
+type Client struct {
+	url          string
+	startTLS     bool
+	bindDN       string
+	bindPassword string
+	baseDN       string
+	userFilter   string
+
+	usernameAttribute   string
+	nameAttribute       string
+	emailAttribute      string
+	departmentAttribute string
+	groupAttribute      string
+}
+
+// NewClient creates a client for the directory described by the LDAP_* settings
+func NewClient(cfg *config.Config) *Client {
+	return &Client{
+		url:                 cfg.LDAPURL,
+		startTLS:            cfg.LDAPStartTLS,
+		bindDN:              cfg.LDAPBindDN,
+		bindPassword:        cfg.LDAPBindPassword,
+		baseDN:              cfg.LDAPBaseDN,
+		userFilter:          cfg.LDAPUserFilter,
+		usernameAttribute:   cfg.LDAPUsernameAttribute,
+		nameAttribute:       cfg.LDAPNameAttribute,
+		emailAttribute:      cfg.LDAPEmailAttribute,
+		departmentAttribute: cfg.LDAPDepartmentAttribute,
+		groupAttribute:      cfg.LDAPGroupAttribute,
+	}
+}
+
+// Authenticate finds the user's entry and checks the password by binding as that entry
+func (c *Client) Authenticate(username, password string) (*Entry, error) {
+	// An empty password is an unauthenticated bind, which most servers accept for any DN
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.bindDN != "" {
+		if err := conn.Bind(c.bindDN, c.bindPassword); err != nil {
+			return nil, fmt.Errorf("directory service bind failed: %w", err)
+		}
+	}
+
+	search := ldap.NewSearchRequest(
+		c.baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		// Two results are enough to tell that a filter is ambiguous
+		2,
+		int(timeout/time.Second),
+		false,
+		strings.ReplaceAll(c.userFilter, "%s", ldap.EscapeFilter(username)),
+		[]string{c.usernameAttribute, c.nameAttribute, "cn", c.emailAttribute, c.departmentAttribute, c.groupAttribute},
+		nil,
+	)
+
+	result, err := conn.Search(search)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("directory search failed: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	found := result.Entries[0]
+
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("directory bind failed: %w", err)
+	}
+
+	entry := &Entry{
+		DN:         found.DN,
+		Username:   found.GetAttributeValue(c.usernameAttribute),
+		Name:       found.GetAttributeValue(c.nameAttribute),
+		Email:      found.GetAttributeValue(c.emailAttribute),
+		Department: found.GetAttributeValue(c.departmentAttribute),
+		Groups:     found.GetAttributeValues(c.groupAttribute),
+	}
+	if entry.Username == "" {
+		entry.Username = username
+	}
+	if entry.Name == "" {
+		entry.Name = found.GetAttributeValue("cn")
+	}
+	if entry.Name == "" {
+		entry.Name = entry.Username
+	}
+
+	return entry, nil
+}
+
+func (c *Client) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(c.url, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+	conn.SetTimeout(timeout)
+
+	if c.startTLS {
+		host := c.url
+		if parsed, err := url.Parse(c.url); err == nil {
+			host = parsed.Hostname()
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("directory StartTLS failed: %w", err)
+		}
+	}
+
+	return conn, nil
+}
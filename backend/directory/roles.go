@@ -0,0 +1,81 @@
+package directory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// GroupRole gives members of Group the Role; Group is a full DN or the name of the group (its first RDN value)
+type GroupRole struct {
+	Role  string
+	Group string
+}
+
+// RoleMapping picks a role from a user's group memberships
+type RoleMapping struct {
+	// Rules are checked in order; the first rule matching one of the user's groups wins
+	Rules       []GroupRole
+	DefaultRole string
+}
+
+// ParseRoleMapping reads LDAP_GROUP_ROLES: role:group pairs separated by ";"
+func ParseRoleMapping(value, defaultRole string) (RoleMapping, error) {
+	mapping := RoleMapping{DefaultRole: defaultRole}
+
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		role, group, ok := strings.Cut(pair, ":")
+		role, group = strings.TrimSpace(role), strings.TrimSpace(group)
+		if !ok || role == "" || group == "" {
+			return RoleMapping{}, fmt.Errorf("invalid group role %q, expected role:group", pair)
+		}
+		mapping.Rules = append(mapping.Rules, GroupRole{Role: role, Group: group})
+	}
+
+	return mapping, nil
+}
+
+// Roles returns every role the mapping can assign
+func (m RoleMapping) Roles() []string {
+	roles := []string{m.DefaultRole}
+	for _, rule := range m.Rules {
+		roles = append(roles, rule.Role)
+	}
+	return roles
+}
+
+// Role returns the role for a user who is a member of groups
+func (m RoleMapping) Role(groups []string) string {
+	for _, rule := range m.Rules {
+		for _, group := range groups {
+			if groupMatches(rule.Group, group) {
+				return rule.Role
+			}
+		}
+	}
+	return m.DefaultRole
+}
+
+// groupMatches compares a configured group with a membership DN; DNs are compared case-insensitively
+// and a plain name matches the value of the DN's first RDN, so "IT Support" matches "CN=IT Support,OU=Groups,..."
+func groupMatches(configured, member string) bool {
+	if strings.EqualFold(configured, member) {
+		return true
+	}
+
+	dn, err := ldap.ParseDN(member)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return false
+	}
+
+	if configuredDN, err := ldap.ParseDN(configured); err == nil && len(configuredDN.RDNs) > 1 {
+		return configuredDN.EqualFold(dn)
+	}
+	return strings.EqualFold(configured, dn.RDNs[0].Attributes[0].Value)
+}
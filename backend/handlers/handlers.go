@@ -1,23 +1,91 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
 	"web-work-request-backend/services"
+	"web-work-request-backend/throttle"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
 	service *services.Service
+	// trustedProxies may report the client's scheme in X-Forwarded-Proto
+	trustedProxies []*net.IPNet
 }
 
 func NewHandler(service *services.Service) *Handler {
 	return &Handler{service: service}
 }
 
+// WithTrustedProxies trusts X-Forwarded-Proto from the listed proxy addresses or CIDRs
+func (h *Handler) WithTrustedProxies(proxies []string) (*Handler, error) {
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		h.trustedProxies = append(h.trustedProxies, network)
+	}
+	return h, nil
+}
+
+// currentActor returns the authenticated user set by the auth middleware
+func currentActor(c *gin.Context) (*services.Actor, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	role, _ := c.Get("user_role")
+	roleStr, _ := role.(string)
+
+	return &services.Actor{UserID: userID.(string), Role: roleStr}, true
+}
+
+// respondForbidden writes the 403 payload shared by every authorization failure
+func respondForbidden(c *gin.Context, err error) {
+	response := gin.H{"error": err.Error(), "code": "FORBIDDEN"}
+
+	var forbiddenErr *services.ForbiddenError
+	if errors.As(err, &forbiddenErr) {
+		response["action"] = forbiddenErr.Action
+	}
+
+	c.JSON(http.StatusForbidden, response)
+}
+
+// respondBadRequest writes a 400 payload; password policy failures also list every broken rule
+func respondBadRequest(c *gin.Context, err error) {
+	response := gin.H{"error": err.Error()}
+
+	var policyErr *services.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		response["code"] = "PASSWORD_POLICY"
+		response["problems"] = policyErr.Problems
+	}
+
+	c.JSON(http.StatusBadRequest, response)
+}
+
 // Auth handlers
 func (h *Handler) Register(c *gin.Context) {
 	var req models.RegisterRequest
@@ -28,7 +96,11 @@ func (h *Handler) Register(c *gin.Context) {
 
 	user, err := h.service.RegisterUser(&req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrRegistrationDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		respondBadRequest(c, err)
 		return
 	}
 
@@ -45,25 +117,209 @@ func (h *Handler) Login(c *gin.Context) {
 
 	log.Printf("Login attempt for username: %s", req.Username)
 
-	response, err := h.service.LoginUser(&req)
+	response, err := h.service.LoginUser(&req, c.ClientIP())
 	if err != nil {
-		log.Printf("Login failed for username %s: %v", req.Username, err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		log.Printf("Login failed for username %s from %s: %v", req.Username, c.ClientIP(), err)
+
+		var blockedErr *throttle.BlockedError
+		switch {
+		case errors.As(err, &blockedErr):
+			respondBlocked(c, blockedErr)
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrAccountNotActive):
+			respondAccountNotActive(c, err)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		}
+		return
+	}
+
+	if response.ChallengeToken != "" {
+		log.Printf("Password accepted for username %s, second factor step: %s", req.Username, response.TwoFactor)
+	} else {
+		log.Printf("Login successful for username: %s", req.Username)
+	}
+	respondAuthenticated(c, response)
+}
+
+// respondAccountNotActive tells a user with the right credentials that their account cannot sign in
+func respondAccountNotActive(c *gin.Context, err error) {
+	c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "ACCOUNT_NOT_ACTIVE"})
+}
+
+// respondAuthenticated writes the response of a completed login step: the tokens, or the challenge for the
+// second factor
+func respondAuthenticated(c *gin.Context, response *models.AuthResponse) {
+	if response.ChallengeToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success":             true,
+			"two_factor_required": true,
+			"two_factor":          response.TwoFactor,
+			"challenge_token":     response.ChallengeToken,
+		})
 		return
 	}
 
-	log.Printf("Login successful for username: %s", req.Username)
 	// Send response with success flag for frontend compatibility
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"token":         response.Token,
+		"refresh_token": response.RefreshToken,
+		"expires_in":    response.ExpiresIn,
+		"user":          response.User,
+	})
+}
+
+// oidcStateCookie keeps the state of a single sign-on login in the browser that started it
+const oidcStateCookie = "oidc_state"
+
+// OIDCLogin sends the browser to the identity provider
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	authURL, state, err := h.service.StartOIDCLogin()
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCDisabled) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Single sign-on could not start: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Single sign-on is unavailable"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, 600, "/api/auth/oidc", "", h.isHTTPS(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes a single sign-on login and issues our own tokens
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	browserState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/auth/oidc", "", h.isHTTPS(c), true)
+
+	// The provider reports a cancelled or refused sign-in instead of sending a code
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": providerErr, "description": c.Query("error_description")})
+		return
+	}
+
+	response, err := h.service.CompleteOIDCLogin(c.Query("state"), browserState, c.Query("code"))
+	if err != nil {
+		log.Printf("Single sign-on failed from %s: %v", c.ClientIP(), err)
+		switch {
+		case errors.Is(err, services.ErrOIDCDisabled):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidOIDCLogin):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUnusableOIDCIdentity):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrAccountNotActive):
+			respondAccountNotActive(c, err)
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Single sign-on failed"})
+		}
+		return
+	}
+
+	log.Printf("Single sign-on successful for username: %s", response.User.Username)
+	respondAuthenticated(c, response)
+}
+
+// isHTTPS reports whether the client reached us over HTTPS, directly or through a trusted proxy
+func (h *Handler) isHTTPS(c *gin.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+
+	remote := net.ParseIP(c.RemoteIP())
+	for _, network := range h.trustedProxies {
+		if remote != nil && network.Contains(remote) {
+			return c.GetHeader("X-Forwarded-Proto") == "https"
+		}
+	}
+	return false
+}
+
+// ResetPassword sets a new password with a one-time token issued by an operator
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ResetPassword(&req); err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_RESET_TOKEN"})
+			return
+		}
+		var policyErr *services.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			respondBadRequest(c, err)
+			return
+		}
+		log.Printf("Password reset failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Password reset successfully",
+	})
+}
+
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.service.RefreshSession(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Token refresh failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"token":         response.Token,
+		"refresh_token": response.RefreshToken,
+		"expires_in":    response.ExpiresIn,
+		"user":          response.User,
+	})
+}
+
+func (h *Handler) Logout(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Logout(req.RefreshToken); err != nil {
+		log.Printf("Logout failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"token":   response.Token,
-		"user":    response.User,
+		"message": "Logged out successfully",
 	})
 }
 
 // User handlers
+// GetAllUsers lists users; deleted users are included with ?include_deleted=true
 func (h *Handler) GetAllUsers(c *gin.Context) {
-	users, err := h.service.GetAllUsers()
+	users, err := h.service.GetAllUsers(c.Query("include_deleted") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -101,222 +357,1295 @@ func (h *Handler) GetDashboardStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-func (h *Handler) GetCurrentUser(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+// respondBlocked writes the 429 payload for throttled or locked logins
+func respondBlocked(c *gin.Context, blockedErr *throttle.BlockedError) {
+	retryAfter := int(math.Ceil(blockedErr.RetryAfter.Seconds()))
+	code := "TOO_MANY_ATTEMPTS"
+	if blockedErr.Locked {
+		code = "ACCOUNT_LOCKED"
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": blockedErr.Error(), "code": code, "retry_after": retryAfter})
+}
+
+// respondTwoFactorError maps the errors of the 2FA endpoints to responses
+func respondTwoFactorError(c *gin.Context, err error) {
+	var blockedErr *throttle.BlockedError
+	switch {
+	case errors.As(err, &blockedErr):
+		respondBlocked(c, blockedErr)
+	case errors.Is(err, services.ErrInvalidChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "INVALID_CHALLENGE"})
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_2FA_CODE"})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled), errors.Is(err, services.ErrTwoFactorNotEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrForbidden):
+		respondForbidden(c, err)
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// VerifyTwoFactor completes a login with a TOTP or recovery code
+func (h *Handler) VerifyTwoFactor(c *gin.Context) {
+	var req models.TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user, err := h.service.GetUserByID(userID.(string))
+	response, err := h.service.VerifyTwoFactor(&req, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		log.Printf("Second factor failed from %s: %v", c.ClientIP(), err)
+		respondTwoFactorError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	log.Printf("Login successful for username: %s", response.User.Username)
+	respondAuthenticated(c, response)
 }
 
-func (h *Handler) CreateUser(c *gin.Context) {
-	var req models.CreateUserRequest
+// StartChallengeEnrollment starts 2FA enrollment during login for roles that require it
+func (h *Handler) StartChallengeEnrollment(c *gin.Context) {
+	var req models.TwoFactorEnrollmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("Create user binding error: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Creating user: %s", req.Username)
-
-	user, err := h.service.CreateUser(&req)
+	enrollment, err := h.service.StartChallengeEnrollment(&req, c.ClientIP())
 	if err != nil {
-		log.Printf("Failed to create user %s: %v", req.Username, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondTwoFactorError(c, err)
 		return
 	}
 
-	log.Printf("User created successfully: %s", req.Username)
-	c.JSON(http.StatusCreated, gin.H{
-		"success": true,
-		"message": "User created successfully",
-		"user":    user,
-	})
+	c.JSON(http.StatusOK, enrollment)
 }
 
-func (h *Handler) UpdateUser(c *gin.Context) {
-	userID := c.Param("id")
-	var req models.UpdateUserRequest
+// ConfirmChallengeEnrollment enables 2FA during login and completes the login
+func (h *Handler) ConfirmChallengeEnrollment(c *gin.Context) {
+	var req models.TwoFactorChallengeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("Update user binding error: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Updating user: %s", userID)
-
-	user, err := h.service.UpdateUser(userID, &req)
+	response, codes, err := h.service.ConfirmChallengeEnrollment(&req, c.ClientIP())
 	if err != nil {
-		log.Printf("Failed to update user %s: %v", userID, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondTwoFactorError(c, err)
 		return
 	}
 
-	log.Printf("User updated successfully: %s", userID)
+	log.Printf("Two-factor authentication enabled for username: %s", response.User.Username)
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "User updated successfully",
-		"user":    user,
+		"success":        true,
+		"token":          response.Token,
+		"refresh_token":  response.RefreshToken,
+		"expires_in":     response.ExpiresIn,
+		"user":           response.User,
+		"recovery_codes": codes,
 	})
 }
 
-func (h *Handler) DeleteUser(c *gin.Context) {
-	userID := c.Param("id")
-
-	log.Printf("Deleting user: %s", userID)
+// StartTwoFactorEnrollment starts 2FA enrollment for the current user
+func (h *Handler) StartTwoFactorEnrollment(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
 
-	err := h.service.DeleteUser(userID)
+	enrollment, err := h.service.StartTwoFactorEnrollment(actor.UserID)
 	if err != nil {
-		log.Printf("Failed to delete user %s: %v", userID, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondTwoFactorError(c, err)
 		return
 	}
 
-	log.Printf("User deleted successfully: %s", userID)
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "User deleted successfully",
-	})
+	c.JSON(http.StatusOK, enrollment)
 }
 
-// Request handlers
-func (h *Handler) CreateRequest(c *gin.Context) {
-	var req models.CreateRequestRequest
+// ConfirmTwoFactorEnrollment enables 2FA for the current user and returns their recovery codes
+func (h *Handler) ConfirmTwoFactorEnrollment(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	codes, err := h.service.ConfirmTwoFactorEnrollment(actor.UserID, req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
 		return
 	}
 
-	// Create request
-	request, err := h.service.CreateRequest(&req, userID.(string))
-	if err != nil {
+	log.Printf("Two-factor authentication enabled for user: %s", actor.UserID)
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns 2FA off for the current user
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	if err := h.service.DisableTwoFactor(actor.UserID, actor.Role, req.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	log.Printf("Two-factor authentication disabled for user: %s", actor.UserID)
+	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"request": request,
+		"message": "Two-factor authentication disabled",
 	})
 }
 
-func (h *Handler) GetRequestByID(c *gin.Context) {
-	requestID := c.Param("id")
-	request, err := h.service.GetRequestByID(requestID)
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	user, err := h.service.GetUserByID(userID.(string))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, request)
+	c.JSON(http.StatusOK, user)
 }
 
-func (h *Handler) GetAllRequests(c *gin.Context) {
-	// Get pagination parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	status := c.Query("status")
-
-	var requests []models.Request
-	var err error
-
-	if status != "" {
-		requests, err = h.service.GetRequestsByStatus(status)
-	} else {
-		requests, err = h.service.GetAllRequests()
+func (h *Handler) UpdateCurrentUser(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Simple pagination (in production, you'd want more sophisticated pagination)
-	start := (page - 1) * limit
-	end := start + limit
-	if start >= len(requests) {
-		start = len(requests)
-	}
-	if end > len(requests) {
-		end = len(requests)
+	user, err := h.service.UpdateProfile(userID.(string), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	paginatedRequests := requests[start:end]
-	total := len(requests)
-
 	c.JSON(http.StatusOK, gin.H{
-		"data": paginatedRequests,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"success": true,
+		"message": "Profile updated successfully",
+		"user":    user,
 	})
 }
 
-func (h *Handler) GetRequestsByUser(c *gin.Context) {
-	// Get user ID from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+// ChangePassword changes the current user's password and returns new tokens
+func (h *Handler) ChangePassword(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
 		return
 	}
 
-	requests, err := h.service.GetRequestsByUser(userID.(string))
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.service.ChangePassword(actor.UserID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var policyErr *services.PasswordPolicyError
+		switch {
+		case errors.Is(err, services.ErrWrongPassword), errors.As(err, &policyErr):
+			respondBadRequest(c, err)
+		case errors.Is(err, services.ErrExternalAccount):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Password change failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, requests)
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"message":       "Password changed successfully",
+		"token":         response.Token,
+		"refresh_token": response.RefreshToken,
+		"expires_in":    response.ExpiresIn,
+	})
 }
 
-func (h *Handler) UpdateRequestStatus(c *gin.Context) {
-	requestID := c.Param("id")
-	var req models.UpdateRequestRequest
+func (h *Handler) CreateUser(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Create user binding error: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err := h.service.UpdateRequestStatus(requestID, &req)
+	log.Printf("Creating user: %s", req.Username)
+
+	user, err := h.service.CreateUser(&req, actor)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		log.Printf("Failed to create user %s: %v", req.Username, err)
+		if errors.Is(err, services.ErrForbidden) {
+			respondForbidden(c, err)
+			return
+		}
+		respondBadRequest(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Request status updated successfully"})
+	log.Printf("User created successfully: %s", req.Username)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "User created successfully",
+		"user":    user,
+	})
 }
 
-func (h *Handler) DeleteRequest(c *gin.Context) {
-	requestID := c.Param("id")
-
-	// Note: In a production environment, you would check if the user
-	// has permission to delete this request (ownership or role-based)
+func (h *Handler) UpdateUser(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
 
-	err := h.service.DeleteRequest(requestID)
-	if err != nil {
+	userID := c.Param("id")
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Update user binding error: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Request deleted successfully"})
+	log.Printf("Updating user: %s", userID)
+
+	user, err := h.service.UpdateUser(userID, &req, actor)
+	if err != nil {
+		log.Printf("Failed to update user %s: %v", userID, err)
+		if errors.Is(err, services.ErrForbidden) {
+			respondForbidden(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("User updated successfully: %s", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User updated successfully",
+		"user":    user,
+	})
+}
+
+// CreatePasswordReset issues a one-time password reset token for a user
+func (h *Handler) CreatePasswordReset(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	token, reset, err := h.service.CreatePasswordReset(userID, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, services.ErrExternalAccount):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	log.Printf("Password reset token issued for user %s by %s", userID, actor.UserID)
+	c.JSON(http.StatusCreated, gin.H{
+		"success":    true,
+		"token":      token,
+		"expires_at": reset.ExpiresAt,
+	})
+}
+
+// UnlockUser lifts a login lockout
+func (h *Handler) UnlockUser(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	if err := h.service.UnlockUser(userID, actor); err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	log.Printf("User unlocked: %s", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User unlocked successfully",
+	})
+}
+
+// ResetTwoFactor removes a user's second factor so they can enroll again
+func (h *Handler) ResetTwoFactor(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	if err := h.service.ResetTwoFactor(userID, actor); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	log.Printf("Two-factor authentication reset for user %s by %s", userID, actor.UserID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Two-factor authentication reset successfully",
+	})
+}
+
+func (h *Handler) DeleteUser(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+
+	log.Printf("Deleting user: %s", userID)
+
+	err := h.service.DeleteUser(userID, actor)
+	if err != nil {
+		log.Printf("Failed to delete user %s: %v", userID, err)
+		respondUserLifecycleError(c, err)
+		return
+	}
+
+	log.Printf("User deleted successfully: %s", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User deleted successfully",
+	})
+}
+
+// Registration handlers
+
+// GetPendingRegistrations lists the self-registered accounts waiting for approval
+func (h *Handler) GetPendingRegistrations(c *gin.Context) {
+	users, err := h.service.GetPendingRegistrations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// ApproveRegistration activates a pending account
+func (h *Handler) ApproveRegistration(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	user, err := h.service.ApproveRegistration(userID, actor)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "pending registration not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// RejectRegistration removes a pending account
+func (h *Handler) RejectRegistration(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	if err := h.service.RejectRegistration(userID, actor); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "pending registration not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Registration rejected",
+	})
+}
+
+// DeactivateUser blocks a user from signing in without deleting the account
+func (h *Handler) DeactivateUser(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.DeactivateUser(c.Param("id"), actor)
+	if err != nil {
+		respondUserLifecycleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ReactivateUser lets a deactivated user sign in again
+func (h *Handler) ReactivateUser(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.ReactivateUser(c.Param("id"), actor)
+	if err != nil {
+		respondUserLifecycleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// RestoreUser brings back a deleted user
+func (h *Handler) RestoreUser(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.RestoreUser(c.Param("id"), actor)
+	if err != nil {
+		respondUserLifecycleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// respondUserLifecycleError maps the errors of deleting, deactivating, reactivating and restoring users
+func respondUserLifecycleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		respondForbidden(c, err)
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, services.ErrUserStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// Unit handlers
+
+// GetUnits lists every unit; it is public so the registration form can offer them
+func (h *Handler) GetUnits(c *gin.Context) {
+	units, err := h.service.GetUnits()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, units)
+}
+
+func (h *Handler) GetUnitByID(c *gin.Context) {
+	unit, err := h.service.GetUnitByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, unit)
+}
+
+func (h *Handler) CreateUnit(c *gin.Context) {
+	var req models.UnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	unit, err := h.service.CreateUnit(&req)
+	if err != nil {
+		respondUnitError(c, err)
+		return
+	}
+
+	log.Printf("Unit created: %s", unit.Name)
+	c.JSON(http.StatusCreated, unit)
+}
+
+func (h *Handler) UpdateUnit(c *gin.Context) {
+	var req models.UnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	unit, err := h.service.UpdateUnit(c.Param("id"), &req)
+	if err != nil {
+		respondUnitError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, unit)
+}
+
+func (h *Handler) DeleteUnit(c *gin.Context) {
+	unitID := c.Param("id")
+	if err := h.service.DeleteUnit(unitID); err != nil {
+		respondUnitError(c, err)
+		return
+	}
+
+	log.Printf("Unit deleted: %s", unitID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Unit deleted successfully",
+	})
+}
+
+// MergeUnit folds a duplicate unit into another one
+func (h *Handler) MergeUnit(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	var req models.MergeUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	unit, err := h.service.MergeUnit(c.Param("id"), &req, actor)
+	if err != nil {
+		respondUnitError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, unit)
+}
+
+// respondUnitError maps the errors of changing units
+func respondUnitError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "unit not found"})
+	case errors.Is(err, services.ErrUnitExists), errors.Is(err, services.ErrUnitInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// Holiday handlers
+
+// maxICSSize limits the size of an uploaded holiday calendar; a multipart upload may add
+// maxMultipartOverhead for its headers and boundaries
+const (
+	maxICSSize           = 1 << 20
+	maxMultipartOverhead = 16 << 10
+)
+
+// GetHolidays lists the holidays, optionally of one ?year
+func (h *Handler) GetHolidays(c *gin.Context) {
+	year := 0
+	if value := c.Query("year"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year must be a positive number"})
+			return
+		}
+		year = parsed
+	}
+
+	holidays, err := h.service.GetHolidays(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, holidays)
+}
+
+// SaveHoliday adds a holiday or renames the holiday on that date
+func (h *Handler) SaveHoliday(c *gin.Context) {
+	var req models.HolidayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	holiday, err := h.service.SaveHoliday(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, holiday)
+}
+
+// ImportHolidays reads an iCalendar file, uploaded as the "file" form field or sent as the request body
+func (h *Handler) ImportHolidays(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	// The limit wraps the request body itself, so a multipart upload is cut off while it is being parsed
+	// rather than after it has been buffered
+	var body io.Reader
+	if c.ContentType() == "multipart/form-data" {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxICSSize+maxMultipartOverhead)
+		file, err := c.FormFile("file")
+		if err != nil {
+			respondUploadError(c, err)
+			return
+		}
+		if file.Size > maxICSSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "the calendar file is too large"})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer opened.Close()
+		body = opened
+	} else {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxICSSize)
+		body = c.Request.Body
+	}
+
+	holidays, err := h.service.ImportHolidays(body, actor)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"imported": len(holidays),
+		"holidays": holidays,
+	})
+}
+
+// respondUploadError reports an upload that went over its size limit as 413 and anything else as 400
+func respondUploadError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "the calendar file is too large"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+func (h *Handler) DeleteHoliday(c *gin.Context) {
+	if err := h.service.DeleteHoliday(c.Param("date")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "holiday not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Holiday deleted successfully",
+	})
+}
+
+// Role handlers
+func (h *Handler) GetRoles(c *gin.Context) {
+	roles, err := h.service.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *Handler) GetPermissions(c *gin.Context) {
+	permissions, err := h.service.GetPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+func (h *Handler) UpsertRole(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpsertRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.service.UpsertRole(c.Param("name"), &req, actor)
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			respondForbidden(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"role":    role,
+	})
+}
+
+// Request handlers
+func (h *Handler) CreateRequest(c *gin.Context) {
+	var req models.CreateRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Create request
+	request, err := h.service.CreateRequest(&req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"request": request,
+	})
+}
+
+func (h *Handler) GetRequestByID(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	requestID := c.Param("id")
+	request, err := h.service.GetRequestByID(requestID, actor)
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			respondForbidden(c, err)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+func (h *Handler) GetAllRequests(c *gin.Context) {
+	// Get pagination parameters
+	pagination := models.PaginationRequest{Page: 1, Limit: 10}
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var filter models.RequestFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.ListRequests(&filter, &pagination, actor)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) SearchRequests(c *gin.Context) {
+	var query models.RequestSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pagination := models.PaginationRequest{Page: 1, Limit: 10}
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.SearchRequests(query.Q, &pagination, actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetRequestsByUser(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	requests, err := h.service.GetRequestsByUser(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func (h *Handler) UpdateRequestStatus(c *gin.Context) {
+	requestID := c.Param("id")
+	var req models.UpdateRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.UpdateRequestStatus(requestID, &req, actor)
+	if err != nil {
+		var transitionErr *services.TransitionError
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.As(err, &transitionErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":               err.Error(),
+				"from":                transitionErr.From,
+				"to":                  transitionErr.To,
+				"allowed_transitions": transitionErr.Allowed,
+			})
+		case errors.Is(err, repository.ErrStatusChanged), errors.Is(err, services.ErrApprovalChainRequired):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Request status updated successfully"})
+}
+
+// DecideApproval approves or rejects the current approval step of a request
+func (h *Handler) DecideApproval(c *gin.Context) {
+	var req models.ApprovalDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.service.DecideApproval(c.Param("id"), &req, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, services.ErrNoPendingApproval), errors.Is(err, repository.ErrStatusChanged):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// GetPendingApprovals lists the requests waiting for the current user's approval
+func (h *Handler) GetPendingApprovals(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	requests, err := h.service.GetPendingApprovals(actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// Delegation handlers
+
+// GetDelegations lists the current user's delegations; expired ones are included with ?include_expired=true
+func (h *Handler) GetDelegations(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	delegations, err := h.service.GetDelegations(actor, c.Query("include_expired") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delegations)
+}
+
+func (h *Handler) CreateDelegation(c *gin.Context) {
+	var req models.DelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	delegation, err := h.service.CreateDelegation(&req, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, services.ErrDelegationOverlap):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, delegation)
+}
+
+func (h *Handler) DeleteDelegation(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteDelegation(c.Param("id"), actor); err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "delegation not found"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Delegation withdrawn successfully",
+	})
+}
+
+// SLA target handlers
+
+func (h *Handler) GetSLATargets(c *gin.Context) {
+	targets, err := h.service.GetSLATargets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, targets)
+}
+
+// SaveSLATarget creates or replaces the SLA target of a request type and status
+func (h *Handler) SaveSLATarget(c *gin.Context) {
+	var req models.SLATargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, err := h.service.SaveSLATarget(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+func (h *Handler) DeleteSLATarget(c *gin.Context) {
+	if err := h.service.DeleteSLATarget(c.Param("jenis_request"), c.Param("status")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "SLA target not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "SLA target deleted successfully",
+	})
+}
+
+// Approval chain handlers
+
+// GetApprovalChains lists the approval chains, optionally for one ?jenis_request
+func (h *Handler) GetApprovalChains(c *gin.Context) {
+	chains, err := h.service.GetApprovalChains(c.Query("jenis_request"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chains)
+}
+
+func (h *Handler) GetApprovalChainByID(c *gin.Context) {
+	chain, err := h.service.GetApprovalChainByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "approval chain not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, chain)
+}
+
+func (h *Handler) CreateApprovalChain(c *gin.Context) {
+	var req models.ApprovalChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chain, err := h.service.CreateApprovalChain(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Approval chain created: %s", chain.Name)
+	c.JSON(http.StatusCreated, chain)
+}
+
+func (h *Handler) UpdateApprovalChain(c *gin.Context) {
+	var req models.ApprovalChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chain, err := h.service.UpdateApprovalChain(c.Param("id"), &req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "approval chain not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chain)
+}
+
+func (h *Handler) DeleteApprovalChain(c *gin.Context) {
+	if err := h.service.DeleteApprovalChain(c.Param("id")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "approval chain not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Approval chain deleted successfully",
+	})
+}
+
+func (h *Handler) UpdateRequestItem(c *gin.Context) {
+	requestID := c.Param("id")
+	itemID := c.Param("item_id")
+	var req models.UpdateRequestItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	item, err := h.service.UpdateRequestItem(requestID, itemID, &req, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, services.ErrRequestClosed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "request item not found"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"item":    item,
+	})
+}
+
+func (h *Handler) GetRequestHistory(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	requestID := c.Param("id")
+	history, err := h.service.GetRequestHistory(requestID, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *Handler) DeleteRequest(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		return
+	}
+
+	requestID := c.Param("id")
+	err := h.service.DeleteRequest(requestID, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, err)
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Request deleted successfully"})
+}
+
+// GetJWKS publishes the public keys that verify access tokens
+func (h *Handler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.JWKS())
 }
 
 // Health check
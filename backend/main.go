@@ -2,18 +2,37 @@ package main
 
 import (
 	"log"
+	"os"
+	_ "time/tzdata" // TIME_ZONE must resolve in images without a zoneinfo database
 	"web-work-request-backend/config"
 	"web-work-request-backend/database"
 	"web-work-request-backend/handlers"
 	"web-work-request-backend/repository"
 	"web-work-request-backend/routes"
 	"web-work-request-backend/services"
+	"web-work-request-backend/utils"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// "config check" prints the effective configuration instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if len(os.Args) != 3 || os.Args[2] != "check" {
+			log.Fatal("Usage: ", os.Args[0], " config check")
+		}
+		os.Exit(runConfigCheck(os.Stdout, cfg))
+	}
+
+	// Refuse to start with default secrets in production; in development only warn about them
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Refusing to start: ", err)
+	}
+	for _, problem := range cfg.Problems() {
+		log.Printf("Warning: %s", problem)
+	}
+
 	// Initialize database
 	db, err := database.InitDB(cfg)
 	if err != nil {
@@ -21,15 +40,47 @@ func main() {
 	}
 	defer db.Close()
 
+	// Load the keys used to sign and verify access tokens
+	keys, err := utils.NewKeyManager(cfg)
+	if err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+
 	// Initialize repository, service, and handler
 	repo := repository.NewRepository(db)
-	service := services.NewService(repo)
-	handler := handlers.NewHandler(service)
+
+	// Logins are checked by the providers listed in AUTH_PROVIDERS, in order
+	authenticators, err := services.NewAuthenticators(repo, cfg)
+	if err != nil {
+		log.Fatal("Invalid authentication providers:", err)
+	}
+
+	// OpenID Connect single sign-on is available when OIDC_ISSUER is set
+	sso, err := services.NewSingleSignOn(cfg)
+	if err != nil {
+		log.Fatal("Invalid single sign-on configuration:", err)
+	}
+
+	service := services.NewService(repo, cfg, keys, authenticators...).WithSingleSignOn(sso)
+	handler, err := handlers.NewHandler(service).WithTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
+	// Requests past their SLA target are escalated in the background
+	if cfg.SLAEscalation {
+		go service.RunEscalations(cfg.SLAEscalationEvery())
+	}
 
 	// Setup routes
-	router := routes.SetupRoutes(handler)
+	router := routes.SetupRoutes(handler, service, cfg.AllowsOrigin)
+
+	// Only proxies we run may set X-Forwarded-For; login throttling relies on the real client IP
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
-	log.Printf("Server starting on port %s", cfg.ServerPort)
+	log.Printf("Server starting on port %s in %s mode", cfg.ServerPort, cfg.ServerMode)
 
 	// Start server - bind to all interfaces
 	err = router.Run(":" + cfg.ServerPort)
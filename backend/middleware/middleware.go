@@ -3,13 +3,28 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"web-work-request-backend/models"
 	"web-work-request-backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware checks if the user is authenticated
-func AuthMiddleware() gin.HandlerFunc {
+// SessionChecker validates access tokens and confirms they still belong to an active session
+type SessionChecker interface {
+	ParseAccessToken(token string) (*utils.AccessClaims, error)
+
+	// CheckSession returns the user's current role and whether tokens with this version are still valid
+	CheckSession(userID string, tokenVersion int) (role string, active bool, err error)
+}
+
+// AccessControl is everything the route middleware needs from the service layer
+type AccessControl interface {
+	SessionChecker
+	PermissionChecker
+}
+
+// AuthMiddleware checks if the user is authenticated and their session has not been revoked
+func AuthMiddleware(sessions SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -28,61 +43,107 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		token := tokenParts[1]
 
-		// Validate token and extract user ID
-		userID, err := utils.ExtractUserIDFromToken(token)
+		// Validate token and extract its claims
+		claims, err := sessions.ParseAccessToken(token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Set user ID in context
-		c.Set("user_id", userID)
-
-		// Extract role
-		role, err := utils.ExtractRoleFromToken(token)
-		if err == nil {
-			c.Set("user_role", role)
+		// The role is read from the database so deleting or demoting a user takes effect immediately
+		role, active, err := sessions.CheckSession(claims.UserID, claims.TokenVersion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked", "code": "SESSION_REVOKED"})
+			c.Abort()
+			return
 		}
 
+		c.Set("user_id", claims.UserID)
+		c.Set("user_role", role)
+
 		c.Next()
 	}
 }
 
-// RoleMiddleware checks if the user has the required role
-func RoleMiddleware(requiredRole string) gin.HandlerFunc {
+// RoleMiddleware checks if the user has one of the allowed roles
+func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("user_role")
 		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "User role not found"})
+			c.JSON(http.StatusForbidden, gin.H{"error": "User role not found", "code": "FORBIDDEN"})
 			c.Abort()
 			return
 		}
 
-		if userRole != requiredRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-			c.Abort()
-			return
+		for _, role := range allowedRoles {
+			if userRole == role {
+				c.Next()
+				return
+			}
 		}
 
-		c.Next()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "code": "FORBIDDEN"})
+		c.Abort()
 	}
 }
 
-// OperatorMiddleware checks if the user is an operator
+// OperatorMiddleware checks if the user is an operator or an admin
 func OperatorMiddleware() gin.HandlerFunc {
-	return RoleMiddleware("operator")
+	return RoleMiddleware(models.RoleOperator, models.RoleAdmin)
 }
 
-// CORS middleware for handling cross-origin requests
-func CORSMiddleware() gin.HandlerFunc {
+// PermissionChecker resolves whether a role grants a permission
+type PermissionChecker interface {
+	HasPermission(role string, permission string) (bool, error)
+}
+
+// RequirePermission checks that the user's role grants every listed permission
+func RequirePermission(checker PermissionChecker, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, exists := c.Get("user_role")
+		if !exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User role not found", "code": "FORBIDDEN"})
+			c.Abort()
+			return
+		}
+
+		role, _ := userRole.(string)
+		for _, permission := range permissions {
+			allowed, err := checker.HasPermission(role, permission)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+				c.Abort()
+				return
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + permission, "code": "FORBIDDEN", "permission": permission})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// CORSMiddleware lets the frontend origins accepted by allowOrigin call the API with credentials. The
+// origin is echoed back because browsers refuse credentialed responses that allow every origin with *.
+func CORSMiddleware(allowOrigin func(origin string) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Allow specific origins in production, use * for development
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, User-Agent")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		c.Header("Access-Control-Max-Age", "86400") // 24 hours
+		c.Header("Vary", "Origin")
+		if origin := c.GetHeader("Origin"); allowOrigin(origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+			c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, User-Agent")
+			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
+			c.Header("Access-Control-Max-Age", "86400") // 24 hours
+		}
 
 		// Handle preflight requests
 		if c.Request.Method == "OPTIONS" {
@@ -6,6 +6,82 @@ import (
 	"github.com/google/uuid"
 )
 
+// Request status values
+const (
+	StatusDiajukan  = "DIAJUKAN"
+	StatusDisetujui = "DISETUJUI"
+	StatusDitolak   = "DITOLAK"
+	StatusDiproses  = "DIPROSES"
+	StatusSelesai   = "SELESAI"
+)
+
+// Request types
+const (
+	JenisPengadaan  = "pengadaan"
+	JenisPerbaikan  = "perbaikan"
+	JenisPeminjaman = "peminjaman"
+)
+
+// User roles
+const (
+	RoleUser     = "user"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Permissions that can be granted to roles
+const (
+	PermissionRequestCreate    = "request.create"
+	PermissionRequestViewAll   = "request.view_all"
+	PermissionRequestApprove   = "request.approve"
+	PermissionRequestProcess   = "request.process"
+	PermissionRequestDeleteAny = "request.delete_any"
+	PermissionUserManage       = "user.manage"
+	PermissionReportView       = "report.view"
+	PermissionRoleManage       = "role.manage"
+	PermissionApprovalManage   = "approval.manage"
+	PermissionSLAManage        = "sla.manage"
+)
+
+// Role represents a named set of permissions
+type Role struct {
+	Name        string   `json:"name" db:"name"`
+	Description string   `json:"description" db:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Permission represents a single named permission
+type Permission struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+}
+
+// UpsertRoleRequest represents the request to create or update a role
+type UpsertRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// Authentication sources of user accounts
+const (
+	AuthSourceLocal = "local"
+	// AuthSourceLDAP accounts are provisioned on first directory login and have no local password
+	AuthSourceLDAP = "ldap"
+	// AuthSourceOIDC accounts are provisioned on first single sign-on and matched by their subject
+	AuthSourceOIDC = "oidc"
+)
+
+// Statuses of user accounts; only active users can sign in
+const (
+	UserStatusActive = "active"
+	// UserStatusPending accounts registered themselves and wait for an operator's approval
+	UserStatusPending = "pending"
+	// UserStatusDeactivated accounts are kept but cannot sign in until they are reactivated
+	UserStatusDeactivated = "deactivated"
+	// UserStatusDeleted accounts are hidden from user lists and can be restored
+	UserStatusDeleted = "deleted"
+)
+
 // User represents a user in the system
 type User struct {
 	ID           uuid.UUID `json:"id" db:"id"`
@@ -14,9 +90,36 @@ type User struct {
 	Name         string    `json:"name" db:"name"`
 	Email        string    `json:"email" db:"email"`
 	Unit         string    `json:"unit" db:"unit"`
-	Role         string    `json:"role" db:"role"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	// UnitID references the user's unit; Unit keeps its name for display and search
+	UnitID *uuid.UUID `json:"unit_id" db:"unit_id"`
+	Role   string     `json:"role" db:"role"`
+	Status string     `json:"status" db:"status"`
+	// TokenVersion is embedded in access tokens; bumping it revokes every token issued before
+	TokenVersion int `json:"-" db:"token_version"`
+	// TOTPSecret is set while enrolling and after; TOTPEnabled only once enrollment was confirmed
+	TOTPSecret  *string `json:"-" db:"totp_secret"`
+	TOTPEnabled bool    `json:"totp_enabled" db:"totp_enabled"`
+	// TOTPLastStep is the last accepted time step, so a code cannot be replayed
+	TOTPLastStep int64 `json:"-" db:"totp_last_step"`
+	// AuthSource is the authenticator that owns the account's password: local or ldap
+	AuthSource string `json:"auth_source" db:"auth_source"`
+	// ExternalID is the identity provider's subject for single sign-on accounts
+	ExternalID *string `json:"-" db:"external_id"`
+	// DeletedAt is set while the account is deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// RefreshToken represents a stored refresh token; only the SHA-256 hash of the token is kept
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by" db:"replaced_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 }
 
 // Request represents a request (pengadaan, perbaikan, peminjaman)
@@ -24,6 +127,8 @@ type Request struct {
 	ID           int64  `json:"id" db:"id"`
 	JenisRequest string `json:"jenis_request" db:"jenis_request"`
 	Unit         string `json:"unit" db:"unit"`
+	// UnitID references the requesting unit; Unit keeps its name for display and search
+	UnitID *uuid.UUID `json:"unit_id" db:"unit_id"`
 
 	// For pengadaan: array fields
 	NamaBarangArray []string `json:"nama_barang_array" db:"nama_barang_array"`
@@ -61,14 +166,181 @@ type Request struct {
 	RequestedBy   string     `json:"requested_by" db:"requested_by"`
 	ApprovedBy    *string    `json:"approved_by" db:"approved_by"`
 	AcceptedBy    *string    `json:"accepted_by" db:"accepted_by"`
+	RequestedByID *string    `json:"requested_by_id" db:"requested_by_id"`
+	ApprovedByID  *string    `json:"approved_by_id" db:"approved_by_id"`
+	AcceptedByID  *string    `json:"accepted_by_id" db:"accepted_by_id"`
 	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+
+	// NilaiEstimasi is the estimated value in rupiah, summed from the items; it selects the approval chain
+	NilaiEstimasi float64 `json:"nilai_estimasi" db:"nilai_estimasi"`
+
+	// StatusSince is when the request entered its current status; the SLA clock runs from there.
+	// EscalationLevel counts the escalations since then, the last one to EscalatedToID.
+	StatusSince     time.Time  `json:"status_since" db:"status_since"`
+	EscalationLevel int        `json:"escalation_level" db:"escalation_level"`
+	EscalatedAt     *time.Time `json:"escalated_at" db:"escalated_at"`
+	EscalatedToID   *string    `json:"escalated_to_id" db:"escalated_to_id"`
+	// DueAt and SLABreached are computed from the SLA target of the current status, when it has one
+	DueAt       *time.Time `json:"due_at,omitempty" db:"-"`
+	SLABreached bool       `json:"sla_breached" db:"-"`
+
+	// Line items, loaded from request_items
+	Items []RequestItem `json:"items"`
+	// Approvals are the steps of the request's approval chain, loaded with a single request
+	Approvals []RequestApproval `json:"approvals,omitempty"`
+}
+
+// RequestSearchResult represents a request matched by full-text search. Snippet is HTML: the request text
+// is escaped and the matches are wrapped in <mark>.
+type RequestSearchResult struct {
+	Request
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// RequestSearchQuery represents the parameters of a full-text search
+type RequestSearchQuery struct {
+	Q string `form:"q" binding:"required,min=2"`
+}
+
+// RequestItem represents a single line item of a request with its own decision
+type RequestItem struct {
+	ID              int64      `json:"id" db:"id"`
+	RequestID       int64      `json:"request_id" db:"request_id"`
+	ItemNo          int        `json:"item_no" db:"item_no"`
+	NamaBarang      *string    `json:"nama_barang" db:"nama_barang"`
+	TypeModel       *string    `json:"type_model" db:"type_model"`
+	JenisPekerjaan  *string    `json:"jenis_pekerjaan" db:"jenis_pekerjaan"`
+	Lokasi          *string    `json:"lokasi" db:"lokasi"`
+	Kegunaan        *string    `json:"kegunaan" db:"kegunaan"`
+	TglPeminjaman   *time.Time `json:"tgl_peminjaman" db:"tgl_peminjaman"`
+	TglPengembalian *time.Time `json:"tgl_pengembalian" db:"tgl_pengembalian"`
+	JumlahDiminta   int        `json:"jumlah_diminta" db:"jumlah_diminta"`
+	JumlahDisetujui *int       `json:"jumlah_disetujui" db:"jumlah_disetujui"`
+	Keterangan      *string    `json:"keterangan" db:"keterangan"`
+	Catatan         *string    `json:"catatan" db:"catatan"`
+	StatusItem      string     `json:"status_item" db:"status_item"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+
+	// HargaSatuan is the estimated price of one unit in rupiah; the request's estimated value is summed
+	// from its items
+	HargaSatuan *float64 `json:"harga_satuan" db:"harga_satuan"`
+}
+
+// Approvers of approval chain steps
+const (
+	// ApproverUnitHead is the head of the request's unit, or of the nearest parent unit that has one
+	ApproverUnitHead = "unit_head"
+	// ApproverRole is any user with the step's role
+	ApproverRole = "role"
+	// ApproverUser is one named user
+	ApproverUser = "user"
+)
+
+// ApprovalChain is the sequence of approvals a request needs before it is DISETUJUI. A chain applies to
+// requests of its type whose estimated value is at least MinValue, from its unit and the units below it,
+// or from every unit when UnitID is nil.
+type ApprovalChain struct {
+	ID           int64          `json:"id" db:"id"`
+	Name         string         `json:"name" db:"name"`
+	JenisRequest string         `json:"jenis_request" db:"jenis_request"`
+	UnitID       *uuid.UUID     `json:"unit_id" db:"unit_id"`
+	MinValue     float64        `json:"min_value" db:"min_value"`
+	Steps        []ApprovalStep `json:"steps"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// ApprovalStep is one level of an approval chain
+type ApprovalStep struct {
+	StepNo         int        `json:"step_no" db:"step_no"`
+	Name           string     `json:"name" db:"name"`
+	ApproverType   string     `json:"approver_type" db:"approver_type"`
+	ApproverRole   *string    `json:"approver_role" db:"approver_role"`
+	ApproverUserID *uuid.UUID `json:"approver_user_id" db:"approver_user_id"`
+}
+
+// ApprovalChainRequest creates or replaces an approval chain with its steps, in order
+type ApprovalChainRequest struct {
+	Name         string                `json:"name" binding:"required,max=100"`
+	JenisRequest string                `json:"jenis_request" binding:"required,oneof=pengadaan perbaikan peminjaman"`
+	UnitID       string                `json:"unit_id"`
+	MinValue     float64               `json:"min_value" binding:"min=0"`
+	Steps        []ApprovalStepRequest `json:"steps" binding:"required,min=1,dive"`
+}
+
+// ApprovalStepRequest describes one step; ApproverRole is set for role steps and ApproverUserID for user steps
+type ApprovalStepRequest struct {
+	Name           string `json:"name" binding:"required,max=100"`
+	ApproverType   string `json:"approver_type" binding:"required,oneof=unit_head role user"`
+	ApproverRole   string `json:"approver_role"`
+	ApproverUserID string `json:"approver_user_id"`
+}
+
+// RequestApproval is a chain step copied onto a request when it is submitted, with its decision.
+// Decision is DIAJUKAN until the step is decided, then DISETUJUI or DITOLAK.
+type RequestApproval struct {
+	ID           int64   `json:"id" db:"id"`
+	RequestID    int64   `json:"request_id" db:"request_id"`
+	StepNo       int     `json:"step_no" db:"step_no"`
+	Name         string  `json:"name" db:"name"`
+	ApproverType string  `json:"approver_type" db:"approver_type"`
+	ApproverRole *string `json:"approver_role" db:"approver_role"`
+	// ApproverUserID is the resolved unit head or named user; a unit head step without one can be decided
+	// by anyone with request.approve
+	ApproverUserID *uuid.UUID `json:"approver_user_id" db:"approver_user_id"`
+	Decision       string     `json:"decision" db:"decision"`
+	DecidedByID    *uuid.UUID `json:"decided_by_id" db:"decided_by_id"`
+	DecidedByName  *string    `json:"decided_by_name" db:"decided_by_name"`
+	DecidedAt      *time.Time `json:"decided_at" db:"decided_at"`
+	Catatan        *string    `json:"catatan" db:"catatan"`
+	// OnBehalfOfID is the absent approver when a substitute decided the step
+	OnBehalfOfID   *uuid.UUID `json:"on_behalf_of_id,omitempty" db:"on_behalf_of"`
+	OnBehalfOfName *string    `json:"on_behalf_of_name,omitempty" db:"on_behalf_of_name"`
+}
+
+// ApprovalDecisionRequest decides the current approval step of a request
+type ApprovalDecisionRequest struct {
+	Decision string  `json:"decision" binding:"required,oneof=DISETUJUI DITOLAK"`
+	Catatan  *string `json:"catatan"`
+}
+
+// RequestStatusHistory represents one entry in the status timeline of a request
+type RequestStatusHistory struct {
+	ID            int64     `json:"id" db:"id"`
+	RequestID     int64     `json:"request_id" db:"request_id"`
+	OldStatus     *string   `json:"old_status" db:"old_status"`
+	NewStatus     string    `json:"new_status" db:"new_status"`
+	ChangedBy     *string   `json:"changed_by" db:"changed_by"`
+	ChangedByName *string   `json:"changed_by_name" db:"changed_by_name"`
+	Catatan       *string   `json:"catatan" db:"catatan"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+
+	// OnBehalfOf is set when ChangedBy acted as the substitute of another approver
+	OnBehalfOf     *string `json:"on_behalf_of,omitempty" db:"on_behalf_of"`
+	OnBehalfOfName *string `json:"on_behalf_of_name,omitempty" db:"on_behalf_of_name"`
+}
+
+// UpdateRequestItemRequest represents the decision on a single request item
+type UpdateRequestItemRequest struct {
+	StatusItem      string  `json:"status_item" binding:"required,oneof=DIAJUKAN DISETUJUI DITOLAK"`
+	JumlahDisetujui *int    `json:"jumlah_disetujui" binding:"omitempty,min=0"`
+	Catatan         *string `json:"catatan"`
 }
 
 // CreateRequestRequest represents the request to create a request
 type CreateRequestRequest struct {
 	JenisRequest string `json:"jenis_request" binding:"required,oneof=pengadaan perbaikan peminjaman"`
-	Unit         string `json:"unit" binding:"required"`
+	// The unit is given by UnitID or by name
+	UnitID string `json:"unit_id"`
+	Unit   string `json:"unit"`
+	// HargaSatuanArray gives the unit price of each pengadaan or perbaikan item, in item order; HargaSatuan
+	// is the price of a request given with the legacy single fields. Those requests need a price for every
+	// item, since the estimated value they add up to selects the approval chain.
+	HargaSatuanArray []float64 `json:"harga_satuan_array"`
+	HargaSatuan      *float64  `json:"harga_satuan"`
 
 	// For pengadaan: array fields
 	NamaBarangArray []string `json:"nama_barang_array"`
@@ -121,35 +393,214 @@ type LoginRequest struct {
 // RegisterRequest represents the registration request
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Unit     string `json:"unit" binding:"required"`
-	Role     string `json:"role" binding:"required,oneof=user operator"`
+	// The unit is given by UnitID or by name
+	UnitID string `json:"unit_id"`
+	Unit   string `json:"unit"`
+	// Role is optional; self-registered accounts always get the user role
+	Role string `json:"role" binding:"omitempty,oneof=user"`
 }
 
 // CreateUserRequest represents the request to create a user
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Unit     string `json:"unit" binding:"required"`
-	Role     string `json:"role" binding:"required,oneof=user operator"`
+	// The unit is given by UnitID or by name
+	UnitID string `json:"unit_id"`
+	Unit   string `json:"unit"`
+	Role   string `json:"role" binding:"required"`
 }
 
 // UpdateUserRequest represents the request to update a user
 type UpdateUserRequest struct {
 	Name  string `json:"name,omitempty"`
 	Email string `json:"email,omitempty"`
-	Unit  string `json:"unit,omitempty"`
-	Role  string `json:"role,omitempty" binding:"omitempty,oneof=user operator"`
+	// A new unit is given by UnitID or by name
+	UnitID string `json:"unit_id,omitempty"`
+	Unit   string `json:"unit,omitempty"`
+	Role   string `json:"role,omitempty"`
+}
+
+// Unit is an organizational unit; units form a tree through ParentID
+type Unit struct {
+	ID   uuid.UUID `json:"id" db:"id"`
+	Name string    `json:"name" db:"name"`
+	// CostCenter is the finance code requests of the unit are booked on
+	CostCenter *string    `json:"cost_center" db:"cost_center"`
+	ParentID   *uuid.UUID `json:"parent_id" db:"parent_id"`
+	// HeadUserID is the user who leads the unit
+	HeadUserID *uuid.UUID `json:"head_user_id" db:"head_user_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// UnitRequest creates or replaces a unit; empty optional fields are cleared
+type UnitRequest struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	CostCenter string `json:"cost_center" binding:"max=50"`
+	ParentID   string `json:"parent_id"`
+	HeadUserID string `json:"head_user_id"`
+}
+
+// SLATarget is the number of working days a request of a type may stay in an open status
+type SLATarget struct {
+	JenisRequest string    `json:"jenis_request" db:"jenis_request"`
+	Status       string    `json:"status" db:"status"`
+	WorkingDays  int       `json:"working_days" db:"working_days"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SLATargetRequest sets the SLA target of a request type and status
+type SLATargetRequest struct {
+	JenisRequest string `json:"jenis_request" binding:"required,oneof=pengadaan perbaikan peminjaman"`
+	Status       string `json:"status" binding:"required,oneof=DIAJUKAN DISETUJUI DIPROSES"`
+	WorkingDays  int    `json:"working_days" binding:"required,min=1,max=365"`
+}
+
+// Holiday is a public holiday or other day off; no working days are counted on it
+type Holiday struct {
+	Date      time.Time `json:"date" db:"date"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// HolidayRequest adds a holiday or renames it; Date uses YYYY-MM-DD
+type HolidayRequest struct {
+	Date string `json:"date" binding:"required"`
+	Name string `json:"name" binding:"required,max=200"`
+}
+
+// Delegation statuses, derived from the date range
+const (
+	DelegationScheduled = "scheduled"
+	DelegationActive    = "active"
+	DelegationExpired   = "expired"
+)
+
+// Delegation lets a substitute decide approvals for an absent approver from StartDate to EndDate, inclusive
+type Delegation struct {
+	ID             int64      `json:"id" db:"id"`
+	DelegatorID    uuid.UUID  `json:"delegator_id" db:"delegator_id"`
+	DelegatorName  string     `json:"delegator_name" db:"delegator_name"`
+	SubstituteID   uuid.UUID  `json:"substitute_id" db:"substitute_id"`
+	SubstituteName string     `json:"substitute_name" db:"substitute_name"`
+	StartDate      time.Time  `json:"start_date" db:"start_date"`
+	EndDate        time.Time  `json:"end_date" db:"end_date"`
+	Reason         *string    `json:"reason" db:"reason"`
+	Status         string     `json:"status" db:"-"`
+	CreatedBy      *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// DelegationRequest registers a substitute. Dates use YYYY-MM-DD; DelegatorID defaults to the current user
+// and can only name somebody else with approval.manage.
+type DelegationRequest struct {
+	DelegatorID  string `json:"delegator_id"`
+	SubstituteID string `json:"substitute_id" binding:"required"`
+	StartDate    string `json:"start_date" binding:"required"`
+	EndDate      string `json:"end_date" binding:"required"`
+	Reason       string `json:"reason" binding:"max=255"`
+}
+
+// MergeUnitRequest names the unit another unit is merged into
+type MergeUnitRequest struct {
+	Into string `json:"into" binding:"required"`
 }
 
-// AuthResponse represents the authentication response
+// ChangePasswordRequest represents a user changing their own password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPasswordRequest represents setting a new password with a one-time reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// PasswordResetToken is a one-time token an operator issues so a user can set a new password;
+// only the SHA-256 hash of the token is stored
+type PasswordResetToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	CreatedBy *string    `json:"created_by" db:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// OIDCLoginState is kept between sending the browser to the identity provider and its callback; only the
+// SHA-256 hash of the state parameter is stored
+type OIDCLoginState struct {
+	StateHash    string    `json:"-" db:"state_hash"`
+	Nonce        string    `json:"-" db:"nonce"`
+	CodeVerifier string    `json:"-" db:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// UpdateProfileRequest represents the fields users may change on their own account
+type UpdateProfileRequest struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty" binding:"omitempty,email"`
+}
+
+// LoginAttempt tracks failed logins for a username or client IP
+type LoginAttempt struct {
+	Key         string     `json:"key" db:"key"`
+	Failures    int        `json:"failures" db:"failures"`
+	LastFailure time.Time  `json:"last_failure" db:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until" db:"locked_until"`
+}
+
+// Second login steps reported in AuthResponse.TwoFactor
+const (
+	TwoFactorVerify = "verify"
+	TwoFactorEnroll = "enroll"
+)
+
+// AuthResponse represents the authentication response. When a second factor is needed only
+// ChallengeToken and TwoFactor are set, and the client finishes the login with the challenge token.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the access token lifetime in seconds
+	ExpiresIn      int64  `json:"expires_in"`
+	User           User   `json:"user"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	TwoFactor      string `json:"two_factor,omitempty"`
+}
+
+// TwoFactorChallengeRequest completes a login with a TOTP or recovery code
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorEnrollmentRequest starts enrollment during login when the user's role requires 2FA
+type TwoFactorEnrollmentRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// TwoFactorCodeRequest carries a TOTP code for confirming enrollment or disabling 2FA
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorEnrollment is returned when enrollment starts; the secret is shown once
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RefreshTokenRequest carries a refresh token for the refresh and logout endpoints
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 // PaginationRequest represents pagination parameters
@@ -158,6 +609,18 @@ type PaginationRequest struct {
 	Limit int `form:"limit" binding:"min=1,max=100"`
 }
 
+// RequestFilter represents the filters and sort order for listing requests
+type RequestFilter struct {
+	JenisRequest  string `form:"jenis_request" binding:"omitempty,oneof=pengadaan perbaikan peminjaman"`
+	Unit          string `form:"unit"`
+	UnitID        string `form:"unit_id"`
+	Status        string `form:"status" binding:"omitempty,oneof=DIAJUKAN DISETUJUI DITOLAK DIPROSES SELESAI"`
+	RequestedByID string `form:"requested_by"`
+	TglFrom       string `form:"tgl_from"`
+	TglTo         string `form:"tgl_to"`
+	Sort          string `form:"sort"`
+}
+
 // PaginationResponse represents pagination response
 type PaginationResponse struct {
 	Page       int         `json:"page"`
@@ -2,11 +2,22 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"html"
+	"strings"
 	"time"
 	"web-work-request-backend/models"
+
+	"github.com/lib/pq"
 )
 
+// ErrStatusChanged is returned when a request no longer has the status an update was based on
+var ErrStatusChanged = errors.New("request status was changed by another user")
+
+// ErrTokenRevoked is returned when a refresh or password reset token has already been revoked or used
+var ErrTokenRevoked = errors.New("token has been revoked")
+
 type Repository struct {
 	db *sql.DB
 }
@@ -15,112 +26,152 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-// UserRepository methods
-func (r *Repository) CreateUser(user *models.User) error {
-	query := `
-		INSERT INTO users (username, password_hash, name, email, unit, role)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at, updated_at`
-
-	return r.db.QueryRow(
-		query,
-		user.Username,
-		user.PasswordHash,
-		user.Name,
-		user.Email,
-		user.Unit,
-		user.Role,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
-}
+// userColumns lists the user columns in the order scanUser expects them
+const userColumns = `id, username, password_hash, name, email, unit, unit_id, role, status, token_version,
+	totp_secret, totp_enabled, totp_last_step, auth_source, external_id, deleted_at, created_at, updated_at`
 
-func (r *Repository) GetUserByUsername(username string) (*models.User, error) {
+func scanUser(row rowScanner) (*models.User, error) {
 	user := &models.User{}
-	query := `SELECT * FROM users WHERE username = $1`
-
-	err := r.db.QueryRow(query, username).Scan(
+	err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
 		&user.Name,
 		&user.Email,
 		&user.Unit,
+		&user.UnitID,
 		&user.Role,
+		&user.Status,
+		&user.TokenVersion,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastStep,
+		&user.AuthSource,
+		&user.ExternalID,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
-
 	if err != nil {
 		return nil, err
 	}
-
 	return user, nil
 }
 
-func (r *Repository) GetUserByEmail(email string) (*models.User, error) {
-	user := &models.User{}
-	query := `SELECT * FROM users WHERE email = $1`
-
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.PasswordHash,
-		&user.Name,
-		&user.Email,
-		&user.Unit,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+// UserRepository methods
+func (r *Repository) CreateUser(user *models.User) error {
+	query := `
+		INSERT INTO users (username, password_hash, name, email, unit, unit_id, role, status, auth_source, external_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at`
 
-	if err != nil {
-		return nil, err
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+	}
+	if user.AuthSource == "" {
+		user.AuthSource = models.AuthSourceLocal
 	}
 
-	return user, nil
+	return r.db.QueryRow(
+		query,
+		user.Username,
+		user.PasswordHash,
+		user.Name,
+		user.Email,
+		user.Unit,
+		user.UnitID,
+		user.Role,
+		user.Status,
+		user.AuthSource,
+		user.ExternalID,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+}
+
+func (r *Repository) GetUserByUsername(username string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+	return scanUser(r.db.QueryRow(query, username))
+}
+
+// GetUserByExternalID finds a single sign-on account by its identity provider subject
+func (r *Repository) GetUserByExternalID(authSource, externalID string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE auth_source = $1 AND external_id = $2`
+	return scanUser(r.db.QueryRow(query, authSource, externalID))
+}
+
+func (r *Repository) GetUserByEmail(email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+	return scanUser(r.db.QueryRow(query, email))
 }
 
 func (r *Repository) UpdateUser(user *models.User) error {
 	query := `
 		UPDATE users 
-		SET name = $1, email = $2, unit = $3, role = $4, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $5`
+		SET name = $1, email = $2, unit = $3, unit_id = $4, role = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6`
 
-	_, err := r.db.Exec(query, user.Name, user.Email, user.Unit, user.Role, user.ID)
+	_, err := r.db.Exec(query, user.Name, user.Email, user.Unit, user.UnitID, user.Role, user.ID)
 	return err
 }
 
+// DeleteUser marks a user deleted and revokes their sessions; the row stays so their requests keep their
+// history. It reports sql.ErrNoRows when the user is already deleted.
 func (r *Repository) DeleteUser(id string) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
-}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-func (r *Repository) GetUserByID(id string) (*models.User, error) {
-	user := &models.User{}
-	query := `SELECT * FROM users WHERE id = $1`
+	result, err := tx.Exec(`
+		UPDATE users
+		SET status = $1, deleted_at = CURRENT_TIMESTAMP, token_version = token_version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status <> $1`, models.UserStatusDeleted, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
 
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.PasswordHash,
-		&user.Name,
-		&user.Email,
-		&user.Unit,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
+// RestoreUser makes a deleted user active again and reports sql.ErrNoRows when the user is not deleted
+func (r *Repository) RestoreUser(id string) error {
+	result, err := r.db.Exec(`
+		UPDATE users SET status = $1, deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3`, models.UserStatusActive, id, models.UserStatusDeleted)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return user, nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
-func (r *Repository) GetAllUsers() ([]models.User, error) {
-	query := `SELECT * FROM users ORDER BY created_at DESC`
-	rows, err := r.db.Query(query)
+func (r *Repository) GetUserByID(id string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.db.QueryRow(query, id))
+}
+
+// GetAllUsers lists users newest first; deleted users are left out unless includeDeleted is set
+func (r *Repository) GetAllUsers(includeDeleted bool) ([]models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE $1 OR status <> $2 ORDER BY created_at DESC`
+	rows, err := r.db.Query(query, includeDeleted, models.UserStatusDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -128,251 +179,2246 @@ func (r *Repository) GetAllUsers() ([]models.User, error) {
 
 	var users []models.User
 	for rows.Next() {
-		var user models.User
-		err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.PasswordHash,
-			&user.Name,
-			&user.Email,
-			&user.Unit,
-			&user.Role,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, user)
+		users = append(users, *user)
 	}
 
 	return users, nil
 }
 
-// RequestRepository methods
-func (r *Repository) CreateRequest(request *models.Request) error {
-	query := `
-		INSERT INTO request (
-			jenis_request, unit, nama_barang, type_model, jumlah, lokasi, 
-			jenis_pekerjaan, kegunaan, tgl_request, tgl_peminjaman, 
-			tgl_pengembalian, keterangan, status_request, requested_by
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
-		RETURNING id, created_at, updated_at`
-
-	// Parse dates
-	tglRequest, _ := time.Parse("2006-01-02", request.TglRequest.Format("2006-01-02"))
+// GetUsersByStatus returns the users with a status, oldest first so a queue is worked in order
+func (r *Repository) GetUsersByStatus(status string) ([]models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE status = $1 ORDER BY created_at`
+	rows, err := r.db.Query(query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	var tglPeminjaman *time.Time
-	if request.TglPeminjaman != nil {
-		parsed, _ := time.Parse("2006-01-02", request.TglPeminjaman.Format("2006-01-02"))
-		tglPeminjaman = &parsed
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
 	}
 
-	var tglPengembalian *time.Time
-	if request.TglPengembalian != nil {
-		parsed, _ := time.Parse("2006-01-02", request.TglPengembalian.Format("2006-01-02"))
-		tglPengembalian = &parsed
+	return users, rows.Err()
+}
+
+// SetUserStatus moves a user from one status to another and reports sql.ErrNoRows when the user
+// does not have the expected status, so two operators cannot decide the same account twice
+func (r *Repository) SetUserStatus(id, from, to string) error {
+	result, err := r.db.Exec(`
+		UPDATE users SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3`, to, id, from)
+	if err != nil {
+		return err
 	}
 
-	return r.db.QueryRow(
-		query,
-		request.JenisRequest,
-		request.Unit,
-		request.NamaBarang,
-		request.TypeModel,
-		request.Jumlah,
-		request.Lokasi,
-		request.JenisPekerjaan,
-		request.Kegunaan,
-		tglRequest,
-		tglPeminjaman,
-		tglPengembalian,
-		request.Keterangan,
-		request.StatusRequest,
-		request.RequestedBy,
-	).Scan(&request.ID, &request.CreatedAt, &request.UpdatedAt)
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
-func (r *Repository) GetRequestByID(id string) (*models.Request, error) {
-	// Get request
-	requestQuery := `SELECT * FROM request WHERE id = $1`
-	request := &models.Request{}
+// DeleteUserWithStatus removes a user only while it has the given status
+func (r *Repository) DeleteUserWithStatus(id, status string) error {
+	result, err := r.db.Exec(`DELETE FROM users WHERE id = $1 AND status = $2`, id, status)
+	if err != nil {
+		return err
+	}
 
-	err := r.db.QueryRow(requestQuery, id).Scan(
-		&request.ID,
-		&request.JenisRequest,
-		&request.Unit,
-		&request.NamaBarang,
-		&request.TypeModel,
-		&request.Jumlah,
-		&request.Lokasi,
-		&request.JenisPekerjaan,
-		&request.Kegunaan,
-		&request.TglRequest,
-		&request.TglPeminjaman,
-		&request.TglPengembalian,
-		&request.Keterangan,
-		&request.StatusRequest,
-		&request.RequestedBy,
-		&request.ApprovedBy,
-		&request.AcceptedBy,
-		&request.CreatedAt,
-		&request.UpdatedAt,
-	)
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
 
+// RevokeUserSessions invalidates every access and refresh token issued to a user
+func (r *Repository) RevokeUserSessions(userID string) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer tx.Rollback()
 
-	return request, nil
+	if _, err := tx.Exec(`UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *Repository) GetAllRequests() ([]models.Request, error) {
-	query := `SELECT * FROM request ORDER BY created_at DESC`
-	rows, err := r.db.Query(query)
+// ChangePassword stores a new password hash, keeps the previous one in the password history (trimmed to
+// historySize entries) and revokes every session of the user
+func (r *Repository) ChangePassword(userID string, newHash string, historySize int) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var requests []models.Request
-	for rows.Next() {
-		var request models.Request
-		err := rows.Scan(
-			&request.ID,
-			&request.JenisRequest,
-			&request.Unit,
-			&request.NamaBarang,
-			&request.TypeModel,
-			&request.Jumlah,
-			&request.Lokasi,
-			&request.JenisPekerjaan,
-			&request.Kegunaan,
-			&request.TglRequest,
-			&request.TglPeminjaman,
-			&request.TglPengembalian,
-			&request.Keterangan,
-			&request.StatusRequest,
-			&request.RequestedBy,
-			&request.ApprovedBy,
-			&request.AcceptedBy,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
+	_, err = tx.Exec(`
+		INSERT INTO password_history (user_id, password_hash)
+		SELECT id, password_hash FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2
+		)`, userID, historySize)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET password_hash = $1, token_version = token_version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2`, newHash, userID)
+	if err != nil {
+		return err
+	}
 
-		requests = append(requests, request)
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
 	}
 
-	return requests, nil
+	return tx.Commit()
 }
 
-func (r *Repository) GetRequestsByStatus(status string) ([]models.Request, error) {
-	query := `SELECT * FROM request WHERE status_request = $1 ORDER BY created_at DESC`
-	rows, err := r.db.Query(query, status)
+// GetPasswordHistory returns the most recent previous password hashes of a user
+func (r *Repository) GetPasswordHistory(userID string, limit int) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`, userID, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var requests []models.Request
+	var hashes []string
 	for rows.Next() {
-		var request models.Request
-		err := rows.Scan(
-			&request.ID,
-			&request.JenisRequest,
-			&request.Unit,
-			&request.NamaBarang,
-			&request.TypeModel,
-			&request.Jumlah,
-			&request.Lokasi,
-			&request.JenisPekerjaan,
-			&request.Kegunaan,
-			&request.TglRequest,
-			&request.TglPeminjaman,
-			&request.TglPengembalian,
-			&request.Keterangan,
-			&request.StatusRequest,
-			&request.RequestedBy,
-			&request.ApprovedBy,
-			&request.AcceptedBy,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-		if err != nil {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
 			return nil, err
 		}
-
-		requests = append(requests, request)
+		hashes = append(hashes, hash)
 	}
-
-	return requests, nil
+	return hashes, rows.Err()
 }
 
-func (r *Repository) UpdateRequestStatus(id string, status string, approvedBy *string, acceptedBy *string, keterangan string) error {
-	query := `
-		UPDATE request 
-		SET status_request = $1, approved_by = $2, accepted_by = $3, keterangan = $4, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $5`
+// OIDCLoginStateRepository methods
 
-	result, err := r.db.Exec(query, status, approvedBy, acceptedBy, keterangan, id)
-	if err != nil {
+// CreateOIDCLoginState stores a pending single sign-on login and removes expired ones
+func (r *Repository) CreateOIDCLoginState(state *models.OIDCLoginState) error {
+	if _, err := r.db.Exec(`DELETE FROM oidc_login_states WHERE expires_at < NOW()`); err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	_, err := r.db.Exec(`
+		INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		state.StateHash, state.Nonce, state.CodeVerifier, state.ExpiresAt)
+	return err
+}
+
+// TakeOIDCLoginState removes and returns a pending login, so each state can complete at most one login
+func (r *Repository) TakeOIDCLoginState(stateHash string) (*models.OIDCLoginState, error) {
+	state := &models.OIDCLoginState{StateHash: stateHash}
+	err := r.db.QueryRow(`
+		DELETE FROM oidc_login_states WHERE state_hash = $1
+		RETURNING nonce, code_verifier, expires_at`, stateHash,
+	).Scan(&state.Nonce, &state.CodeVerifier, &state.ExpiresAt)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return state, nil
+}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("request not found")
-	}
+// PasswordResetTokenRepository methods
+func (r *Repository) CreatePasswordResetToken(token *models.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, created_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
 
-	return nil
+	return r.db.QueryRow(query, token.UserID, token.TokenHash, token.CreatedBy, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
 }
 
-func (r *Repository) DeleteRequest(id string) error {
-	query := `DELETE FROM request WHERE id = $1`
+func (r *Repository) GetPasswordResetTokenByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	token := &models.PasswordResetToken{}
+	err := r.db.QueryRow(`
+		SELECT id, user_id, token_hash, created_by, expires_at, used_at, created_at
+		FROM password_reset_tokens WHERE token_hash = $1`, tokenHash,
+	).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.CreatedBy, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
 
-	result, err := r.db.Exec(query, id)
+// UsePasswordResetToken marks a token as used; it returns ErrTokenRevoked when it was already used
+func (r *Repository) UsePasswordResetToken(id string) error {
+	result, err := r.db.Exec(`UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL`, id)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("request not found")
+	if affected == 0 {
+		return ErrTokenRevoked
 	}
-
 	return nil
 }
 
-// Dashboard repository methods
-func (r *Repository) GetRequestCount() (int, error) {
+// DeleteUserPasswordResetTokens removes outstanding reset tokens when a new one is issued
+func (r *Repository) DeleteUserPasswordResetTokens(userID string) error {
+	_, err := r.db.Exec(`DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID)
+	return err
+}
+
+// TwoFactorRepository methods
+
+// SetPendingTOTPSecret stores a secret for an enrollment that has not been confirmed yet
+func (r *Repository) SetPendingTOTPSecret(userID, secret string) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET totp_secret = $1, totp_enabled = FALSE, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2`, secret, userID)
+	return err
+}
+
+// EnableTOTP confirms enrollment and replaces the user's recovery codes
+func (r *Repository) EnableTOTP(userID string, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET totp_enabled = TRUE, totp_last_step = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2`, step, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// AdvanceTOTPStep records the time step of an accepted code; it reports false when the step was
+// already used, so every code works only once
+func (r *Repository) AdvanceTOTPStep(userID string, step int64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UseRecoveryCode marks an unused recovery code as used and reports whether one matched
+func (r *Repository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE totp_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UseLoginChallenge marks a two-factor challenge as used and reports whether it was unused until now
+func (r *Repository) UseLoginChallenge(id string, expiresAt time.Time) (bool, error) {
+	if _, err := r.db.Exec(`DELETE FROM used_login_challenges WHERE expires_at < NOW()`); err != nil {
+		return false, err
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO used_login_challenges (id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (id) DO NOTHING`, id, expiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// LoginChallengeUsed reports whether a two-factor challenge has already completed a login
+func (r *Repository) LoginChallengeUsed(id string) (bool, error) {
+	var used bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM used_login_challenges WHERE id = $1)`, id).Scan(&used)
+	return used, err
+}
+
+// DisableTOTP removes the user's second factor and recovery codes
+func (r *Repository) DisableTOTP(userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshTokenRepository methods
+const refreshTokenColumns = `id, user_id, token_hash, expires_at, revoked_at, replaced_by, created_at`
+
+func scanRefreshToken(row rowScanner) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.ReplacedBy,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *Repository) CreateRefreshToken(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (r *Repository) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+	return scanRefreshToken(r.db.QueryRow(query, tokenHash))
+}
+
+// RotateRefreshToken revokes a refresh token and stores its replacement in one transaction.
+// It returns ErrTokenRevoked when the old token was already used, so a token can only be rotated once.
+func (r *Repository) RotateRefreshToken(oldID string, replacement *models.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		replacement.UserID, replacement.TokenHash, replacement.ExpiresAt,
+	).Scan(&replacement.ID, &replacement.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $1
+		WHERE id = $2 AND revoked_at IS NULL`,
+		replacement.ID, oldID,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTokenRevoked
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshToken revokes a single refresh token; revoking an unknown or already revoked token is not an error
+func (r *Repository) RevokeRefreshToken(tokenHash string) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE token_hash = $1 AND revoked_at IS NULL`, tokenHash)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every active refresh token of a user
+func (r *Repository) RevokeUserRefreshTokens(userID string) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// LoginAttemptRepository methods; Repository implements throttle.Store
+func (r *Repository) GetLoginAttempt(key string) (*models.LoginAttempt, error) {
+	attempt := &models.LoginAttempt{}
+	err := r.db.QueryRow(
+		`SELECT key, failures, last_failure, locked_until FROM login_attempts WHERE key = $1`, key,
+	).Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailure, &attempt.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// RecordLoginFailure increments the counter atomically so concurrent replicas never lose a failure
+func (r *Repository) RecordLoginFailure(key string, at time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure < $2 - make_interval(secs => $3) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure = $2
+		RETURNING key, failures, last_failure, locked_until`
+
+	attempt := &models.LoginAttempt{}
+	err := r.db.QueryRow(query, key, at, window.Seconds()).Scan(
+		&attempt.Key, &attempt.Failures, &attempt.LastFailure, &attempt.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (r *Repository) LockLoginAttempt(key string, until time.Time) error {
+	_, err := r.db.Exec(`UPDATE login_attempts SET locked_until = $1 WHERE key = $2`, until, key)
+	return err
+}
+
+func (r *Repository) ResetLoginAttempts(key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+// requestColumns lists the request columns in the order scanRequest expects them
+const requestColumns = `
+	id, jenis_request, unit, unit_id,
+	nama_barang_array, type_model_array, jumlah_array, keterangan_array,
+	nama_barang_perbaikan_array, type_model_perbaikan_array, jumlah_perbaikan_array,
+	jenis_pekerjaan_array, lokasi_perbaikan_array,
+	lokasi_peminjaman_array, kegunaan_array, tgl_peminjaman_array, tgl_pengembalian_array,
+	nama_barang, type_model, jumlah, lokasi, jenis_pekerjaan, kegunaan,
+	tgl_request, tgl_peminjaman, tgl_pengembalian, keterangan,
+	status_request, requested_by, approved_by, accepted_by,
+	requested_by_id, approved_by_id, accepted_by_id, nilai_estimasi, created_at, updated_at,
+	status_since, escalation_level, escalated_at, escalated_to_id`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRequest(row rowScanner) (*models.Request, error) {
+	request := &models.Request{}
+	var jumlahArray, jumlahPerbaikanArray pq.Int64Array
+	var tglPeminjamanArray, tglPengembalianArray pq.StringArray
+
+	err := row.Scan(
+		&request.ID,
+		&request.JenisRequest,
+		&request.Unit,
+		&request.UnitID,
+		pq.Array(&request.NamaBarangArray),
+		pq.Array(&request.TypeModelArray),
+		&jumlahArray,
+		pq.Array(&request.KeteranganArray),
+		pq.Array(&request.NamaBarangPerbaikanArray),
+		pq.Array(&request.TypeModelPerbaikanArray),
+		&jumlahPerbaikanArray,
+		pq.Array(&request.JenisPekerjaanArray),
+		pq.Array(&request.LokasiPerbaikanArray),
+		pq.Array(&request.LokasiPeminjamanArray),
+		pq.Array(&request.KegunaanArray),
+		&tglPeminjamanArray,
+		&tglPengembalianArray,
+		&request.NamaBarang,
+		&request.TypeModel,
+		&request.Jumlah,
+		&request.Lokasi,
+		&request.JenisPekerjaan,
+		&request.Kegunaan,
+		&request.TglRequest,
+		&request.TglPeminjaman,
+		&request.TglPengembalian,
+		&request.Keterangan,
+		&request.StatusRequest,
+		&request.RequestedBy,
+		&request.ApprovedBy,
+		&request.AcceptedBy,
+		&request.RequestedByID,
+		&request.ApprovedByID,
+		&request.AcceptedByID,
+		&request.NilaiEstimasi,
+		&request.CreatedAt,
+		&request.UpdatedAt,
+		&request.StatusSince,
+		&request.EscalationLevel,
+		&request.EscalatedAt,
+		&request.EscalatedToID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	request.JumlahArray = toIntSlice(jumlahArray)
+	request.JumlahPerbaikanArray = toIntSlice(jumlahPerbaikanArray)
+
+	if request.TglPeminjamanArray, err = parseDateArray(tglPeminjamanArray); err != nil {
+		return nil, err
+	}
+	if request.TglPengembalianArray, err = parseDateArray(tglPengembalianArray); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func scanRequests(rows *sql.Rows) ([]models.Request, error) {
+	var requests []models.Request
+	for rows.Next() {
+		request, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+
+	return requests, rows.Err()
+}
+
+func toInt64Array(values []int) pq.Int64Array {
+	if values == nil {
+		return nil
+	}
+	result := make(pq.Int64Array, len(values))
+	for i, v := range values {
+		result[i] = int64(v)
+	}
+	return result
+}
+
+func toIntSlice(values pq.Int64Array) []int {
+	if values == nil {
+		return nil
+	}
+	result := make([]int, len(values))
+	for i, v := range values {
+		result[i] = int(v)
+	}
+	return result
+}
+
+func formatDateArray(values []time.Time) pq.StringArray {
+	if values == nil {
+		return nil
+	}
+	result := make(pq.StringArray, len(values))
+	for i, v := range values {
+		result[i] = v.Format("2006-01-02")
+	}
+	return result
+}
+
+// parseDateArray accepts both DATE[] and the TIMESTAMP[] columns created by older migration scripts
+func parseDateArray(values pq.StringArray) ([]time.Time, error) {
+	if values == nil {
+		return nil, nil
+	}
+	result := make([]time.Time, len(values))
+	for i, v := range values {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			parsed, err = time.Parse("2006-01-02 15:04:05", v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date in array: %v", err)
+			}
+		}
+		result[i] = parsed
+	}
+	return result, nil
+}
+
+// Role and permission repository methods
+
+// GetRolePermissions returns the permissions granted to a role
+func (r *Repository) GetRolePermissions(role string) ([]string, error) {
+	rows, err := r.db.Query(`SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission`, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// GetRoles returns every role together with its permissions
+func (r *Repository) GetRoles() ([]models.Role, error) {
+	query := `
+		SELECT r.name, r.description, COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+		GROUP BY r.name, r.description
+		ORDER BY r.name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.Name, &role.Description, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// RoleExists reports whether a role with the given name is defined
+func (r *Repository) RoleExists(role string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, role).Scan(&exists)
+	return exists, err
+}
+
+// GetPermissions returns every known permission
+func (r *Repository) GetPermissions() ([]models.Permission, error) {
+	rows, err := r.db.Query(`SELECT name, description FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []models.Permission{}
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// UpsertRole creates or updates a role and replaces its permissions
+func (r *Repository) UpsertRole(role *models.Role) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO roles (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description`,
+		role.Name, role.Description)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = $1`, role.Name); err != nil {
+		return err
+	}
+
+	for _, permission := range role.Permissions {
+		if _, err := tx.Exec(`INSERT INTO role_permissions (role, permission) VALUES ($1, $2)`, role.Name, permission); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RequestRepository methods
+
+// CreateRequest inserts a request together with its items and the initial history entry
+func (r *Repository) CreateRequest(request *models.Request, actorID string) error {
+	query := `
+		INSERT INTO request (
+			jenis_request, unit, nama_barang, type_model, jumlah, lokasi, 
+			jenis_pekerjaan, kegunaan, tgl_request, tgl_peminjaman, 
+			tgl_pengembalian, keterangan, status_request, requested_by, requested_by_id,
+			nama_barang_array, type_model_array, jumlah_array, keterangan_array,
+			nama_barang_perbaikan_array, type_model_perbaikan_array, jumlah_perbaikan_array,
+			jenis_pekerjaan_array, lokasi_perbaikan_array,
+			lokasi_peminjaman_array, kegunaan_array, tgl_peminjaman_array, tgl_pengembalian_array, unit_id,
+			nilai_estimasi
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
+			$16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30)
+		RETURNING id, created_at, updated_at`
+
+	// Parse dates
+	tglRequest, _ := time.Parse("2006-01-02", request.TglRequest.Format("2006-01-02"))
+
+	var tglPeminjaman *time.Time
+	if request.TglPeminjaman != nil {
+		parsed, _ := time.Parse("2006-01-02", request.TglPeminjaman.Format("2006-01-02"))
+		tglPeminjaman = &parsed
+	}
+
+	var tglPengembalian *time.Time
+	if request.TglPengembalian != nil {
+		parsed, _ := time.Parse("2006-01-02", request.TglPengembalian.Format("2006-01-02"))
+		tglPengembalian = &parsed
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		query,
+		request.JenisRequest,
+		request.Unit,
+		request.NamaBarang,
+		request.TypeModel,
+		request.Jumlah,
+		request.Lokasi,
+		request.JenisPekerjaan,
+		request.Kegunaan,
+		tglRequest,
+		tglPeminjaman,
+		tglPengembalian,
+		request.Keterangan,
+		request.StatusRequest,
+		request.RequestedBy,
+		request.RequestedByID,
+		pq.Array(request.NamaBarangArray),
+		pq.Array(request.TypeModelArray),
+		toInt64Array(request.JumlahArray),
+		pq.Array(request.KeteranganArray),
+		pq.Array(request.NamaBarangPerbaikanArray),
+		pq.Array(request.TypeModelPerbaikanArray),
+		toInt64Array(request.JumlahPerbaikanArray),
+		pq.Array(request.JenisPekerjaanArray),
+		pq.Array(request.LokasiPerbaikanArray),
+		pq.Array(request.LokasiPeminjamanArray),
+		pq.Array(request.KegunaanArray),
+		formatDateArray(request.TglPeminjamanArray),
+		formatDateArray(request.TglPengembalianArray),
+		request.UnitID,
+		request.NilaiEstimasi,
+	).Scan(&request.ID, &request.CreatedAt, &request.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertRequestItems(tx, request.ID, request.Items); err != nil {
+		return err
+	}
+	if err := insertRequestApprovals(tx, request.ID, request.Approvals); err != nil {
+		return err
+	}
+
+	// Start the status timeline with the submission itself
+	entry := &models.RequestStatusHistory{
+		RequestID: request.ID,
+		NewStatus: request.StatusRequest,
+		ChangedBy: nullableString(actorID),
+	}
+	if err := insertStatusHistory(tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertRequestItems(tx *sql.Tx, requestID int64, items []models.RequestItem) error {
+	query := `
+		INSERT INTO request_items (
+			request_id, item_no, nama_barang, type_model, jenis_pekerjaan, lokasi, kegunaan,
+			tgl_peminjaman, tgl_pengembalian, jumlah_diminta, keterangan, status_item, harga_satuan
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`
+
+	for i := range items {
+		item := &items[i]
+		item.RequestID = requestID
+		err := tx.QueryRow(
+			query,
+			item.RequestID,
+			item.ItemNo,
+			item.NamaBarang,
+			item.TypeModel,
+			item.JenisPekerjaan,
+			item.Lokasi,
+			item.Kegunaan,
+			item.TglPeminjaman,
+			item.TglPengembalian,
+			item.JumlahDiminta,
+			item.Keterangan,
+			item.StatusItem,
+			item.HargaSatuan,
+		).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// requestItemColumns lists the request_items columns in the order scanRequestItem expects them
+const requestItemColumns = `
+	id, request_id, item_no, nama_barang, type_model, jenis_pekerjaan, lokasi, kegunaan,
+	tgl_peminjaman, tgl_pengembalian, jumlah_diminta, jumlah_disetujui, keterangan, catatan,
+	status_item, created_at, updated_at, harga_satuan`
+
+func scanRequestItem(row rowScanner) (*models.RequestItem, error) {
+	item := &models.RequestItem{}
+	err := row.Scan(
+		&item.ID,
+		&item.RequestID,
+		&item.ItemNo,
+		&item.NamaBarang,
+		&item.TypeModel,
+		&item.JenisPekerjaan,
+		&item.Lokasi,
+		&item.Kegunaan,
+		&item.TglPeminjaman,
+		&item.TglPengembalian,
+		&item.JumlahDiminta,
+		&item.JumlahDisetujui,
+		&item.Keterangan,
+		&item.Catatan,
+		&item.StatusItem,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.HargaSatuan,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// GetRequestItems returns the line items of a request in item order
+func (r *Repository) GetRequestItems(requestID string) ([]models.RequestItem, error) {
+	query := `SELECT ` + requestItemColumns + ` FROM request_items WHERE request_id = $1 ORDER BY item_no`
+	rows, err := r.db.Query(query, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.RequestItem{}
+	for rows.Next() {
+		item, err := scanRequestItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return items, rows.Err()
+}
+
+// attachRequestItems loads the items of all given requests with a single query
+func (r *Repository) attachRequestItems(requests []models.Request) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(requests))
+	index := make(map[int64]int, len(requests))
+	for i := range requests {
+		ids[i] = requests[i].ID
+		index[requests[i].ID] = i
+		requests[i].Items = []models.RequestItem{}
+	}
+
+	query := `SELECT ` + requestItemColumns + ` FROM request_items WHERE request_id = ANY($1) ORDER BY request_id, item_no`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanRequestItem(rows)
+		if err != nil {
+			return err
+		}
+		i := index[item.RequestID]
+		requests[i].Items = append(requests[i].Items, *item)
+	}
+
+	return rows.Err()
+}
+
+// GetRequestItem returns a single item belonging to the given request
+func (r *Repository) GetRequestItem(requestID string, itemID string) (*models.RequestItem, error) {
+	query := `SELECT ` + requestItemColumns + ` FROM request_items WHERE request_id = $1 AND id = $2`
+	return scanRequestItem(r.db.QueryRow(query, requestID, itemID))
+}
+
+// UpdateRequestItem stores the decision on a single item
+func (r *Repository) UpdateRequestItem(item *models.RequestItem) error {
+	query := `
+		UPDATE request_items
+		SET status_item = $1, jumlah_disetujui = $2, catatan = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND request_id = $5
+		RETURNING updated_at`
+
+	err := r.db.QueryRow(query, item.StatusItem, item.JumlahDisetujui, item.Catatan, item.ID, item.RequestID).Scan(&item.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("request item not found")
+	}
+	return err
+}
+
+func (r *Repository) GetRequestByID(id string) (*models.Request, error) {
+	query := `SELECT ` + requestColumns + ` FROM request WHERE id = $1`
+	request, err := scanRequest(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Items, err = r.GetRequestItems(id)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Approvals, err = r.GetRequestApprovals(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// GetRequestsByRequesterID returns the requests submitted by the given user
+func (r *Repository) GetRequestsByRequesterID(userID string) ([]models.Request, error) {
+	query := `SELECT ` + requestColumns + ` FROM request WHERE requested_by_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests, err := scanRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return requests, r.attachRequestItems(requests)
+}
+
+// requestSortColumns maps the allowed sort keys to their ORDER BY expressions
+var requestSortColumns = map[string]string{
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+	"tgl_request":   "tgl_request",
+	"jenis_request": "jenis_request",
+	"unit":          "unit",
+	"status":        "status_request",
+	"id":            "id",
+}
+
+// IsValidRequestSort reports whether sort is an allowed sort key, optionally prefixed with "-" for descending order
+func IsValidRequestSort(sort string) bool {
+	_, ok := requestSortColumns[strings.TrimPrefix(sort, "-")]
+	return sort == "" || ok
+}
+
+func requestOrderBy(sort string) string {
+	if sort == "" {
+		sort = "-created_at"
+	}
+
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+		sort = strings.TrimPrefix(sort, "-")
+	}
+
+	column, ok := requestSortColumns[sort]
+	if !ok {
+		column = "created_at"
+	}
+
+	// id keeps the order stable between pages when the sort column has duplicates
+	return fmt.Sprintf(" ORDER BY %s %s NULLS LAST, id %s", column, direction, direction)
+}
+
+// RequestScope limits the requests a query may return to those of one requester or unit.
+// A nil scope means every request is visible.
+type RequestScope struct {
+	RequesterID string
+	UnitID      string
+}
+
+// requestWhere builds the WHERE clause for a request filter and scope, numbering placeholders from 1
+func requestWhere(filter *models.RequestFilter, scope *RequestScope) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if scope != nil {
+		if scope.UnitID != "" {
+			args = append(args, scope.RequesterID, scope.UnitID)
+			conditions = append(conditions, fmt.Sprintf("(requested_by_id = $%d OR unit_id = $%d)", len(args)-1, len(args)))
+		} else {
+			add("requested_by_id = $%d", scope.RequesterID)
+		}
+	}
+
+	if filter.JenisRequest != "" {
+		add("jenis_request = $%d", filter.JenisRequest)
+	}
+	if filter.Unit != "" {
+		add("LOWER(unit) = LOWER($%d)", filter.Unit)
+	}
+	if filter.UnitID != "" {
+		add("unit_id = $%d", filter.UnitID)
+	}
+	if filter.Status != "" {
+		add("status_request = $%d", filter.Status)
+	}
+	if filter.RequestedByID != "" {
+		add("requested_by_id = $%d", filter.RequestedByID)
+	}
+	if filter.TglFrom != "" {
+		add("tgl_request >= $%d", filter.TglFrom)
+	}
+	if filter.TglTo != "" {
+		add("tgl_request <= $%d", filter.TglTo)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetRequests returns one page of requests matching the filter together with the total number of matches
+func (r *Repository) GetRequests(filter *models.RequestFilter, scope *RequestScope, limit int, offset int) ([]models.Request, int64, error) {
+	where, args := requestWhere(filter, scope)
+
+	var total int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM request`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + requestColumns + ` FROM request` + where + requestOrderBy(filter.Sort) +
+		fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	requests, err := scanRequests(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if requests == nil {
+		requests = []models.Request{}
+	}
+
+	return requests, total, r.attachRequestItems(requests)
+}
+
+// SearchRequests runs a ranked full-text search over requests, their items and the requester name,
+// limited to the given scope
+func (r *Repository) SearchRequests(q string, scope *RequestScope, limit int, offset int) ([]models.RequestSearchResult, int64, error) {
+	where, args := requestWhere(&models.RequestFilter{}, scope)
+	args = append(args, q)
+	match := fmt.Sprintf("search_vector @@ websearch_to_tsquery('simple', $%d)", len(args))
+	if where == "" {
+		where = " WHERE " + match
+	} else {
+		where += " AND " + match
+	}
+
+	var total int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM request`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// The headline marks matches with snippetStart and snippetStop, which are removed from the text first so
+	// that HighlightSnippet can tell them apart from what requesters typed
+	queryArg := len(args)
+	query := `SELECT ` + requestColumns + fmt.Sprintf(`,
+			ts_rank(search_vector, websearch_to_tsquery('simple', $%[1]d)) AS rank,
+			ts_headline('simple', translate(COALESCE(search_text, ''), '%[2]s%[3]s', ''),
+				websearch_to_tsquery('simple', $%[1]d),
+				'StartSel=%[2]s, StopSel=%[3]s, MaxFragments=2, MaxWords=15, MinWords=5')
+		FROM request`, queryArg, snippetStart, snippetStop) + where +
+		fmt.Sprintf(" ORDER BY rank DESC, created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []models.RequestSearchResult{}
+	requests := []models.Request{}
+	for rows.Next() {
+		var result models.RequestSearchResult
+		request, err := scanRequest(extraColumns{rows, []interface{}{&result.Rank, &result.Snippet}})
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, result)
+		requests = append(requests, *request)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.attachRequestItems(requests); err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		results[i].Request = requests[i]
+		results[i].Snippet = HighlightSnippet(results[i].Snippet)
+	}
+
+	return results, total, nil
+}
+
+// Markers ts_headline puts around search matches; private-use characters never appear in the HTML that
+// HighlightSnippet produces from them
+const (
+	snippetStart = "\uE000"
+	snippetStop  = "\uE001"
+)
+
+// HighlightSnippet turns a search headline into HTML: the request text is escaped and only the matches are
+// wrapped in <mark>
+func HighlightSnippet(headline string) string {
+	var b strings.Builder
+	open := false
+	for _, r := range headline {
+		switch string(r) {
+		case snippetStart:
+			if !open {
+				b.WriteString("<mark>")
+				open = true
+			}
+		case snippetStop:
+			if open {
+				b.WriteString("</mark>")
+				open = false
+			}
+		default:
+			b.WriteString(html.EscapeString(string(r)))
+		}
+	}
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
+
+// extraColumns scans additional trailing columns after the ones requested by the wrapped scan
+type extraColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
+
+// restartSLAClock is set by every status change: the SLA of the new status runs from now
+const restartSLAClock = `status_since = CURRENT_TIMESTAMP, escalation_level = 0, escalated_at = NULL, escalated_to_id = NULL`
+
+// StatusUpdate describes a status change applied by UpdateRequestStatus. The approver and acceptor are
+// written as given, so nil clears them.
+type StatusUpdate struct {
+	RequestID     string
+	CurrentStatus string
+	NewStatus     string
+	ApprovedBy    *string
+	ApprovedByID  *string
+	AcceptedBy    *string
+	AcceptedByID  *string
+	Keterangan    string
+	ActorID       string
+}
+
+// UpdateRequestStatus changes the status of a request only if it is still in CurrentStatus,
+// so two concurrent updates cannot both apply a transition from the same state.
+// The change is recorded in request_status_history in the same transaction.
+func (r *Repository) UpdateRequestStatus(update *StatusUpdate) error {
+	query := `
+		UPDATE request 
+		SET status_request = $1,
+			approved_by = $2,
+			approved_by_id = $3,
+			accepted_by = $4,
+			accepted_by_id = $5,
+			keterangan = $6,
+			updated_at = CURRENT_TIMESTAMP,
+			` + restartSLAClock + `
+		WHERE id = $7 AND status_request = $8
+		RETURNING id`
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var requestID int64
+	err = tx.QueryRow(
+		query,
+		update.NewStatus,
+		update.ApprovedBy,
+		update.ApprovedByID,
+		update.AcceptedBy,
+		update.AcceptedByID,
+		update.Keterangan,
+		update.RequestID,
+		update.CurrentStatus,
+	).Scan(&requestID)
+	if err == sql.ErrNoRows {
+		return ErrStatusChanged
+	}
+	if err != nil {
+		return err
+	}
+
+	entry := &models.RequestStatusHistory{
+		RequestID: requestID,
+		OldStatus: &update.CurrentStatus,
+		NewStatus: update.NewStatus,
+		ChangedBy: nullableString(update.ActorID),
+		Catatan:   nullableString(update.Keterangan),
+	}
+	if err := insertStatusHistory(tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertStatusHistory(tx *sql.Tx, entry *models.RequestStatusHistory) error {
+	query := `
+		INSERT INTO request_status_history (request_id, old_status, new_status, changed_by, catatan, on_behalf_of)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return tx.QueryRow(
+		query,
+		entry.RequestID,
+		entry.OldStatus,
+		entry.NewStatus,
+		entry.ChangedBy,
+		entry.Catatan,
+		entry.OnBehalfOf,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// GetRequestStatusHistory returns the status timeline of a request, oldest first
+func (r *Repository) GetRequestStatusHistory(requestID string) ([]models.RequestStatusHistory, error) {
+	query := `
+		SELECT h.id, h.request_id, h.old_status, h.new_status, h.changed_by, u.name, h.catatan, h.created_at,
+			h.on_behalf_of, o.name
+		FROM request_status_history h
+		LEFT JOIN users u ON u.id = h.changed_by
+		LEFT JOIN users o ON o.id = h.on_behalf_of
+		WHERE h.request_id = $1
+		ORDER BY h.created_at, h.id`
+
+	rows, err := r.db.Query(query, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.RequestStatusHistory{}
+	for rows.Next() {
+		var entry models.RequestStatusHistory
+		err := rows.Scan(
+			&entry.ID,
+			&entry.RequestID,
+			&entry.OldStatus,
+			&entry.NewStatus,
+			&entry.ChangedBy,
+			&entry.ChangedByName,
+			&entry.Catatan,
+			&entry.CreatedAt,
+			&entry.OnBehalfOf,
+			&entry.OnBehalfOfName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
+func nullableString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (r *Repository) DeleteRequest(id string) error {
+	query := `DELETE FROM request WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("request not found")
+	}
+
+	return nil
+}
+
+// Dashboard repository methods
+func (r *Repository) GetRequestCount() (int, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM request`
 	err := r.db.QueryRow(query).Scan(&count)
 	return count, err
 }
 
-func (r *Repository) GetPendingRequestCount() (int, error) {
-	var count int
-	query := `SELECT COUNT(*) FROM request WHERE status_request = 'DIAJUKAN'`
-	err := r.db.QueryRow(query).Scan(&count)
-	return count, err
+func (r *Repository) GetPendingRequestCount() (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM request WHERE status_request = 'DIAJUKAN'`
+	err := r.db.QueryRow(query).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) GetUserCount() (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM users WHERE status <> $1`
+	err := r.db.QueryRow(query, models.UserStatusDeleted).Scan(&count)
+	return count, err
+}
+
+// UnitRepository methods
+
+// unitColumns lists the unit columns in the order scanUnit expects them
+const unitColumns = `id, name, cost_center, parent_id, head_user_id, created_at, updated_at`
+
+func scanUnit(row rowScanner) (*models.Unit, error) {
+	unit := &models.Unit{}
+	err := row.Scan(
+		&unit.ID,
+		&unit.Name,
+		&unit.CostCenter,
+		&unit.ParentID,
+		&unit.HeadUserID,
+		&unit.CreatedAt,
+		&unit.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return unit, nil
+}
+
+func (r *Repository) CreateUnit(unit *models.Unit) error {
+	query := `
+		INSERT INTO units (name, cost_center, parent_id, head_user_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(query, unit.Name, unit.CostCenter, unit.ParentID, unit.HeadUserID).
+		Scan(&unit.ID, &unit.CreatedAt, &unit.UpdatedAt)
+}
+
+func (r *Repository) GetUnitByID(id string) (*models.Unit, error) {
+	query := `SELECT ` + unitColumns + ` FROM units WHERE id = $1`
+	return scanUnit(r.db.QueryRow(query, id))
+}
+
+// GetUnitByName finds a unit by its name, ignoring case
+func (r *Repository) GetUnitByName(name string) (*models.Unit, error) {
+	query := `SELECT ` + unitColumns + ` FROM units WHERE LOWER(name) = LOWER($1)`
+	return scanUnit(r.db.QueryRow(query, name))
+}
+
+// FindUnitByName finds a unit by its name or, failing that, by the name of a unit merged into it
+func (r *Repository) FindUnitByName(name string) (*models.Unit, error) {
+	query := `SELECT ` + unitColumns + ` FROM units WHERE id = COALESCE(
+		(SELECT id FROM units WHERE LOWER(name) = LOWER($1)),
+		(SELECT unit_id FROM unit_aliases WHERE LOWER(alias) = LOWER($1)))`
+	return scanUnit(r.db.QueryRow(query, name))
+}
+
+// GetUnitByCostCenter finds the unit booked on a cost center
+func (r *Repository) GetUnitByCostCenter(costCenter string) (*models.Unit, error) {
+	query := `SELECT ` + unitColumns + ` FROM units WHERE cost_center = $1`
+	return scanUnit(r.db.QueryRow(query, costCenter))
+}
+
+func (r *Repository) GetAllUnits() ([]models.Unit, error) {
+	rows, err := r.db.Query(`SELECT ` + unitColumns + ` FROM units ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var units []models.Unit
+	for rows.Next() {
+		unit, err := scanUnit(rows)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, *unit)
+	}
+
+	return units, rows.Err()
+}
+
+// UpdateUnit saves a unit and copies its name onto the users and requests that reference it
+func (r *Repository) UpdateUnit(unit *models.Unit) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE units
+		SET name = $1, cost_center = $2, parent_id = $3, head_user_id = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING updated_at`,
+		unit.Name, unit.CostCenter, unit.ParentID, unit.HeadUserID, unit.ID,
+	).Scan(&unit.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET unit = $1 WHERE unit_id = $2 AND unit <> $1`, unit.Name, unit.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE request SET unit = $1 WHERE unit_id = $2 AND unit IS DISTINCT FROM $1`, unit.Name, unit.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CountUnitReferences returns how many child units, users, requests and approval chains refer to a unit
+func (r *Repository) CountUnitReferences(id string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM units WHERE parent_id = $1)
+			+ (SELECT COUNT(*) FROM users WHERE unit_id = $1)
+			+ (SELECT COUNT(*) FROM request WHERE unit_id = $1)
+			+ (SELECT COUNT(*) FROM approval_chains WHERE unit_id = $1)`, id).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) DeleteUnit(id string) error {
+	result, err := r.db.Exec(`DELETE FROM units WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MergeUnits moves the child units, users, requests and approval chains of one unit to another and deletes
+// the first. Its name and aliases become aliases of the unit it was merged into.
+func (r *Repository) MergeUnits(fromID string, into *models.Unit) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE units SET parent_id = $1, updated_at = CURRENT_TIMESTAMP WHERE parent_id = $2`, into.ID, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET unit_id = $1, unit = $2, updated_at = CURRENT_TIMESTAMP WHERE unit_id = $3`, into.ID, into.Name, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE request SET unit_id = $1, unit = $2 WHERE unit_id = $3`, into.ID, into.Name, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE approval_chains SET unit_id = $1, updated_at = CURRENT_TIMESTAMP WHERE unit_id = $2`, into.ID, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE unit_aliases SET unit_id = $1 WHERE unit_id = $2`, into.ID, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO unit_aliases (alias, unit_id)
+		SELECT name, $1 FROM units WHERE id = $2
+		ON CONFLICT ((LOWER(alias))) DO UPDATE SET unit_id = EXCLUDED.unit_id`, into.ID, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM units WHERE id = $1`, fromID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ApprovalRepository methods
+
+// approvalChainColumns lists the approval chain columns in the order scanApprovalChain expects them
+const approvalChainColumns = `id, name, jenis_request, unit_id, min_value, created_at, updated_at`
+
+func scanApprovalChain(row rowScanner) (*models.ApprovalChain, error) {
+	chain := &models.ApprovalChain{}
+	err := row.Scan(
+		&chain.ID,
+		&chain.Name,
+		&chain.JenisRequest,
+		&chain.UnitID,
+		&chain.MinValue,
+		&chain.CreatedAt,
+		&chain.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// GetApprovalChains returns the chains with their steps; an empty jenisRequest returns the chains of every type
+func (r *Repository) GetApprovalChains(jenisRequest string) ([]models.ApprovalChain, error) {
+	query := `SELECT ` + approvalChainColumns + ` FROM approval_chains
+		WHERE $1 = '' OR jenis_request = $1
+		ORDER BY jenis_request, min_value, id`
+	rows, err := r.db.Query(query, jenisRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chains := []models.ApprovalChain{}
+	for rows.Next() {
+		chain, err := scanApprovalChain(rows)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, *chain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range chains {
+		if chains[i].Steps, err = r.getApprovalChainSteps(chains[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return chains, nil
+}
+
+func (r *Repository) GetApprovalChainByID(id string) (*models.ApprovalChain, error) {
+	query := `SELECT ` + approvalChainColumns + ` FROM approval_chains WHERE id = $1`
+	chain, err := scanApprovalChain(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	chain.Steps, err = r.getApprovalChainSteps(chain.ID)
+	if err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+func (r *Repository) getApprovalChainSteps(chainID int64) ([]models.ApprovalStep, error) {
+	rows, err := r.db.Query(`
+		SELECT step_no, name, approver_type, approver_role, approver_user_id
+		FROM approval_chain_steps WHERE chain_id = $1 ORDER BY step_no`, chainID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []models.ApprovalStep{}
+	for rows.Next() {
+		var step models.ApprovalStep
+		if err := rows.Scan(&step.StepNo, &step.Name, &step.ApproverType, &step.ApproverRole, &step.ApproverUserID); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, rows.Err()
+}
+
+// CreateApprovalChain inserts a chain together with its steps
+func (r *Repository) CreateApprovalChain(chain *models.ApprovalChain) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO approval_chains (name, jenis_request, unit_id, min_value)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`,
+		chain.Name, chain.JenisRequest, chain.UnitID, chain.MinValue,
+	).Scan(&chain.ID, &chain.CreatedAt, &chain.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertApprovalChainSteps(tx, chain.ID, chain.Steps); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateApprovalChain saves a chain and replaces its steps
+func (r *Repository) UpdateApprovalChain(chain *models.ApprovalChain) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE approval_chains
+		SET name = $1, jenis_request = $2, unit_id = $3, min_value = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING updated_at`,
+		chain.Name, chain.JenisRequest, chain.UnitID, chain.MinValue, chain.ID,
+	).Scan(&chain.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM approval_chain_steps WHERE chain_id = $1`, chain.ID); err != nil {
+		return err
+	}
+	if err := insertApprovalChainSteps(tx, chain.ID, chain.Steps); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *Repository) GetUserCount() (int, error) {
-	var count int
-	query := `SELECT COUNT(*) FROM users`
-	err := r.db.QueryRow(query).Scan(&count)
-	return count, err
+func insertApprovalChainSteps(tx *sql.Tx, chainID int64, steps []models.ApprovalStep) error {
+	for _, step := range steps {
+		_, err := tx.Exec(`
+			INSERT INTO approval_chain_steps (chain_id, step_no, name, approver_type, approver_role, approver_user_id)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			chainID, step.StepNo, step.Name, step.ApproverType, step.ApproverRole, step.ApproverUserID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) DeleteApprovalChain(id string) error {
+	result, err := r.db.Exec(`DELETE FROM approval_chains WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func insertRequestApprovals(tx *sql.Tx, requestID int64, approvals []models.RequestApproval) error {
+	query := `
+		INSERT INTO request_approvals (request_id, step_no, name, approver_type, approver_role, approver_user_id, decision)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+
+	for i := range approvals {
+		approval := &approvals[i]
+		approval.RequestID = requestID
+		err := tx.QueryRow(
+			query,
+			approval.RequestID,
+			approval.StepNo,
+			approval.Name,
+			approval.ApproverType,
+			approval.ApproverRole,
+			approval.ApproverUserID,
+			approval.Decision,
+		).Scan(&approval.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRequestApprovals returns the approval steps of a request in order, with the names of who decided them
+func (r *Repository) GetRequestApprovals(requestID string) ([]models.RequestApproval, error) {
+	rows, err := r.db.Query(`
+		SELECT a.id, a.request_id, a.step_no, a.name, a.approver_type, a.approver_role, a.approver_user_id,
+			a.decision, a.decided_by_id, u.name, a.decided_at, a.catatan, a.on_behalf_of, o.name
+		FROM request_approvals a
+		LEFT JOIN users u ON u.id = a.decided_by_id
+		LEFT JOIN users o ON o.id = a.on_behalf_of
+		WHERE a.request_id = $1
+		ORDER BY a.step_no`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvals := []models.RequestApproval{}
+	for rows.Next() {
+		var a models.RequestApproval
+		err := rows.Scan(&a.ID, &a.RequestID, &a.StepNo, &a.Name, &a.ApproverType, &a.ApproverRole, &a.ApproverUserID,
+			&a.Decision, &a.DecidedByID, &a.DecidedByName, &a.DecidedAt, &a.Catatan, &a.OnBehalfOfID, &a.OnBehalfOfName)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+
+	return approvals, rows.Err()
+}
+
+// ApprovalDecision records the decision on one approval step. NewStatus is the request status afterwards:
+// DIAJUKAN while later steps are still open, otherwise DISETUJUI or DITOLAK.
+type ApprovalDecision struct {
+	ApprovalID int64
+	RequestID  string
+	Decision   string
+	Catatan    *string
+	NewStatus  string
+	// ApprovedBy and ApprovedByID are stored on the request when the decision settles it
+	ApprovedBy   string
+	ApprovedByID string
+	// HistoryNote describes the decision in the request's status history
+	HistoryNote string
+	ActorID     string
+	// OnBehalfOf is the approver ActorID substitutes for, if any
+	OnBehalfOf string
+}
+
+// DecideApproval stores a step decision, settles the request when it was the last step or a rejection,
+// and records the decision in request_status_history, all in one transaction. It returns ErrStatusChanged
+// when the step or the request was decided concurrently.
+func (r *Repository) DecideApproval(decision *ApprovalDecision) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE request_approvals
+		SET decision = $1, decided_by_id = $2, decided_at = CURRENT_TIMESTAMP, catatan = $3, on_behalf_of = $4
+		WHERE id = $5 AND decision = $6`,
+		decision.Decision, decision.ActorID, decision.Catatan, nullableString(decision.OnBehalfOf), decision.ApprovalID,
+		models.StatusDiajukan)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrStatusChanged
+	}
+
+	var requestID int64
+	if decision.NewStatus == models.StatusDiajukan {
+		err = tx.QueryRow(`SELECT id FROM request WHERE id = $1 AND status_request = $2 FOR UPDATE`,
+			decision.RequestID, models.StatusDiajukan).Scan(&requestID)
+	} else {
+		err = tx.QueryRow(`
+			UPDATE request
+			SET status_request = $1, approved_by = $2, approved_by_id = $3, updated_at = CURRENT_TIMESTAMP,
+				`+restartSLAClock+`
+			WHERE id = $4 AND status_request = $5
+			RETURNING id`,
+			decision.NewStatus, decision.ApprovedBy, decision.ApprovedByID, decision.RequestID, models.StatusDiajukan,
+		).Scan(&requestID)
+	}
+	if err == sql.ErrNoRows {
+		return ErrStatusChanged
+	}
+	if err != nil {
+		return err
+	}
+
+	oldStatus := models.StatusDiajukan
+	entry := &models.RequestStatusHistory{
+		RequestID:  requestID,
+		OldStatus:  &oldStatus,
+		NewStatus:  decision.NewStatus,
+		ChangedBy:  nullableString(decision.ActorID),
+		Catatan:    nullableString(decision.HistoryNote),
+		OnBehalfOf: nullableString(decision.OnBehalfOf),
+	}
+	if err := insertStatusHistory(tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRequestsAwaitingApproval returns the DIAJUKAN requests whose current approval step the user can decide:
+// steps assigned to them, to their role, and, when approveAny is set, unit head steps without a unit head
+func (r *Repository) GetRequestsAwaitingApproval(userID, role string, approveAny bool) ([]models.Request, error) {
+	query := `SELECT ` + requestColumns + ` FROM request
+		WHERE status_request = $1 AND EXISTS (
+			SELECT 1 FROM request_approvals a
+			WHERE a.request_id = request.id AND a.decision = $1
+				AND a.step_no = (SELECT MIN(step_no) FROM request_approvals p WHERE p.request_id = request.id AND p.decision = $1)
+				AND (a.approver_user_id = $2
+					OR (a.approver_type = $3 AND a.approver_role = $4)
+					OR (a.approver_type = $5 AND a.approver_user_id IS NULL AND $6))
+		)
+		ORDER BY created_at`
+	rows, err := r.db.Query(query, models.StatusDiajukan, userID, models.ApproverRole, role, models.ApproverUnitHead, approveAny)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests, err := scanRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+	return requests, r.attachRequestItems(requests)
+}
+
+// DelegationRepository methods
+
+const delegationColumns = `d.id, d.delegator_id, dg.name, d.substitute_id, sb.name, d.start_date, d.end_date,
+	d.reason, d.created_by, d.created_at`
+
+const delegationTables = `approval_delegations d
+	JOIN users dg ON dg.id = d.delegator_id
+	JOIN users sb ON sb.id = d.substitute_id`
+
+func scanDelegation(row rowScanner) (*models.Delegation, error) {
+	delegation := &models.Delegation{}
+	err := row.Scan(
+		&delegation.ID,
+		&delegation.DelegatorID,
+		&delegation.DelegatorName,
+		&delegation.SubstituteID,
+		&delegation.SubstituteName,
+		&delegation.StartDate,
+		&delegation.EndDate,
+		&delegation.Reason,
+		&delegation.CreatedBy,
+		&delegation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return delegation, nil
+}
+
+func (r *Repository) queryDelegations(query string, args ...interface{}) ([]models.Delegation, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delegations := []models.Delegation{}
+	for rows.Next() {
+		delegation, err := scanDelegation(rows)
+		if err != nil {
+			return nil, err
+		}
+		delegations = append(delegations, *delegation)
+	}
+	return delegations, rows.Err()
+}
+
+func (r *Repository) CreateDelegation(delegation *models.Delegation) error {
+	query := `
+		INSERT INTO approval_delegations (delegator_id, substitute_id, start_date, end_date, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(
+		query,
+		delegation.DelegatorID,
+		delegation.SubstituteID,
+		delegation.StartDate.Format("2006-01-02"),
+		delegation.EndDate.Format("2006-01-02"),
+		delegation.Reason,
+		delegation.CreatedBy,
+	).Scan(&delegation.ID, &delegation.CreatedAt)
+}
+
+func (r *Repository) GetDelegationByID(id string) (*models.Delegation, error) {
+	query := `SELECT ` + delegationColumns + ` FROM ` + delegationTables + ` WHERE d.id = $1`
+	return scanDelegation(r.db.QueryRow(query, id))
+}
+
+// GetDelegations returns the delegations given by or to userID, or all of them when userID is empty.
+// Delegations that ended before today are left out unless includeExpired is set.
+func (r *Repository) GetDelegations(userID string, includeExpired bool, today time.Time) ([]models.Delegation, error) {
+	query := `SELECT ` + delegationColumns + ` FROM ` + delegationTables + `
+		WHERE ($1 = '' OR d.delegator_id::text = $1 OR d.substitute_id::text = $1)
+			AND ($2 OR d.end_date >= $3::date)
+		ORDER BY d.start_date, d.id`
+	return r.queryDelegations(query, userID, includeExpired, today.Format("2006-01-02"))
+}
+
+// GetActiveDelegations returns the delegations a substitute can act on today
+func (r *Repository) GetActiveDelegations(substituteID string, today time.Time) ([]models.Delegation, error) {
+	query := `SELECT ` + delegationColumns + ` FROM ` + delegationTables + `
+		WHERE d.substitute_id = $1 AND d.start_date <= $2::date AND d.end_date >= $2::date
+		ORDER BY d.start_date, d.id`
+	return r.queryDelegations(query, substituteID, today.Format("2006-01-02"))
+}
+
+// HasOverlappingDelegation reports whether the delegator already has a delegation sharing a day with the range
+func (r *Repository) HasOverlappingDelegation(delegatorID string, start, end time.Time) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM approval_delegations
+			WHERE delegator_id = $1 AND start_date <= $3::date AND end_date >= $2::date
+		)`, delegatorID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&exists)
+	return exists, err
+}
+
+func (r *Repository) DeleteDelegation(id string) error {
+	result, err := r.db.Exec(`DELETE FROM approval_delegations WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SLARepository methods
+
+func (r *Repository) GetSLATargets() ([]models.SLATarget, error) {
+	rows, err := r.db.Query(`
+		SELECT jenis_request, status, working_days, updated_at
+		FROM sla_targets
+		ORDER BY jenis_request, status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []models.SLATarget{}
+	for rows.Next() {
+		var target models.SLATarget
+		if err := rows.Scan(&target.JenisRequest, &target.Status, &target.WorkingDays, &target.UpdatedAt); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// SaveSLATarget creates or replaces the target of a request type and status
+func (r *Repository) SaveSLATarget(target *models.SLATarget) error {
+	query := `
+		INSERT INTO sla_targets (jenis_request, status, working_days)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jenis_request, status)
+		DO UPDATE SET working_days = EXCLUDED.working_days, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`
+
+	return r.db.QueryRow(query, target.JenisRequest, target.Status, target.WorkingDays).Scan(&target.UpdatedAt)
+}
+
+func (r *Repository) DeleteSLATarget(jenisRequest, status string) error {
+	result, err := r.db.Exec(`DELETE FROM sla_targets WHERE jenis_request = $1 AND status = $2`, jenisRequest, status)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SLARequest is an open request together with the SLA target of its current status
+type SLARequest struct {
+	models.Request
+	WorkingDays int
+}
+
+// GetRequestsUnderSLA returns the requests whose current status has an SLA target, oldest clock first.
+// Items are not loaded.
+func (r *Repository) GetRequestsUnderSLA() ([]SLARequest, error) {
+	// A subquery keeps the unqualified request columns from clashing with those of sla_targets
+	query := `SELECT ` + requestColumns + `, working_days
+		FROM (
+			SELECT request.*, t.working_days
+			FROM request
+			JOIN sla_targets t ON t.jenis_request = request.jenis_request AND t.status = request.status_request
+		) request
+		ORDER BY status_since, id`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []SLARequest
+	for rows.Next() {
+		var workingDays int
+		request, err := scanRequest(extraColumns{rows, []interface{}{&workingDays}})
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, SLARequest{Request: *request, WorkingDays: workingDays})
+	}
+	return requests, rows.Err()
+}
+
+// Escalation moves an overdue request one approver level up. ApprovalID is the pending approval step that
+// is reassigned to TargetID, or zero when the request has none.
+type Escalation struct {
+	RequestID   int64
+	Status      string
+	FromLevel   int
+	ToLevel     int
+	TargetID    string
+	ApprovalID  int64
+	HistoryNote string
+}
+
+// EscalateRequest records an escalation, reassigns the pending step and adds a status history entry
+// without an acting user, all in one transaction. It returns ErrStatusChanged when the request changed
+// status or was escalated concurrently.
+func (r *Repository) EscalateRequest(escalation *Escalation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE request
+		SET escalation_level = $1, escalated_at = CURRENT_TIMESTAMP, escalated_to_id = $2
+		WHERE id = $3 AND status_request = $4 AND escalation_level = $5`,
+		escalation.ToLevel, escalation.TargetID, escalation.RequestID, escalation.Status, escalation.FromLevel)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrStatusChanged
+	}
+
+	if escalation.ApprovalID != 0 {
+		_, err := tx.Exec(`
+			UPDATE request_approvals
+			SET approver_type = $1, approver_role = NULL, approver_user_id = $2
+			WHERE id = $3 AND decision = $4`,
+			models.ApproverUser, escalation.TargetID, escalation.ApprovalID, models.StatusDiajukan)
+		if err != nil {
+			return err
+		}
+	}
+
+	entry := &models.RequestStatusHistory{
+		RequestID: escalation.RequestID,
+		OldStatus: &escalation.Status,
+		NewStatus: escalation.Status,
+		Catatan:   nullableString(escalation.HistoryNote),
+	}
+	if err := insertStatusHistory(tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// HolidayRepository methods; Repository implements calendar.Store
+
+// GetHolidays returns the holidays of a year, or all of them when year is zero
+func (r *Repository) GetHolidays(year int) ([]models.Holiday, error) {
+	rows, err := r.db.Query(`
+		SELECT date, name, created_at
+		FROM holidays
+		WHERE $1 = 0 OR EXTRACT(YEAR FROM date) = $1
+		ORDER BY date`, year)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []models.Holiday{}
+	for rows.Next() {
+		var holiday models.Holiday
+		if err := rows.Scan(&holiday.Date, &holiday.Name, &holiday.CreatedAt); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, holiday)
+	}
+	return holidays, rows.Err()
+}
+
+func (r *Repository) GetHolidayDates() ([]time.Time, error) {
+	rows, err := r.db.Query(`SELECT date FROM holidays ORDER BY date`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dates []time.Time
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	return dates, rows.Err()
+}
+
+// SaveHolidays adds the holidays in one transaction; a day that already is a holiday gets the new name
+func (r *Repository) SaveHolidays(holidays []models.Holiday) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range holidays {
+		err := tx.QueryRow(`
+			INSERT INTO holidays (date, name)
+			VALUES ($1, $2)
+			ON CONFLICT (date) DO UPDATE SET name = EXCLUDED.name
+			RETURNING created_at`,
+			holidays[i].Date.Format("2006-01-02"), holidays[i].Name,
+		).Scan(&holidays[i].CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) DeleteHoliday(date string) error {
+	result, err := r.db.Exec(`DELETE FROM holidays WHERE date = $1::date`, date)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
@@ -3,11 +3,13 @@ package routes
 import (
 	"web-work-request-backend/handlers"
 	"web-work-request-backend/middleware"
+	"web-work-request-backend/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(handler *handlers.Handler) *gin.Engine {
+// SetupRoutes builds the router; allowOrigin decides which browser origins may call the API
+func SetupRoutes(handler *handlers.Handler, access middleware.AccessControl, allowOrigin func(origin string) bool) *gin.Engine {
 	// Configure Gin to prevent automatic redirects
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
@@ -17,11 +19,14 @@ func SetupRoutes(handler *handlers.Handler) *gin.Engine {
 	r.Use(gin.Logger())
 
 	// Add CORS middleware
-	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.CORSMiddleware(allowOrigin))
 
 	// Health check
 	r.GET("/health", handler.HealthCheck)
 
+	// Public keys for services that verify our access tokens
+	r.GET("/.well-known/jwks.json", handler.GetJWKS)
+
 	// Public routes
 	api := r.Group("/api")
 	{
@@ -30,46 +35,146 @@ func SetupRoutes(handler *handlers.Handler) *gin.Engine {
 		{
 			auth.POST("/register", handler.Register)
 			auth.POST("/login", handler.Login)
+			auth.POST("/refresh", handler.RefreshToken)
+			auth.POST("/logout", handler.Logout)
+			auth.POST("/password-reset", handler.ResetPassword)
+			auth.POST("/2fa/verify", handler.VerifyTwoFactor)
+			auth.POST("/2fa/enroll", handler.StartChallengeEnrollment)
+			auth.POST("/2fa/enroll/confirm", handler.ConfirmChallengeEnrollment)
+			auth.GET("/oidc/login", handler.OIDCLogin)
+			auth.GET("/oidc/callback", handler.OIDCCallback)
+		}
+
+		// Every signed-in user can list the units; operators manage them below
+		units := api.Group("/units")
+		units.Use(middleware.AuthMiddleware(access))
+		{
+			units.GET("", handler.GetUnits)
 		}
 
 		// Protected routes - User management
 		users := api.Group("/users")
-		users.Use(middleware.AuthMiddleware())
+		users.Use(middleware.AuthMiddleware(access))
 		{
+			// Self-service profile, available to every authenticated user
 			users.GET("/me", handler.GetCurrentUser)
-			users.GET("", handler.GetAllUsers) // Remove trailing slash to prevent 301
-			users.GET("/:id", handler.GetUserByID)
-			users.POST("", handler.CreateUser) // Remove trailing slash
-			users.PUT("/:id", handler.UpdateUser)
-			users.DELETE("/:id", handler.DeleteUser)
+			users.PUT("/me", handler.UpdateCurrentUser)
+			users.POST("/me/password", handler.ChangePassword)
+			users.POST("/me/2fa", handler.StartTwoFactorEnrollment)
+			users.POST("/me/2fa/confirm", handler.ConfirmTwoFactorEnrollment)
+			users.DELETE("/me/2fa", handler.DisableTwoFactor)
+
+			// User administration requires the user.manage permission
+			userAdmin := users.Group("")
+			userAdmin.Use(middleware.RequirePermission(access, models.PermissionUserManage))
+			{
+				userAdmin.GET("", handler.GetAllUsers) // Remove trailing slash to prevent 301
+				userAdmin.GET("/:id", handler.GetUserByID)
+				userAdmin.POST("", handler.CreateUser) // Remove trailing slash
+				userAdmin.PUT("/:id", handler.UpdateUser)
+				userAdmin.DELETE("/:id", handler.DeleteUser)
+				userAdmin.POST("/:id/deactivate", handler.DeactivateUser)
+				userAdmin.POST("/:id/reactivate", handler.ReactivateUser)
+				userAdmin.POST("/:id/restore", handler.RestoreUser)
+				userAdmin.POST("/:id/unlock", handler.UnlockUser)
+				userAdmin.POST("/:id/password-reset", handler.CreatePasswordReset)
+				userAdmin.DELETE("/:id/2fa", handler.ResetTwoFactor)
+			}
+		}
+
+		// Role and permission management
+		roles := api.Group("")
+		roles.Use(middleware.AuthMiddleware(access))
+		roles.Use(middleware.RequirePermission(access, models.PermissionRoleManage))
+		{
+			roles.GET("/roles", handler.GetRoles)
+			roles.PUT("/roles/:name", handler.UpsertRole)
+			roles.GET("/permissions", handler.GetPermissions)
+		}
+
+		// Substitutes who decide approvals while an approver is away
+		delegations := api.Group("/delegations")
+		delegations.Use(middleware.AuthMiddleware(access))
+		{
+			delegations.GET("", handler.GetDelegations)
+			delegations.POST("", handler.CreateDelegation)
+			delegations.DELETE("/:id", handler.DeleteDelegation)
+		}
+
+		// SLA targets per request type and status; anyone signed in can read them
+		slaTargets := api.Group("/sla-targets")
+		slaTargets.Use(middleware.AuthMiddleware(access))
+		{
+			slaTargets.GET("", handler.GetSLATargets)
+
+			// Changing them requires the sla.manage permission
+			slaAdmin := slaTargets.Group("")
+			slaAdmin.Use(middleware.RequirePermission(access, models.PermissionSLAManage))
+			{
+				slaAdmin.PUT("", handler.SaveSLATarget)
+				slaAdmin.DELETE("/:jenis_request/:status", handler.DeleteSLATarget)
+			}
+		}
+
+		// Approval chains, used for requests submitted after a change
+		approvalChains := api.Group("/approval-chains")
+		approvalChains.Use(middleware.AuthMiddleware(access))
+		approvalChains.Use(middleware.RequirePermission(access, models.PermissionApprovalManage))
+		{
+			approvalChains.GET("", handler.GetApprovalChains)
+			approvalChains.GET("/:id", handler.GetApprovalChainByID)
+			approvalChains.POST("", handler.CreateApprovalChain)
+			approvalChains.PUT("/:id", handler.UpdateApprovalChain)
+			approvalChains.DELETE("/:id", handler.DeleteApprovalChain)
 		}
 
 		// Dashboard routes
 		dashboard := api.Group("/dashboard")
-		dashboard.Use(middleware.AuthMiddleware())
+		dashboard.Use(middleware.AuthMiddleware(access))
 		{
 			dashboard.GET("/stats", handler.GetDashboardStats)
 		}
 
 		// Protected routes - Requests
 		requests := api.Group("/requests")
-		requests.Use(middleware.AuthMiddleware())
+		requests.Use(middleware.AuthMiddleware(access))
 		{
 			requests.POST("", handler.CreateRequest) // Remove trailing slash
 			requests.GET("", handler.GetAllRequests) // Remove trailing slash
 			requests.GET("/my-requests", handler.GetRequestsByUser)
+			requests.GET("/search", handler.SearchRequests)
+			requests.GET("/pending-approvals", handler.GetPendingApprovals)
 			requests.GET("/:id", handler.GetRequestByID)
+			requests.GET("/:id/history", handler.GetRequestHistory)
 			requests.PUT("/:id/status", handler.UpdateRequestStatus)
+			requests.POST("/:id/approvals", handler.DecideApproval)
+			requests.PUT("/:id/items/:item_id", handler.UpdateRequestItem)
 			requests.DELETE("/:id", handler.DeleteRequest)
 		}
 
 		// Operator-only routes
 		operator := api.Group("/operator")
-		operator.Use(middleware.AuthMiddleware())
+		operator.Use(middleware.AuthMiddleware(access))
 		operator.Use(middleware.OperatorMiddleware())
 		{
-			// These routes are only accessible by operators
-			// The middleware ensures role-based access control
+			// Self-registered accounts wait here until an operator decides on them
+			operator.GET("/registrations", handler.GetPendingRegistrations)
+			operator.POST("/registrations/:id/approve", handler.ApproveRegistration)
+			operator.POST("/registrations/:id/reject", handler.RejectRegistration)
+
+			// Organizational units
+			operator.GET("/units", handler.GetUnits)
+			operator.GET("/units/:id", handler.GetUnitByID)
+			operator.POST("/units", handler.CreateUnit)
+			operator.PUT("/units/:id", handler.UpdateUnit)
+			operator.DELETE("/units/:id", handler.DeleteUnit)
+			operator.POST("/units/:id/merge", handler.MergeUnit)
+
+			// Public holidays, skipped when due dates are computed
+			operator.GET("/holidays", handler.GetHolidays)
+			operator.POST("/holidays", handler.SaveHoliday)
+			operator.POST("/holidays/import", handler.ImportHolidays)
+			operator.DELETE("/holidays/:date", handler.DeleteHoliday)
 		}
 	}
 
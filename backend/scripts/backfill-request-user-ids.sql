@@ -0,0 +1,34 @@
+-- Migration Script: Link requests to users by ID
+-- The backend adds and backfills these columns on startup; this script does the same by hand
+-- and lists the requests that could not be matched because the stored name is unknown or shared.
+
+BEGIN;
+
+ALTER TABLE request
+    ADD COLUMN IF NOT EXISTS requested_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS approved_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS accepted_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_request_requested_by_id ON request(requested_by_id);
+
+-- Only names that belong to exactly one user are backfilled
+WITH unique_names AS (
+    SELECT name, (array_agg(id))[1] AS id FROM users GROUP BY name HAVING COUNT(*) = 1
+)
+UPDATE request r SET
+    requested_by_id = COALESCE(r.requested_by_id, (SELECT id FROM unique_names WHERE name = r.requested_by)),
+    approved_by_id = COALESCE(r.approved_by_id, (SELECT id FROM unique_names WHERE name = r.approved_by)),
+    accepted_by_id = COALESCE(r.accepted_by_id, (SELECT id FROM unique_names WHERE name = r.accepted_by))
+WHERE (r.requested_by_id IS NULL AND r.requested_by IS NOT NULL)
+   OR (r.approved_by_id IS NULL AND r.approved_by IS NOT NULL)
+   OR (r.accepted_by_id IS NULL AND r.accepted_by IS NOT NULL);
+
+COMMIT;
+
+-- Requests that still need to be linked manually
+SELECT id, requested_by, approved_by, accepted_by
+FROM request
+WHERE (requested_by_id IS NULL AND requested_by IS NOT NULL)
+   OR (approved_by_id IS NULL AND approved_by IS NOT NULL)
+   OR (accepted_by_id IS NULL AND accepted_by IS NOT NULL)
+ORDER BY id;
@@ -0,0 +1,84 @@
+-- Migration Script: Move request line items into the request_items table
+-- Converts both the legacy single columns and the *_array columns into rows.
+-- Requests that already have items are skipped, so the script is safe to run more than once.
+-- The backend applies the same steps once on startup (migration backfill_request_items); this script is
+-- only needed to move the items by hand, for example before upgrading.
+
+BEGIN;
+
+-- Step 1: Create request_items table (also created automatically by the backend on startup)
+CREATE TABLE IF NOT EXISTS request_items (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES request(id) ON DELETE CASCADE,
+    item_no INT NOT NULL,
+    nama_barang VARCHAR(200),
+    type_model VARCHAR(100),
+    jenis_pekerjaan TEXT,
+    lokasi VARCHAR(200),
+    kegunaan TEXT,
+    tgl_peminjaman DATE,
+    tgl_pengembalian DATE,
+    jumlah_diminta INT NOT NULL DEFAULT 1,
+    jumlah_disetujui INT,
+    keterangan TEXT,
+    catatan TEXT,
+    status_item VARCHAR(50) NOT NULL DEFAULT 'DIAJUKAN',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (request_id, item_no)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_items_request_id ON request_items(request_id);
+
+-- Step 2: Pengadaan requests with array columns
+INSERT INTO request_items (request_id, item_no, nama_barang, type_model, jumlah_diminta, keterangan, status_item)
+SELECT r.id, t.item_no, t.nama_barang, t.type_model, COALESCE(t.jumlah, 1), t.keterangan, 'DIAJUKAN'
+FROM request r
+CROSS JOIN LATERAL unnest(r.nama_barang_array, r.type_model_array, r.jumlah_array, r.keterangan_array)
+    WITH ORDINALITY AS t(nama_barang, type_model, jumlah, keterangan, item_no)
+WHERE r.jenis_request = 'pengadaan'
+  AND COALESCE(array_length(r.nama_barang_array, 1), 0) > 0
+  AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+-- Step 3: Perbaikan requests with array columns
+INSERT INTO request_items (request_id, item_no, nama_barang, type_model, jumlah_diminta, jenis_pekerjaan, lokasi, status_item)
+SELECT r.id, t.item_no, t.nama_barang, t.type_model, COALESCE(t.jumlah, 1), t.jenis_pekerjaan, t.lokasi, 'DIAJUKAN'
+FROM request r
+CROSS JOIN LATERAL unnest(r.nama_barang_perbaikan_array, r.type_model_perbaikan_array, r.jumlah_perbaikan_array,
+                          r.jenis_pekerjaan_array, r.lokasi_perbaikan_array)
+    WITH ORDINALITY AS t(nama_barang, type_model, jumlah, jenis_pekerjaan, lokasi, item_no)
+WHERE r.jenis_request = 'perbaikan'
+  AND COALESCE(array_length(r.nama_barang_perbaikan_array, 1), 0) > 0
+  AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+-- Step 4: Peminjaman requests with array columns
+INSERT INTO request_items (request_id, item_no, lokasi, kegunaan, tgl_peminjaman, tgl_pengembalian, status_item)
+SELECT r.id, t.item_no, t.lokasi, t.kegunaan, t.tgl_peminjaman::date, t.tgl_pengembalian::date, 'DIAJUKAN'
+FROM request r
+CROSS JOIN LATERAL unnest(r.lokasi_peminjaman_array, r.kegunaan_array, r.tgl_peminjaman_array, r.tgl_pengembalian_array)
+    WITH ORDINALITY AS t(lokasi, kegunaan, tgl_peminjaman, tgl_pengembalian, item_no)
+WHERE r.jenis_request = 'peminjaman'
+  AND COALESCE(array_length(r.lokasi_peminjaman_array, 1), 0) > 0
+  AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+-- Step 5: Requests that only use the legacy single columns
+INSERT INTO request_items (request_id, item_no, nama_barang, type_model, jumlah_diminta, jenis_pekerjaan, lokasi,
+                           kegunaan, tgl_peminjaman, tgl_pengembalian, status_item)
+SELECT r.id, 1, r.nama_barang, r.type_model, COALESCE(r.jumlah, 1), r.jenis_pekerjaan, r.lokasi,
+       r.kegunaan, r.tgl_peminjaman, r.tgl_pengembalian, 'DIAJUKAN'
+FROM request r
+WHERE (r.nama_barang IS NOT NULL OR r.lokasi IS NOT NULL OR r.kegunaan IS NOT NULL)
+  AND NOT EXISTS (SELECT 1 FROM request_items i WHERE i.request_id = r.id);
+
+-- Step 6: Carry the request-level decision over to the migrated items
+UPDATE request_items i
+SET status_item = 'DITOLAK', jumlah_disetujui = 0
+FROM request r
+WHERE i.request_id = r.id AND r.status_request = 'DITOLAK' AND i.status_item = 'DIAJUKAN';
+
+UPDATE request_items i
+SET status_item = 'DISETUJUI', jumlah_disetujui = i.jumlah_diminta
+FROM request r
+WHERE i.request_id = r.id AND r.status_request IN ('DISETUJUI', 'DIPROSES', 'SELESAI') AND i.status_item = 'DIAJUKAN';
+
+COMMIT;
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNoPendingApproval is returned when deciding an approval step of a request that has none open
+	ErrNoPendingApproval = errors.New("the request has no approval step waiting for a decision")
+	// ErrApprovalChainRequired is returned when a request with an approval chain is approved or rejected directly
+	ErrApprovalChainRequired = errors.New("this request is decided through its approval steps")
+)
+
+// maxUnitDepth bounds the walk up the unit tree in case the data has a cycle
+const maxUnitDepth = 32
+
+// SelectApprovalChain picks the chain for a request of the given type and estimated value. lineage is the
+// request's unit followed by its parent units. Among the chains whose minimum value is reached, a chain
+// for a closer unit beats one for a parent unit, which beats a chain for every unit; among those the
+// highest minimum value wins. It returns nil when no chain applies.
+func SelectApprovalChain(chains []models.ApprovalChain, jenisRequest string, lineage []uuid.UUID, value float64) *models.ApprovalChain {
+	var best *models.ApprovalChain
+	bestDistance := 0
+
+	for i := range chains {
+		chain := &chains[i]
+		if chain.JenisRequest != jenisRequest || value < chain.MinValue {
+			continue
+		}
+
+		distance := len(lineage)
+		if chain.UnitID != nil {
+			distance = -1
+			for d, unitID := range lineage {
+				if unitID == *chain.UnitID {
+					distance = d
+					break
+				}
+			}
+			if distance < 0 {
+				continue
+			}
+		}
+
+		if best == nil || distance < bestDistance || distance == bestDistance && chain.MinValue > best.MinValue {
+			best, bestDistance = chain, distance
+		}
+	}
+
+	return best
+}
+
+// EstimatedValue sums the requested quantity times the unit price of each item; items without a price
+// add nothing
+func EstimatedValue(items []models.RequestItem) float64 {
+	total := 0.0
+	for _, item := range items {
+		if item.HargaSatuan != nil {
+			total += float64(item.JumlahDiminta) * *item.HargaSatuan
+		}
+	}
+	return total
+}
+
+// unitLineage returns a unit followed by its parent units, closest first
+func (s *Service) unitLineage(unitID *uuid.UUID) ([]models.Unit, error) {
+	var lineage []models.Unit
+
+	for next := unitID; next != nil && len(lineage) < maxUnitDepth; {
+		unit, err := s.repo.GetUnitByID(next.String())
+		if err != nil {
+			return nil, err
+		}
+		lineage = append(lineage, *unit)
+		next = unit.ParentID
+	}
+
+	return lineage, nil
+}
+
+// approvalsFor copies the steps of the chain that applies to a new request, resolving unit heads from the
+// request's unit upwards. A head who submitted the request is passed over for the next head up; with none
+// left the step falls to anyone with request.approve. It returns nil when no chain applies and the request
+// is approved in one step.
+func (s *Service) approvalsFor(request *models.Request) ([]models.RequestApproval, error) {
+	chains, err := s.repo.GetApprovalChains(request.JenisRequest)
+	if err != nil || len(chains) == 0 {
+		return nil, err
+	}
+
+	lineage, err := s.unitLineage(request.UnitID)
+	if err != nil {
+		return nil, err
+	}
+	unitIDs := make([]uuid.UUID, len(lineage))
+	var unitHead *uuid.UUID
+	for i, unit := range lineage {
+		unitIDs[i] = unit.ID
+		if unitHead == nil && unit.HeadUserID != nil && !requestedBy(request, unit.HeadUserID.String()) {
+			unitHead = unit.HeadUserID
+		}
+	}
+
+	chain := SelectApprovalChain(chains, request.JenisRequest, unitIDs, request.NilaiEstimasi)
+	if chain == nil {
+		return nil, nil
+	}
+
+	approvals := make([]models.RequestApproval, len(chain.Steps))
+	for i, step := range chain.Steps {
+		approvals[i] = models.RequestApproval{
+			StepNo:         step.StepNo,
+			Name:           step.Name,
+			ApproverType:   step.ApproverType,
+			ApproverRole:   step.ApproverRole,
+			ApproverUserID: step.ApproverUserID,
+			Decision:       models.StatusDiajukan,
+		}
+		if step.ApproverType == models.ApproverUnitHead {
+			approvals[i].ApproverUserID = unitHead
+		}
+	}
+	return approvals, nil
+}
+
+// currentApproval returns the first step still waiting for a decision
+func currentApproval(request *models.Request) *models.RequestApproval {
+	for i := range request.Approvals {
+		if request.Approvals[i].Decision == models.StatusDiajukan {
+			return &request.Approvals[i]
+		}
+	}
+	return nil
+}
+
+// errOwnRequest is returned when a requester tries to decide an approval step of their own request
+var errOwnRequest = &ForbiddenError{Action: ActionDecideApproval, Reason: "you cannot decide your own request"}
+
+// requestedBy reports whether the user submitted the request
+func requestedBy(request *models.Request, userID string) bool {
+	return request.RequestedByID != nil && *request.RequestedByID == userID
+}
+
+// checkDecideApproval returns a ForbiddenError unless the actor is the approver of the step. Nobody
+// decides a step of their own request, whoever they act for.
+func (s *Service) checkDecideApproval(actor *Actor, request *models.Request, approval *models.RequestApproval) error {
+	if requestedBy(request, actor.UserID) {
+		return errOwnRequest
+	}
+
+	allowed, err := s.canDecideApproval(actor, approval)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &ForbiddenError{
+			Action: ActionDecideApproval,
+			Reason: fmt.Sprintf("step %d (%s) is waiting for another approver", approval.StepNo, approval.Name),
+		}
+	}
+	return nil
+}
+
+// canDecideApproval reports whether the actor is the approver of a step. A unit head step whose unit has
+// no head falls back to anyone with request.approve, so requests cannot get stuck.
+func (s *Service) canDecideApproval(actor *Actor, approval *models.RequestApproval) (bool, error) {
+	switch {
+	case approval.ApproverType == models.ApproverRole:
+		return approval.ApproverRole != nil && *approval.ApproverRole == actor.Role, nil
+	case approval.ApproverUserID != nil:
+		return approval.ApproverUserID.String() == actor.UserID, nil
+	case approval.ApproverType == models.ApproverUnitHead:
+		return s.can(actor, models.PermissionRequestApprove)
+	default:
+		return false, nil
+	}
+}
+
+// approvalNote describes a step decision in the request's status history
+func approvalNote(approval *models.RequestApproval, decision, approverName string, catatan *string) string {
+	note := fmt.Sprintf("Step %d (%s) %s by %s", approval.StepNo, approval.Name, statusVerb(decision), approverName)
+	if catatan != nil && *catatan != "" {
+		note += ": " + *catatan
+	}
+	return note
+}
+
+// DecideApproval approves or rejects the current approval step of a request. A rejection rejects the
+// request; approving the last step approves it.
+func (s *Service) DecideApproval(id string, req *models.ApprovalDecisionRequest, actor *Actor) (*models.Request, error) {
+	request, err := s.repo.GetRequestByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	approval := currentApproval(request)
+	if approval == nil || request.StatusRequest != models.StatusDiajukan {
+		return nil, ErrNoPendingApproval
+	}
+
+	// A substitute decides the steps of the approver they stand in for, unless the request is their own
+	if requestedBy(request, actor.UserID) {
+		return nil, errOwnRequest
+	}
+	onBehalfOf, err := s.actAs(actor, func(a *Actor) error {
+		return s.checkDecideApproval(a, request, approval)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newStatus := models.StatusDiajukan
+	switch {
+	case req.Decision == models.StatusDitolak:
+		newStatus = models.StatusDitolak
+	case approval.StepNo == request.Approvals[len(request.Approvals)-1].StepNo:
+		newStatus = models.StatusDisetujui
+	}
+
+	decision := &repository.ApprovalDecision{
+		ApprovalID:   approval.ID,
+		RequestID:    id,
+		Decision:     req.Decision,
+		Catatan:      req.Catatan,
+		NewStatus:    newStatus,
+		ApprovedBy:   user.Name,
+		ApprovedByID: actor.UserID,
+		HistoryNote:  approvalNote(approval, req.Decision, actedBy(user.Name, onBehalfOf), req.Catatan),
+		ActorID:      actor.UserID,
+	}
+	if onBehalfOf != nil {
+		decision.OnBehalfOf = onBehalfOf.DelegatorID.String()
+	}
+	if err := s.repo.DecideApproval(decision); err != nil {
+		return nil, err
+	}
+
+	decided, err := s.repo.GetRequestByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return decided, s.applySLA(decided)
+}
+
+// GetPendingApprovals returns the requests whose current approval step the actor can decide, including
+// those of the approvers the actor substitutes for today
+func (s *Service) GetPendingApprovals(actor *Actor) ([]models.Request, error) {
+	approvers := []*Actor{actor}
+
+	delegations, err := s.repo.GetActiveDelegations(actor.UserID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range delegations {
+		delegator, err := s.delegatorActor(&delegations[i])
+		if err != nil {
+			return nil, err
+		}
+		if delegator != nil {
+			approvers = append(approvers, delegator)
+		}
+	}
+
+	var pending []models.Request
+	seen := make(map[int64]bool)
+	for _, approver := range approvers {
+		approveAny, err := s.can(approver, models.PermissionRequestApprove)
+		if err != nil {
+			return nil, err
+		}
+		requests, err := s.repo.GetRequestsAwaitingApproval(approver.UserID, approver.Role, approveAny)
+		if err != nil {
+			return nil, err
+		}
+		for _, request := range requests {
+			if requestedBy(&request, actor.UserID) || requestedBy(&request, approver.UserID) {
+				continue
+			}
+			if !seen[request.ID] {
+				seen[request.ID] = true
+				pending = append(pending, request)
+			}
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	return pending, s.applySLAToList(pending)
+}
+
+// Approval chain configuration
+
+func (s *Service) GetApprovalChains(jenisRequest string) ([]models.ApprovalChain, error) {
+	return s.repo.GetApprovalChains(jenisRequest)
+}
+
+func (s *Service) GetApprovalChainByID(id string) (*models.ApprovalChain, error) {
+	return s.repo.GetApprovalChainByID(id)
+}
+
+func (s *Service) CreateApprovalChain(req *models.ApprovalChainRequest) (*models.ApprovalChain, error) {
+	chain := &models.ApprovalChain{}
+	if err := s.applyApprovalChainRequest(chain, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateApprovalChain(chain); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// UpdateApprovalChain replaces a chain and its steps; requests already submitted keep the steps they got
+func (s *Service) UpdateApprovalChain(id string, req *models.ApprovalChainRequest) (*models.ApprovalChain, error) {
+	chain, err := s.repo.GetApprovalChainByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyApprovalChainRequest(chain, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateApprovalChain(chain); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+func (s *Service) DeleteApprovalChain(id string) error {
+	return s.repo.DeleteApprovalChain(id)
+}
+
+// applyApprovalChainRequest validates the unit and the approver of every step and copies them onto chain
+func (s *Service) applyApprovalChainRequest(chain *models.ApprovalChain, req *models.ApprovalChainRequest) error {
+	var unitID *uuid.UUID
+	if req.UnitID != "" {
+		unit, err := s.resolveUnit(req.UnitID, "")
+		if err != nil {
+			return err
+		}
+		unitID = &unit.ID
+	}
+
+	steps := make([]models.ApprovalStep, len(req.Steps))
+	for i, stepReq := range req.Steps {
+		step := models.ApprovalStep{StepNo: i + 1, Name: stepReq.Name, ApproverType: stepReq.ApproverType}
+
+		switch stepReq.ApproverType {
+		case models.ApproverRole:
+			if err := s.validateRole(stepReq.ApproverRole); err != nil {
+				return fmt.Errorf("step %d: %w", step.StepNo, err)
+			}
+			role := stepReq.ApproverRole
+			step.ApproverRole = &role
+		case models.ApproverUser:
+			approverID, err := s.activeUserID(stepReq.ApproverUserID, "approver")
+			if err != nil {
+				return fmt.Errorf("step %d: %w", step.StepNo, err)
+			}
+			step.ApproverUserID = approverID
+		}
+
+		steps[i] = step
+	}
+
+	chain.Name, chain.JenisRequest, chain.UnitID, chain.MinValue, chain.Steps = req.Name, req.JenisRequest, unitID, req.MinValue, steps
+	return nil
+}
+
+// activeUserID checks that id names an active user; what names the user's part in error messages
+func (s *Service) activeUserID(id, what string) (*uuid.UUID, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%s not found", what)
+	}
+
+	user, err := s.repo.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s not found", what)
+		}
+		return nil, err
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, fmt.Errorf("the %s must be an active user", what)
+	}
+	return &user.ID, nil
+}
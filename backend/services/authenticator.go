@@ -0,0 +1,197 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"web-work-request-backend/config"
+	"web-work-request-backend/directory"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+	"web-work-request-backend/utils"
+
+	"github.com/google/uuid"
+)
+
+// ErrExternalAccount is returned when changing the password of an account whose password is kept elsewhere
+var ErrExternalAccount = errors.New("the password of this account is managed by an external identity provider")
+
+// Authenticator checks a username and password against one source of accounts
+type Authenticator interface {
+	// Name is the auth source of the accounts the authenticator owns
+	Name() string
+	// Authenticate returns the user for valid credentials and ErrInvalidCredentials otherwise;
+	// it may create the user on their first login
+	Authenticate(username, password string) (*models.User, error)
+}
+
+// NewAuthenticators builds the authenticators listed in AUTH_PROVIDERS, in order
+func NewAuthenticators(repo *repository.Repository, cfg *config.Config) ([]Authenticator, error) {
+	var authenticators []Authenticator
+
+	for _, provider := range cfg.AuthProviders {
+		switch provider {
+		case config.AuthProviderLocal:
+			authenticators = append(authenticators, NewLocalAuthenticator(repo))
+		case config.AuthProviderLDAP:
+			roles, err := directory.ParseRoleMapping(cfg.LDAPGroupRoles, cfg.LDAPDefaultRole)
+			if err != nil {
+				return nil, err
+			}
+			authenticators = append(authenticators, NewLDAPAuthenticator(directory.NewClient(cfg), repo, roles))
+		default:
+			return nil, fmt.Errorf("unknown auth provider %q", provider)
+		}
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("no auth providers configured")
+	}
+	return authenticators, nil
+}
+
+// localAuthenticator checks bcrypt password hashes stored in the users table
+type localAuthenticator struct {
+	repo *repository.Repository
+}
+
+// NewLocalAuthenticator authenticates accounts with a local password
+func NewLocalAuthenticator(repo *repository.Repository) Authenticator {
+	return &localAuthenticator{repo: repo}
+}
+
+func (a *localAuthenticator) Name() string {
+	return models.AuthSourceLocal
+}
+
+func (a *localAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	user, err := a.repo.GetUserByUsername(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if user.AuthSource != models.AuthSourceLocal || !utils.CheckPasswordHash(password, user.PasswordHash) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// Directory verifies credentials against an LDAP directory; *directory.Client implements it
+type Directory interface {
+	Authenticate(username, password string) (*directory.Entry, error)
+}
+
+// ldapAuthenticator checks passwords against the directory and keeps the local account in sync with it
+type ldapAuthenticator struct {
+	directory Directory
+	repo      *repository.Repository
+	roles     directory.RoleMapping
+}
+
+// NewLDAPAuthenticator authenticates against a directory. Users are provisioned on their first login, with
+// the directory department as unit and a role from their groups; the name, email, unit and role are
+// refreshed on every later login so directory changes take effect.
+func NewLDAPAuthenticator(dir Directory, repo *repository.Repository, roles directory.RoleMapping) Authenticator {
+	return &ldapAuthenticator{directory: dir, repo: repo, roles: roles}
+}
+
+func (a *ldapAuthenticator) Name() string {
+	return models.AuthSourceLDAP
+}
+
+func (a *ldapAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	entry, err := a.directory.Authenticate(username, password)
+	if err != nil {
+		if errors.Is(err, directory.ErrInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	role := a.roles.Role(entry.Groups)
+	exists, err := a.repo.RoleExists(role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("LDAP group mapping uses unknown role %q", role)
+	}
+
+	user, err := a.repo.GetUserByUsername(entry.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if user == nil {
+		return a.provision(entry, role)
+	}
+
+	// A local account with the same username is never taken over by a directory login
+	if user.AuthSource != models.AuthSourceLDAP {
+		log.Printf("Directory login for %s refused: a local account with that username exists", entry.Username)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := syncExternalUser(a.repo, user, entry.Name, entry.Email, entry.Department, role); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncExternalUser copies the profile and role kept by an external identity source onto the local account
+func syncExternalUser(repo *repository.Repository, user *models.User, name, email, unit, role string) error {
+	unit, unitID, err := externalUnit(repo, unit)
+	if err != nil {
+		return err
+	}
+	// A department no unit is known by does not take the user out of the unit they are in
+	if unitID == nil && user.UnitID != nil {
+		unit, unitID = user.Unit, user.UnitID
+	}
+
+	if user.Name == name && user.Email == email && user.Unit == unit && sameUnit(user.UnitID, unitID) && user.Role == role {
+		return nil
+	}
+
+	user.Name, user.Email, user.Unit, user.UnitID, user.Role = name, email, unit, unitID, role
+	return repo.UpdateUser(user)
+}
+
+func sameUnit(a, b *uuid.UUID) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// provision creates the local account of a directory user on their first login
+func (a *ldapAuthenticator) provision(entry *directory.Entry, role string) (*models.User, error) {
+	// Email is required and unique for every account
+	if entry.Email == "" {
+		return nil, fmt.Errorf("directory entry %s has no email address", entry.DN)
+	}
+
+	unit, unitID, err := externalUnit(a.repo, entry.Department)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username: entry.Username,
+		// No local password: bcrypt never matches an empty hash
+		PasswordHash: "",
+		Name:         entry.Name,
+		Email:        entry.Email,
+		Unit:         unit,
+		UnitID:       unitID,
+		Role:         role,
+		AuthSource:   models.AuthSourceLDAP,
+	}
+	if err := a.repo.CreateUser(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned directory user %s with role %s", user.Username, user.Role)
+	return user, nil
+}
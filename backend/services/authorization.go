@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+)
+
+// Actor identifies the authenticated user performing an action
+type Actor struct {
+	UserID string
+	Role   string
+
+	// permissions is loaded on first use and reused for the rest of the request
+	permissions map[string]bool
+}
+
+// Authorization actions checked by the service layer
+const (
+	ActionViewRequest    = "request.view"
+	ActionDeleteRequest  = "request.delete"
+	ActionDecideRequest  = "request.decide"
+	ActionDecideApproval = "request.decide_approval"
+	ActionDelegate       = "delegation.manage"
+	ActionDisable2FA     = "2fa.disable"
+)
+
+// ErrForbidden is matched by every authorization failure
+var ErrForbidden = errors.New("forbidden")
+
+// ForbiddenError describes an action the actor is not allowed to perform
+type ForbiddenError struct {
+	Action string
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Reason
+}
+
+// Is makes errors.Is(err, ErrForbidden) true for every ForbiddenError
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// permissionsOf returns the permissions of the actor's role
+func (s *Service) permissionsOf(actor *Actor) (map[string]bool, error) {
+	if actor.permissions != nil {
+		return actor.permissions, nil
+	}
+
+	permissions, err := s.repo.GetRolePermissions(actor.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	actor.permissions = make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		actor.permissions[permission] = true
+	}
+
+	return actor.permissions, nil
+}
+
+// can reports whether the actor's role grants the permission
+func (s *Service) can(actor *Actor, permission string) (bool, error) {
+	permissions, err := s.permissionsOf(actor)
+	if err != nil {
+		return false, err
+	}
+	return permissions[permission], nil
+}
+
+// permissionList returns the actor's permissions as a slice
+func (s *Service) permissionList(actor *Actor) ([]string, error) {
+	permissions, err := s.permissionsOf(actor)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(permissions))
+	for permission := range permissions {
+		list = append(list, permission)
+	}
+	return list, nil
+}
+
+// HasPermission reports whether a role grants the permission; used by the permission middleware
+func (s *Service) HasPermission(role string, permission string) (bool, error) {
+	return s.can(&Actor{Role: role}, permission)
+}
+
+func (a *Actor) owns(request *models.Request) bool {
+	return request.RequestedByID != nil && *request.RequestedByID == a.UserID
+}
+
+// approves reports whether the actor is the approver of one of the request's approval steps
+func (a *Actor) approves(request *models.Request) bool {
+	for _, approval := range request.Approvals {
+		if approval.ApproverUserID != nil && approval.ApproverUserID.String() == a.UserID {
+			return true
+		}
+		if approval.ApproverType == models.ApproverRole && approval.ApproverRole != nil && *approval.ApproverRole == a.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// escalatedTo reports whether an overdue request was last escalated to the actor
+func (a *Actor) escalatedTo(request *models.Request) bool {
+	return request.EscalatedToID != nil && *request.EscalatedToID == a.UserID
+}
+
+// requestScope returns the requests the actor may see: holders of request.view_all see everything,
+// other users see their own requests and those of their unit
+func (s *Service) requestScope(actor *Actor) (*repository.RequestScope, error) {
+	viewAll, err := s.can(actor, models.PermissionRequestViewAll)
+	if err != nil || viewAll {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &repository.RequestScope{RequesterID: actor.UserID}
+	if user.UnitID != nil {
+		scope.UnitID = user.UnitID.String()
+	}
+	return scope, nil
+}
+
+// authorizeViewRequest also lets a substitute see what the approver they stand in for can see
+func (s *Service) authorizeViewRequest(actor *Actor, request *models.Request) error {
+	_, err := s.actAs(actor, func(a *Actor) error {
+		return s.checkViewRequest(a, request)
+	})
+	return err
+}
+
+func (s *Service) checkViewRequest(actor *Actor, request *models.Request) error {
+	if actor.owns(request) || actor.approves(request) || actor.escalatedTo(request) {
+		return nil
+	}
+
+	viewAll, err := s.can(actor, models.PermissionRequestViewAll)
+	if err != nil || viewAll {
+		return err
+	}
+
+	user, err := s.repo.GetUserByID(actor.UserID)
+	if err != nil {
+		return err
+	}
+	if user.UnitID != nil && request.UnitID != nil && *user.UnitID == *request.UnitID {
+		return nil
+	}
+
+	return &ForbiddenError{Action: ActionViewRequest, Reason: "you can only view requests from yourself or your unit"}
+}
+
+func (s *Service) authorizeDeleteRequest(actor *Actor, request *models.Request) error {
+	deleteAny, err := s.can(actor, models.PermissionRequestDeleteAny)
+	if err != nil || deleteAny {
+		return err
+	}
+	if !actor.owns(request) {
+		return &ForbiddenError{Action: ActionDeleteRequest, Reason: "you can only delete your own requests"}
+	}
+	if request.StatusRequest != models.StatusDiajukan {
+		return &ForbiddenError{Action: ActionDeleteRequest, Reason: "requests can only be deleted while they are still DIAJUKAN"}
+	}
+	return nil
+}
+
+// authorizeDecideRequest requires at least one of the permissions that move a request through its workflow;
+// the exact permission for a given status change is checked by CheckTransition
+func (s *Service) authorizeDecideRequest(actor *Actor) error {
+	for _, permission := range []string{models.PermissionRequestApprove, models.PermissionRequestProcess} {
+		allowed, err := s.can(actor, permission)
+		if err != nil || allowed {
+			return err
+		}
+	}
+	return &ForbiddenError{Action: ActionDecideRequest, Reason: "you are not allowed to approve or process requests"}
+}
+
+func (s *Service) authorizeApproveItems(actor *Actor) error {
+	approve, err := s.can(actor, models.PermissionRequestApprove)
+	if err != nil || approve {
+		return err
+	}
+	return &ForbiddenError{Action: ActionDecideRequest, Reason: "you are not allowed to approve or reject request items"}
+}
+
+// authorizeManageRole checks that the actor holds every permission of the role being assigned or of the
+// user being managed, so nobody can hand out or take away more rights than they have themselves
+func (s *Service) authorizeManageRole(actor *Actor, role string) error {
+	permissions, err := s.repo.GetRolePermissions(role)
+	if err != nil {
+		return err
+	}
+
+	for _, permission := range permissions {
+		allowed, err := s.can(actor, permission)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return &ForbiddenError{Action: models.PermissionUserManage, Reason: "you cannot manage role " + role + " because it has permissions you do not have"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"web-work-request-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrDelegationOverlap is returned when an approver registers a second substitute for the same days
+var ErrDelegationOverlap = errors.New("the approver already has a substitute for some of these days")
+
+// DelegationStatus returns whether a delegation running from start to end, inclusive, is scheduled, active
+// or expired on the given day. Only the calendar dates are compared, so a delegation ends at midnight
+// without anything having to clean it up.
+func DelegationStatus(start, end, today time.Time) string {
+	day := today.Format("2006-01-02")
+	switch {
+	case day < start.Format("2006-01-02"):
+		return models.DelegationScheduled
+	case day > end.Format("2006-01-02"):
+		return models.DelegationExpired
+	default:
+		return models.DelegationActive
+	}
+}
+
+// actAs runs check for the actor and, when the actor is forbidden, for each approver the actor substitutes
+// for today. It returns the delegation that allowed the action, or nil when the actor acts for themselves.
+// When nobody is allowed the actor's own error is returned.
+func (s *Service) actAs(actor *Actor, check func(*Actor) error) (*models.Delegation, error) {
+	err := check(actor)
+	if err == nil || !errors.Is(err, ErrForbidden) {
+		return nil, err
+	}
+
+	delegations, lookupErr := s.repo.GetActiveDelegations(actor.UserID, time.Now())
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	for i := range delegations {
+		delegator, lookupErr := s.delegatorActor(&delegations[i])
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if delegator == nil {
+			continue
+		}
+
+		delegatedErr := check(delegator)
+		if delegatedErr == nil {
+			return &delegations[i], nil
+		}
+		if !errors.Is(delegatedErr, ErrForbidden) {
+			return nil, delegatedErr
+		}
+	}
+
+	return nil, err
+}
+
+// delegatorActor returns the approver behind a delegation; an approver who is no longer active passes
+// nothing on and nil is returned
+func (s *Service) delegatorActor(delegation *models.Delegation) (*Actor, error) {
+	user, err := s.repo.GetUserByID(delegation.DelegatorID.String())
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, nil
+	}
+	return &Actor{UserID: user.ID.String(), Role: user.Role}, nil
+}
+
+// actedBy names who took a decision, including the approver a substitute stood in for
+func actedBy(name string, onBehalfOf *models.Delegation) string {
+	if onBehalfOf == nil {
+		return name
+	}
+	return fmt.Sprintf("%s on behalf of %s", name, onBehalfOf.DelegatorName)
+}
+
+// statusVerb describes the decision behind a status change in the status history
+func statusVerb(status string) string {
+	switch status {
+	case models.StatusDisetujui:
+		return "approved"
+	case models.StatusDitolak:
+		return "rejected"
+	case models.StatusDiproses:
+		return "accepted"
+	case models.StatusSelesai:
+		return "completed"
+	default:
+		return "moved to " + status
+	}
+}
+
+// GetDelegations returns the delegations given by or to the actor; holders of approval.manage see all
+func (s *Service) GetDelegations(actor *Actor, includeExpired bool) ([]models.Delegation, error) {
+	manage, err := s.can(actor, models.PermissionApprovalManage)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := actor.UserID
+	if manage {
+		userID = ""
+	}
+
+	today := time.Now()
+	delegations, err := s.repo.GetDelegations(userID, includeExpired, today)
+	if err != nil {
+		return nil, err
+	}
+	for i := range delegations {
+		delegations[i].Status = DelegationStatus(delegations[i].StartDate, delegations[i].EndDate, today)
+	}
+	return delegations, nil
+}
+
+// CreateDelegation registers a substitute for the actor or, with approval.manage, for another approver
+func (s *Service) CreateDelegation(req *models.DelegationRequest, actor *Actor) (*models.Delegation, error) {
+	delegatorID := actor.UserID
+	if req.DelegatorID != "" && req.DelegatorID != actor.UserID {
+		manage, err := s.can(actor, models.PermissionApprovalManage)
+		if err != nil {
+			return nil, err
+		}
+		if !manage {
+			return nil, &ForbiddenError{Action: ActionDelegate, Reason: "you can only register substitutes for yourself"}
+		}
+		delegatorID = req.DelegatorID
+	}
+
+	delegator, err := s.activeUserID(delegatorID, "approver")
+	if err != nil {
+		return nil, err
+	}
+	substitute, err := s.activeUserID(req.SubstituteID, "substitute")
+	if err != nil {
+		return nil, err
+	}
+	if *delegator == *substitute {
+		return nil, errors.New("an approver cannot be their own substitute")
+	}
+
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date format: %v", err)
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date format: %v", err)
+	}
+	if end.Before(start) {
+		return nil, errors.New("the end date cannot be before the start date")
+	}
+	if DelegationStatus(start, end, time.Now()) == models.DelegationExpired {
+		return nil, errors.New("the end date has already passed")
+	}
+
+	overlap, err := s.repo.HasOverlappingDelegation(delegator.String(), start, end)
+	if err != nil {
+		return nil, err
+	}
+	if overlap {
+		return nil, ErrDelegationOverlap
+	}
+
+	delegation := &models.Delegation{
+		DelegatorID:  *delegator,
+		SubstituteID: *substitute,
+		StartDate:    start,
+		EndDate:      end,
+	}
+	if req.Reason != "" {
+		delegation.Reason = &req.Reason
+	}
+	if createdBy, err := uuid.Parse(actor.UserID); err == nil {
+		delegation.CreatedBy = &createdBy
+	}
+
+	if err := s.repo.CreateDelegation(delegation); err != nil {
+		return nil, err
+	}
+	log.Printf("Delegation %d: %s substitutes for %s from %s to %s", delegation.ID, substitute, delegator, req.StartDate, req.EndDate)
+
+	created, err := s.repo.GetDelegationByID(fmt.Sprint(delegation.ID))
+	if err != nil {
+		return nil, err
+	}
+	created.Status = DelegationStatus(created.StartDate, created.EndDate, time.Now())
+	return created, nil
+}
+
+// DeleteDelegation withdraws a delegation; the approver, whoever registered it and holders of
+// approval.manage may do so
+func (s *Service) DeleteDelegation(id string, actor *Actor) error {
+	delegation, err := s.repo.GetDelegationByID(id)
+	if err != nil {
+		return err
+	}
+
+	registeredBy := delegation.CreatedBy != nil && delegation.CreatedBy.String() == actor.UserID
+	if delegation.DelegatorID.String() != actor.UserID && !registeredBy {
+		manage, err := s.can(actor, models.PermissionApprovalManage)
+		if err != nil {
+			return err
+		}
+		if !manage {
+			return &ForbiddenError{Action: ActionDelegate, Reason: "you can only withdraw your own delegations"}
+		}
+	}
+
+	if err := s.repo.DeleteDelegation(id); err != nil {
+		return err
+	}
+	log.Printf("Delegation %s withdrawn by %s", id, actor.UserID)
+	return nil
+}
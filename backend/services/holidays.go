@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+	"web-work-request-backend/calendar"
+	"web-work-request-backend/models"
+)
+
+// GetHolidays returns the holidays of a year, or all of them when year is zero
+func (s *Service) GetHolidays(year int) ([]models.Holiday, error) {
+	return s.repo.GetHolidays(year)
+}
+
+// SaveHoliday adds a holiday or renames an existing one
+func (s *Service) SaveHoliday(req *models.HolidayRequest) (*models.Holiday, error) {
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holiday date format: %v", err)
+	}
+
+	holidays := []models.Holiday{{Date: date, Name: req.Name}}
+	if err := s.saveHolidays(holidays); err != nil {
+		return nil, err
+	}
+	return &holidays[0], nil
+}
+
+// ImportHolidays adds the events of an iCalendar file as holidays and returns them
+func (s *Service) ImportHolidays(r io.Reader, actor *Actor) ([]models.Holiday, error) {
+	holidays, err := calendar.ParseICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveHolidays(holidays); err != nil {
+		return nil, err
+	}
+
+	log.Printf("%d holidays imported by %s", len(holidays), actor.UserID)
+	return holidays, nil
+}
+
+func (s *Service) DeleteHoliday(date string) error {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return fmt.Errorf("invalid holiday date format: %v", err)
+	}
+
+	if err := s.repo.DeleteHoliday(date); err != nil {
+		return err
+	}
+	s.calendar.Invalidate()
+	return nil
+}
+
+// saveHolidays stores holidays; due dates use them from the next calculation on
+func (s *Service) saveHolidays(holidays []models.Holiday) error {
+	if err := s.repo.SaveHolidays(holidays); err != nil {
+		return err
+	}
+	s.calendar.Invalidate()
+	return nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"web-work-request-backend/models"
+)
+
+var (
+	// ErrAccountNotActive is returned when a user whose account is not active tries to sign in
+	ErrAccountNotActive = errors.New("account is not active")
+	// ErrUserStatus is returned when a lifecycle action does not apply to the user's current status
+	ErrUserStatus = errors.New("the action does not apply to the user's current status")
+)
+
+// checkActive refuses users who may not sign in; pending and deactivated accounts get a message that says why
+func checkActive(user *models.User) error {
+	switch user.Status {
+	case models.UserStatusActive:
+		return nil
+	case models.UserStatusPending:
+		return fmt.Errorf("%w: registration is waiting for approval", ErrAccountNotActive)
+	case models.UserStatusDeactivated:
+		return fmt.Errorf("%w: account has been deactivated", ErrAccountNotActive)
+	default:
+		return ErrAccountNotActive
+	}
+}
+
+// manageableUser loads a user the actor may change the lifecycle of; nobody can act on their own account
+func (s *Service) manageableUser(id string, actor *Actor) (*models.User, error) {
+	user, err := s.repo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.ID.String() == actor.UserID {
+		return nil, &ForbiddenError{Action: models.PermissionUserManage, Reason: "you cannot change the status of your own account"}
+	}
+	if err := s.authorizeManageRole(actor, user.Role); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// setUserStatus moves a user between statuses, mapping a concurrent change to ErrUserStatus
+func (s *Service) setUserStatus(user *models.User, to string) (*models.User, error) {
+	from := user.Status
+	if err := s.repo.SetUserStatus(user.ID.String(), from, to); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserStatus
+		}
+		return nil, err
+	}
+
+	user.Status = to
+	return user, nil
+}
+
+// DeactivateUser blocks an active user from signing in and ends their sessions; the account keeps its data
+func (s *Service) DeactivateUser(id string, actor *Actor) (*models.User, error) {
+	user, err := s.manageableUser(id, actor)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, ErrUserStatus
+	}
+
+	if user, err = s.setUserStatus(user, models.UserStatusDeactivated); err != nil {
+		return nil, err
+	}
+	if err := s.repo.RevokeUserSessions(id); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s deactivated by %s", user.Username, actor.UserID)
+	return user, nil
+}
+
+// ReactivateUser lets a deactivated user sign in again
+func (s *Service) ReactivateUser(id string, actor *Actor) (*models.User, error) {
+	user, err := s.manageableUser(id, actor)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != models.UserStatusDeactivated {
+		return nil, ErrUserStatus
+	}
+
+	if user, err = s.setUserStatus(user, models.UserStatusActive); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s reactivated by %s", user.Username, actor.UserID)
+	return user, nil
+}
+
+// RestoreUser brings a deleted user back as an active account
+func (s *Service) RestoreUser(id string, actor *Actor) (*models.User, error) {
+	user, err := s.manageableUser(id, actor)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != models.UserStatusDeleted {
+		return nil, ErrUserStatus
+	}
+
+	if err := s.repo.RestoreUser(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserStatus
+		}
+		return nil, err
+	}
+
+	log.Printf("User %s restored by %s", user.Username, actor.UserID)
+	return s.repo.GetUserByID(id)
+}
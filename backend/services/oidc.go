@@ -0,0 +1,218 @@
+package services
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"web-work-request-backend/config"
+	"web-work-request-backend/directory"
+	"web-work-request-backend/models"
+	"web-work-request-backend/oidc"
+	"web-work-request-backend/utils"
+)
+
+// oidcLoginTTL is how long a user has to sign in at the identity provider
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	// ErrOIDCDisabled is returned when single sign-on is used without OIDC_ISSUER
+	ErrOIDCDisabled = errors.New("single sign-on is not configured")
+	// ErrInvalidOIDCLogin is returned for an unknown, expired or replayed callback, or a rejected ID token
+	ErrInvalidOIDCLogin = errors.New("invalid or expired single sign-on login")
+	// ErrUnusableOIDCIdentity is returned when the provider's claims cannot be mapped onto an account
+	ErrUnusableOIDCIdentity = errors.New("the identity provider account cannot be used to sign in")
+)
+
+// SingleSignOn maps a provider's ID token claims onto users
+type SingleSignOn struct {
+	provider      *oidc.Provider
+	roles         directory.RoleMapping
+	usernameClaim string
+	unitClaim     string
+	groupsClaim   string
+}
+
+// NewSingleSignOn creates single sign-on from the OIDC_* settings; it returns nil when OIDC_ISSUER is not set
+func NewSingleSignOn(cfg *config.Config) (*SingleSignOn, error) {
+	if !cfg.OIDCEnabled() {
+		return nil, nil
+	}
+
+	roles, err := directory.ParseRoleMapping(cfg.OIDCGroupRoles, cfg.OIDCDefaultRole)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SingleSignOn{
+		provider:      oidc.NewProvider(cfg),
+		roles:         roles,
+		usernameClaim: cfg.OIDCUsernameClaim,
+		unitClaim:     cfg.OIDCUnitClaim,
+		groupsClaim:   cfg.OIDCGroupsClaim,
+	}, nil
+}
+
+// WithSingleSignOn enables OpenID Connect logins; a nil sso leaves them disabled
+func (s *Service) WithSingleSignOn(sso *SingleSignOn) *Service {
+	s.sso = sso
+	return s
+}
+
+// StartOIDCLogin creates a pending login and returns the provider URL to send the browser to, and the state
+// the browser must present again at the callback
+func (s *Service) StartOIDCLogin() (string, string, error) {
+	if s.sso == nil {
+		return "", "", ErrOIDCDisabled
+	}
+
+	state, stateHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, _, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.sso.provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	pending := &models.OIDCLoginState{
+		StateHash:    stateHash,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}
+	if err := s.repo.CreateOIDCLoginState(pending); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteOIDCLogin finishes a login at the callback. state is the callback's state parameter and
+// browserState the one stored in the browser when the login started; they must match so a login started
+// by somebody else cannot be completed in this browser.
+func (s *Service) CompleteOIDCLogin(state, browserState, code string) (*models.AuthResponse, error) {
+	if s.sso == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	if state == "" || code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, ErrInvalidOIDCLogin
+	}
+
+	pending, err := s.repo.TakeOIDCLoginState(utils.HashOpaqueToken(state))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidOIDCLogin
+		}
+		return nil, err
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, ErrInvalidOIDCLogin
+	}
+
+	rawIDToken, err := s.sso.provider.Exchange(code, pending.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.sso.provider.VerifyIDToken(rawIDToken, pending.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidIDToken) {
+			log.Printf("Single sign-on rejected: %v", err)
+			return nil, ErrInvalidOIDCLogin
+		}
+		return nil, err
+	}
+
+	user, err := s.oidcUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
+
+	// Local 2FA still applies to accounts that enabled it or whose role requires it
+	challenge, err := s.twoFactorChallenge(user)
+	if err != nil || challenge != nil {
+		return challenge, err
+	}
+	return s.issueTokens(user)
+}
+
+// oidcUser finds the account of a provider subject, provisioning it on the first login and refreshing the
+// profile and role from the claims on later ones
+func (s *Service) oidcUser(claims oidc.Claims) (*models.User, error) {
+	subject := claims.Subject()
+
+	email := claims.String("email")
+	if email == "" || !claims.EmailVerified() {
+		return nil, fmt.Errorf("%w: no verified email address", ErrUnusableOIDCIdentity)
+	}
+
+	username := claims.String(s.sso.usernameClaim)
+	if username == "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+	name := claims.String("name")
+	if name == "" {
+		name = username
+	}
+	unit, unitID, err := externalUnit(s.repo, claims.String(s.sso.unitClaim))
+	if err != nil {
+		return nil, err
+	}
+
+	role := s.sso.roles.Role(claims.Strings(s.sso.groupsClaim))
+	if err := s.validateRole(role); err != nil {
+		return nil, fmt.Errorf("OIDC group mapping: %w", err)
+	}
+
+	user, err := s.repo.GetUserByExternalID(models.AuthSourceOIDC, subject)
+	if err == nil {
+		if err := syncExternalUser(s.repo, user, name, email, unit, role); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// An existing account with the same username is never taken over by a single sign-on login
+	if existing, _ := s.repo.GetUserByUsername(username); existing != nil {
+		return nil, fmt.Errorf("%w: username %s is already taken", ErrUnusableOIDCIdentity, username)
+	}
+
+	user = &models.User{
+		Username: username,
+		// No local password: bcrypt never matches an empty hash
+		PasswordHash: "",
+		Name:         name,
+		Email:        email,
+		Unit:         unit,
+		UnitID:       unitID,
+		Role:         role,
+		AuthSource:   models.AuthSourceOIDC,
+		ExternalID:   &subject,
+	}
+	if err := s.repo.CreateUser(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned single sign-on user %s with role %s", user.Username, user.Role)
+	return user, nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"web-work-request-backend/config"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+	"web-work-request-backend/utils"
+)
+
+// ErrWrongPassword is returned when the current password given to change a password does not match
+var ErrWrongPassword = errors.New("current password is incorrect")
+
+// ErrInvalidResetToken is returned when a password reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordPolicy describes the passwords users may choose
+type PasswordPolicy struct {
+	MinLength int
+	// RequiredClasses lists character classes: lower, upper, digit and symbol
+	RequiredClasses []string
+	// History is how many previous passwords cannot be reused
+	History int
+}
+
+// NewPasswordPolicy builds the policy from the configuration
+func NewPasswordPolicy(cfg *config.Config) PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:       cfg.PasswordMinLength,
+		RequiredClasses: cfg.PasswordRequiredClasses,
+		History:         cfg.PasswordHistory,
+	}
+}
+
+// PasswordPolicyError lists every rule a password breaks
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Problems, "; ")
+}
+
+var passwordClassCheckers = map[string]struct {
+	description string
+	matches     func(rune) bool
+}{
+	"lower":  {"a lowercase letter", unicode.IsLower},
+	"upper":  {"an uppercase letter", unicode.IsUpper},
+	"digit":  {"a digit", unicode.IsDigit},
+	"symbol": {"a symbol", func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) }},
+}
+
+// Validate checks length and character classes; reuse is checked separately against the stored history
+func (p PasswordPolicy) Validate(password string) error {
+	var problems []string
+
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	for _, class := range p.RequiredClasses {
+		checker, ok := passwordClassCheckers[class]
+		if !ok {
+			continue
+		}
+		if !strings.ContainsFunc(password, checker.matches) {
+			problems = append(problems, "must contain "+checker.description)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &PasswordPolicyError{Problems: problems}
+	}
+	return nil
+}
+
+// checkPasswordReuse refuses the user's current password and the passwords kept in their history
+func (s *Service) checkPasswordReuse(user *models.User, password string) error {
+	if s.passwords.History <= 0 {
+		return nil
+	}
+
+	hashes, err := s.repo.GetPasswordHistory(user.ID.String(), s.passwords.History)
+	if err != nil {
+		return err
+	}
+	hashes = append(hashes, user.PasswordHash)
+
+	for _, hash := range hashes {
+		if utils.CheckPasswordHash(password, hash) {
+			return &PasswordPolicyError{Problems: []string{
+				fmt.Sprintf("must not be the current password or one of the last %d passwords", s.passwords.History),
+			}}
+		}
+	}
+	return nil
+}
+
+// validateNewPassword applies the full policy, including reuse, to a new password for an existing user
+func (s *Service) validateNewPassword(user *models.User, password string) error {
+	if err := s.passwords.Validate(password); err != nil {
+		return err
+	}
+	return s.checkPasswordReuse(user, password)
+}
+
+// storePassword saves a validated password, revoking every session of the user
+func (s *Service) storePassword(user *models.User, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.ChangePassword(user.ID.String(), hash, s.passwords.History)
+}
+
+// ChangePassword lets a user change their own password; it returns new tokens because every existing
+// session, including the caller's, is revoked
+func (s *Service) ChangePassword(userID string, req *models.ChangePasswordRequest) (*models.AuthResponse, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.AuthSource != models.AuthSourceLocal {
+		return nil, ErrExternalAccount
+	}
+
+	if !utils.CheckPasswordHash(req.OldPassword, user.PasswordHash) {
+		return nil, ErrWrongPassword
+	}
+
+	if err := s.validateNewPassword(user, req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := s.storePassword(user, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	// Reload to pick up the new token version
+	user, err = s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user)
+}
+
+// CreatePasswordReset issues a one-time token the user can use to set a new password; any earlier
+// unused token for the user stops working
+func (s *Service) CreatePasswordReset(userID string, actor *Actor) (string, *models.PasswordResetToken, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := s.authorizeManageRole(actor, user.Role); err != nil {
+		return "", nil, err
+	}
+	if user.AuthSource != models.AuthSourceLocal {
+		return "", nil, ErrExternalAccount
+	}
+
+	if err := s.repo.DeleteUserPasswordResetTokens(userID); err != nil {
+		return "", nil, err
+	}
+
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	reset := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		CreatedBy: &actor.UserID,
+		ExpiresAt: time.Now().Add(s.config.PasswordResetTTL()),
+	}
+	if err := s.repo.CreatePasswordResetToken(reset); err != nil {
+		return "", nil, err
+	}
+
+	return token, reset, nil
+}
+
+// ResetPassword sets a new password with a reset token; it also lifts any login lockout
+func (s *Service) ResetPassword(req *models.ResetPasswordRequest) error {
+	reset, err := s.repo.GetPasswordResetTokenByHash(utils.HashOpaqueToken(req.Token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.repo.GetUserByID(reset.UserID.String())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// Validate before using up the token so a rejected password can be retried with the same token
+	if err := s.validateNewPassword(user, req.NewPassword); err != nil {
+		return err
+	}
+
+	if err := s.repo.UsePasswordResetToken(reset.ID.String()); err != nil {
+		if errors.Is(err, repository.ErrTokenRevoked) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if err := s.storePassword(user, req.NewPassword); err != nil {
+		return err
+	}
+
+	return s.userLogins.Reset(userLoginKey(user.Username))
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"web-work-request-backend/models"
+)
+
+// ErrRegistrationDisabled is returned when somebody signs up while SELF_REGISTRATION is off
+var ErrRegistrationDisabled = errors.New("self-registration is disabled")
+
+// GetPendingRegistrations returns the self-registered accounts waiting for approval, oldest first
+func (s *Service) GetPendingRegistrations() ([]models.User, error) {
+	return s.repo.GetUsersByStatus(models.UserStatusPending)
+}
+
+// ApproveRegistration activates a pending account so its user can sign in
+func (s *Service) ApproveRegistration(id string, actor *Actor) (*models.User, error) {
+	if err := s.repo.SetUserStatus(id, models.UserStatusPending, models.UserStatusActive); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Registration of user %s approved by %s", id, actor.UserID)
+	return s.repo.GetUserByID(id)
+}
+
+// RejectRegistration removes a pending account; it never signed in, so nothing refers to it and the
+// username and email can be used again
+func (s *Service) RejectRegistration(id string, actor *Actor) error {
+	if err := s.repo.DeleteUserWithStatus(id, models.UserStatusPending); err != nil {
+		return err
+	}
+
+	log.Printf("Registration of user %s rejected by %s", id, actor.UserID)
+	return nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"web-work-request-backend/models"
+)
+
+// ErrUnknownRole is returned when a user is assigned a role that does not exist
+var ErrUnknownRole = errors.New("unknown role")
+
+// ErrUnknownPermission is returned when a role is granted a permission that does not exist
+var ErrUnknownPermission = errors.New("unknown permission")
+
+func (s *Service) validateRole(role string) error {
+	exists, err := s.repo.RoleExists(role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+	return nil
+}
+
+// GetRoles returns every role with the permissions it grants
+func (s *Service) GetRoles() ([]models.Role, error) {
+	return s.repo.GetRoles()
+}
+
+// GetPermissions returns every permission that can be granted to a role
+func (s *Service) GetPermissions() ([]models.Permission, error) {
+	return s.repo.GetPermissions()
+}
+
+// UpsertRole creates a role or replaces the permissions of an existing one
+func (s *Service) UpsertRole(name string, req *models.UpsertRoleRequest, actor *Actor) (*models.Role, error) {
+	known, err := s.repo.GetPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	valid := make(map[string]bool, len(known))
+	for _, permission := range known {
+		valid[permission.Name] = true
+	}
+
+	for _, permission := range req.Permissions {
+		if !valid[permission] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, permission)
+		}
+		// Nobody can grant a permission they do not hold themselves
+		allowed, err := s.can(actor, permission)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, &ForbiddenError{Action: models.PermissionRoleManage, Reason: "you cannot grant permission " + permission + " because you do not have it"}
+		}
+	}
+
+	// Changing the actor's own role could lock every administrator out
+	if name == actor.Role {
+		return nil, &ForbiddenError{Action: models.PermissionRoleManage, Reason: "you cannot change the permissions of your own role"}
+	}
+
+	role := &models.Role{Name: name, Description: req.Description, Permissions: req.Permissions}
+	if err := s.repo.UpsertRole(role); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
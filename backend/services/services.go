@@ -4,27 +4,84 @@ import (
 	"errors"
 	"fmt"
 	"time"
+	"web-work-request-backend/calendar"
+	"web-work-request-backend/config"
 	"web-work-request-backend/models"
 	"web-work-request-backend/repository"
+	"web-work-request-backend/throttle"
 	"web-work-request-backend/utils"
+
+	"github.com/google/uuid"
 )
 
+// ErrInvalidFilter is returned when request list filters cannot be applied
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ErrRequestClosed is returned when an item of a request that is rejected or finished is decided
+var ErrRequestClosed = errors.New("the items of a closed request can no longer be decided")
+
 type Service struct {
-	repo *repository.Repository
+	repo   *repository.Repository
+	config *config.Config
+	keys   *utils.KeyManager
+
+	// userLogins and ipLogins throttle failed logins per username and per client IP
+	userLogins *throttle.Limiter
+	ipLogins   *throttle.Limiter
+
+	passwords PasswordPolicy
+
+	// authenticators are tried in order at login
+	authenticators []Authenticator
+	// sso is nil when OpenID Connect single sign-on is not configured
+	sso *SingleSignOn
+
+	// calendar provides the holidays skipped when due dates are computed
+	calendar *calendar.Provider
 }
 
-func NewService(repo *repository.Repository) *Service {
-	return &Service{repo: repo}
+// NewService creates the service; without authenticators, logins use local passwords only
+func NewService(repo *repository.Repository, cfg *config.Config, keys *utils.KeyManager, authenticators ...Authenticator) *Service {
+	if len(authenticators) == 0 {
+		authenticators = []Authenticator{NewLocalAuthenticator(repo)}
+	}
+
+	return &Service{
+		repo:           repo,
+		config:         cfg,
+		keys:           keys,
+		userLogins:     throttle.NewLimiter(repo, userLoginPolicy(cfg)),
+		ipLogins:       throttle.NewLimiter(repo, ipLoginPolicy),
+		passwords:      NewPasswordPolicy(cfg),
+		authenticators: authenticators,
+		calendar:       calendar.NewProvider(repo, cfg.Location()),
+	}
 }
 
 // UserService methods
+
+// RegisterUser signs a user up. The account gets the user role and stays pending until an operator
+// approves it.
 func (s *Service) RegisterUser(req *models.RegisterRequest) (*models.User, error) {
+	if !s.config.SelfRegistration {
+		return nil, ErrRegistrationDisabled
+	}
+
 	// Check if username already exists
 	existingUser, _ := s.repo.GetUserByUsername(req.Username)
 	if existingUser != nil {
 		return nil, errors.New("username already exists")
 	}
 
+	unit, err := s.resolveUnit(req.UnitID, req.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.passwords.Validate(req.Password); err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -37,8 +94,10 @@ func (s *Service) RegisterUser(req *models.RegisterRequest) (*models.User, error
 		PasswordHash: hashedPassword,
 		Name:         req.Name,
 		Email:        req.Email,
-		Unit:         req.Unit,
-		Role:         req.Role,
+		Unit:         unit.Name,
+		UnitID:       &unit.ID,
+		Role:         models.RoleUser,
+		Status:       models.UserStatusPending,
 	}
 
 	err = s.repo.CreateUser(user)
@@ -67,8 +126,12 @@ func (s *Service) GetDashboardStats(userID string) (*models.DashboardStats, erro
 	stats.TotalPengajuan, _ = s.repo.GetRequestCount()
 	stats.TotalRiwayat, _ = s.repo.GetRequestCount()
 
-	// Operator-specific stats
-	if user.Role == "operator" {
+	// Stats for roles allowed to view reports
+	canViewReports, err := s.HasPermission(user.Role, models.PermissionReportView)
+	if err != nil {
+		return nil, err
+	}
+	if canViewReports {
 		stats.TotalPersetujuan, _ = s.repo.GetPendingRequestCount()
 		stats.TotalPengguna, _ = s.repo.GetUserCount()
 	}
@@ -76,39 +139,23 @@ func (s *Service) GetDashboardStats(userID string) (*models.DashboardStats, erro
 	return stats, nil
 }
 
-func (s *Service) LoginUser(req *models.LoginRequest) (*models.AuthResponse, error) {
-	// Get user by username
-	user, err := s.repo.GetUserByUsername(req.Username)
-	if err != nil {
-		return nil, errors.New("invalid credentials")
-	}
-
-	// Check password
-	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
-		return nil, errors.New("invalid credentials")
-	}
-
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID.String(), user.Username, user.Role)
-	if err != nil {
-		return nil, err
-	}
-
-	return &models.AuthResponse{
-		Token: token,
-		User:  *user,
-	}, nil
-}
-
-func (s *Service) GetAllUsers() ([]models.User, error) {
-	return s.repo.GetAllUsers()
+// GetAllUsers lists users; deleted users are only included when asked for
+func (s *Service) GetAllUsers(includeDeleted bool) ([]models.User, error) {
+	return s.repo.GetAllUsers(includeDeleted)
 }
 
 func (s *Service) GetUserByID(id string) (*models.User, error) {
 	return s.repo.GetUserByID(id)
 }
 
-func (s *Service) CreateUser(req *models.CreateUserRequest) (*models.User, error) {
+func (s *Service) CreateUser(req *models.CreateUserRequest, actor *Actor) (*models.User, error) {
+	if err := s.validateRole(req.Role); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeManageRole(actor, req.Role); err != nil {
+		return nil, err
+	}
+
 	// Check if username already exists
 	existingUser, _ := s.repo.GetUserByUsername(req.Username)
 	if existingUser != nil {
@@ -121,6 +168,15 @@ func (s *Service) CreateUser(req *models.CreateUserRequest) (*models.User, error
 		return nil, errors.New("email already exists")
 	}
 
+	unit, err := s.resolveUnit(req.UnitID, req.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.passwords.Validate(req.Password); err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -133,7 +189,8 @@ func (s *Service) CreateUser(req *models.CreateUserRequest) (*models.User, error
 		PasswordHash: hashedPassword,
 		Name:         req.Name,
 		Email:        req.Email,
-		Unit:         req.Unit,
+		Unit:         unit.Name,
+		UnitID:       &unit.ID,
 		Role:         req.Role,
 	}
 
@@ -145,13 +202,43 @@ func (s *Service) CreateUser(req *models.CreateUserRequest) (*models.User, error
 	return user, nil
 }
 
-func (s *Service) UpdateUser(id string, req *models.UpdateUserRequest) (*models.User, error) {
-	// Get existing user
+// UpdateUser applies an administrative update; the actor must outrank both the user's current and new role
+func (s *Service) UpdateUser(id string, req *models.UpdateUserRequest, actor *Actor) (*models.User, error) {
 	existingUser, err := s.repo.GetUserByID(id)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.authorizeManageRole(actor, existingUser.Role); err != nil {
+		return nil, err
+	}
+	if req.Role != "" && req.Role != existingUser.Role {
+		if err := s.validateRole(req.Role); err != nil {
+			return nil, err
+		}
+		if err := s.authorizeManageRole(actor, req.Role); err != nil {
+			return nil, err
+		}
+	}
+
+	roleChanged := req.Role != "" && req.Role != existingUser.Role
+
+	user, err := s.updateUser(existingUser, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// A demoted user must not keep using tokens that were issued for the old role
+	if roleChanged {
+		if err := s.repo.RevokeUserSessions(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *Service) updateUser(existingUser *models.User, req *models.UpdateUserRequest) (*models.User, error) {
 	// Update fields if provided
 	if req.Name != "" {
 		existingUser.Name = req.Name
@@ -166,15 +253,19 @@ func (s *Service) UpdateUser(id string, req *models.UpdateUserRequest) (*models.
 		}
 		existingUser.Email = req.Email
 	}
-	if req.Unit != "" {
-		existingUser.Unit = req.Unit
+	if req.UnitID != "" || req.Unit != "" {
+		unit, err := s.resolveUnit(req.UnitID, req.Unit)
+		if err != nil {
+			return nil, err
+		}
+		existingUser.Unit, existingUser.UnitID = unit.Name, &unit.ID
 	}
 	if req.Role != "" {
 		existingUser.Role = req.Role
 	}
 
 	// Update user
-	err = s.repo.UpdateUser(existingUser)
+	err := s.repo.UpdateUser(existingUser)
 	if err != nil {
 		return nil, err
 	}
@@ -182,14 +273,28 @@ func (s *Service) UpdateUser(id string, req *models.UpdateUserRequest) (*models.
 	return existingUser, nil
 }
 
-func (s *Service) DeleteUser(id string) error {
-	// Check if user exists
-	_, err := s.repo.GetUserByID(id)
+// UpdateProfile applies self-service changes; unit and role can only be changed by an operator
+func (s *Service) UpdateProfile(userID string, req *models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateUser(user, &models.UpdateUserRequest{Name: req.Name, Email: req.Email})
+}
+
+// DeleteUser soft-deletes a user: the account is hidden and cannot sign in, but stays referenced by the
+// requests the user made and decided, and can be restored
+func (s *Service) DeleteUser(id string, actor *Actor) error {
+	user, err := s.manageableUser(id, actor)
 	if err != nil {
 		return err
 	}
+	if user.Status == models.UserStatusDeleted {
+		return ErrUserStatus
+	}
 
-	// Delete user
+	// Deleting revokes the refresh tokens, and the access tokens fail the session check
 	return s.repo.DeleteUser(id)
 }
 
@@ -226,10 +331,48 @@ func (s *Service) CreateRequest(req *models.CreateRequestRequest, userID string)
 		tglPengembalian = &parsed
 	}
 
+	// Parallel item arrays must describe the same number of items
+	if err := validateItemArrays(req); err != nil {
+		return nil, err
+	}
+
+	tglPeminjamanArray, err := parseDates(req.TglPeminjamanArray)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peminjaman date format: %v", err)
+	}
+
+	tglPengembalianArray, err := parseDates(req.TglPengembalianArray)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pengembalian date format: %v", err)
+	}
+
+	unit, err := s.resolveUnit(req.UnitID, req.Unit)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create request
 	request := &models.Request{
-		JenisRequest:    req.JenisRequest,
-		Unit:            req.Unit,
+		JenisRequest: req.JenisRequest,
+		Unit:         unit.Name,
+		UnitID:       &unit.ID,
+
+		NamaBarangArray: req.NamaBarangArray,
+		TypeModelArray:  req.TypeModelArray,
+		JumlahArray:     req.JumlahArray,
+		KeteranganArray: req.KeteranganArray,
+
+		NamaBarangPerbaikanArray: req.NamaBarangPerbaikanArray,
+		TypeModelPerbaikanArray:  req.TypeModelPerbaikanArray,
+		JumlahPerbaikanArray:     req.JumlahPerbaikanArray,
+		JenisPekerjaanArray:      req.JenisPekerjaanArray,
+		LokasiPerbaikanArray:     req.LokasiPerbaikanArray,
+
+		LokasiPeminjamanArray: req.LokasiPeminjamanArray,
+		KegunaanArray:         req.KegunaanArray,
+		TglPeminjamanArray:    tglPeminjamanArray,
+		TglPengembalianArray:  tglPengembalianArray,
+
 		NamaBarang:      req.NamaBarang,
 		TypeModel:       req.TypeModel,
 		Jumlah:          req.Jumlah,
@@ -240,12 +383,24 @@ func (s *Service) CreateRequest(req *models.CreateRequestRequest, userID string)
 		TglPeminjaman:   tglPeminjaman,
 		TglPengembalian: tglPengembalian,
 		Keterangan:      req.Keterangan,
-		StatusRequest:   "DIAJUKAN",
+		StatusRequest:   models.StatusDiajukan,
 		RequestedBy:     user.Name,
+		RequestedByID:   &userID,
+	}
+
+	request.Items = buildRequestItems(request)
+	if err := priceItems(request, req); err != nil {
+		return nil, err
+	}
+	request.NilaiEstimasi = EstimatedValue(request.Items)
+
+	request.Approvals, err = s.approvalsFor(request)
+	if err != nil {
+		return nil, err
 	}
 
 	// Save to database
-	err = s.repo.CreateRequest(request)
+	err = s.repo.CreateRequest(request, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -253,47 +408,424 @@ func (s *Service) CreateRequest(req *models.CreateRequestRequest, userID string)
 	return request, nil
 }
 
-func (s *Service) GetRequestByID(id string) (*models.Request, error) {
-	return s.repo.GetRequestByID(id)
+// validateItemArrays checks that each group of parallel item arrays has one entry per item. A group that
+// is omitted entirely is allowed; once any of its arrays is given, its required arrays must be given too.
+func validateItemArrays(req *models.CreateRequestRequest) error {
+	type itemArray struct {
+		name     string
+		length   int
+		required bool
+	}
+	groups := []struct {
+		name   string
+		arrays []itemArray
+	}{
+		{"pengadaan", []itemArray{
+			{"nama_barang_array", len(req.NamaBarangArray), true},
+			{"type_model_array", len(req.TypeModelArray), false},
+			{"jumlah_array", len(req.JumlahArray), true},
+			{"keterangan_array", len(req.KeteranganArray), false},
+		}},
+		{"perbaikan", []itemArray{
+			{"nama_barang_perbaikan_array", len(req.NamaBarangPerbaikanArray), true},
+			{"type_model_perbaikan_array", len(req.TypeModelPerbaikanArray), false},
+			{"jumlah_perbaikan_array", len(req.JumlahPerbaikanArray), true},
+			{"jenis_pekerjaan_array", len(req.JenisPekerjaanArray), false},
+			{"lokasi_perbaikan_array", len(req.LokasiPerbaikanArray), true},
+		}},
+		{"peminjaman", []itemArray{
+			{"lokasi_peminjaman_array", len(req.LokasiPeminjamanArray), true},
+			{"kegunaan_array", len(req.KegunaanArray), false},
+			{"tgl_peminjaman_array", len(req.TglPeminjamanArray), false},
+			{"tgl_pengembalian_array", len(req.TglPengembalianArray), false},
+		}},
+	}
+
+	for _, group := range groups {
+		expected := 0
+		for _, array := range group.arrays {
+			if array.length == 0 {
+				continue
+			}
+			if expected == 0 {
+				expected = array.length
+			} else if array.length != expected {
+				return fmt.Errorf("%s item arrays must all have the same length", group.name)
+			}
+		}
+		if expected == 0 {
+			continue
+		}
+		for _, array := range group.arrays {
+			if array.required && array.length == 0 {
+				return fmt.Errorf("%s is required for %s items", array.name, group.name)
+			}
+		}
+	}
+
+	for _, jumlah := range append(append([]int{}, req.JumlahArray...), req.JumlahPerbaikanArray...) {
+		if jumlah < 1 {
+			return errors.New("item quantity must be at least 1")
+		}
+	}
+
+	for _, harga := range req.HargaSatuanArray {
+		if harga < 0 {
+			return errors.New("item price cannot be negative")
+		}
+	}
+	if req.HargaSatuan != nil && *req.HargaSatuan < 0 {
+		return errors.New("item price cannot be negative")
+	}
+
+	return nil
 }
 
-func (s *Service) GetAllRequests() ([]models.Request, error) {
-	return s.repo.GetAllRequests()
+// buildRequestItems turns the item arrays of a request into line items.
+// Requests that only use the legacy single fields get one item built from those.
+func buildRequestItems(request *models.Request) []models.RequestItem {
+	items := []models.RequestItem{}
+	newItem := func() models.RequestItem {
+		return models.RequestItem{ItemNo: len(items) + 1, JumlahDiminta: 1, StatusItem: models.StatusDiajukan}
+	}
+
+	switch request.JenisRequest {
+	case models.JenisPengadaan:
+		for i := range request.NamaBarangArray {
+			item := newItem()
+			item.NamaBarang = &request.NamaBarangArray[i]
+			item.TypeModel = stringAt(request.TypeModelArray, i)
+			item.Keterangan = stringAt(request.KeteranganArray, i)
+			if i < len(request.JumlahArray) {
+				item.JumlahDiminta = request.JumlahArray[i]
+			}
+			items = append(items, item)
+		}
+	case models.JenisPerbaikan:
+		for i := range request.NamaBarangPerbaikanArray {
+			item := newItem()
+			item.NamaBarang = &request.NamaBarangPerbaikanArray[i]
+			item.TypeModel = stringAt(request.TypeModelPerbaikanArray, i)
+			item.JenisPekerjaan = stringAt(request.JenisPekerjaanArray, i)
+			item.Lokasi = stringAt(request.LokasiPerbaikanArray, i)
+			if i < len(request.JumlahPerbaikanArray) {
+				item.JumlahDiminta = request.JumlahPerbaikanArray[i]
+			}
+			items = append(items, item)
+		}
+	case models.JenisPeminjaman:
+		for i := range request.LokasiPeminjamanArray {
+			item := newItem()
+			item.Lokasi = &request.LokasiPeminjamanArray[i]
+			item.Kegunaan = stringAt(request.KegunaanArray, i)
+			if i < len(request.TglPeminjamanArray) {
+				item.TglPeminjaman = &request.TglPeminjamanArray[i]
+			}
+			if i < len(request.TglPengembalianArray) {
+				item.TglPengembalian = &request.TglPengembalianArray[i]
+			}
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 && (request.NamaBarang != nil || request.Lokasi != nil || request.Kegunaan != nil) {
+		item := newItem()
+		item.NamaBarang = request.NamaBarang
+		item.TypeModel = request.TypeModel
+		item.JenisPekerjaan = request.JenisPekerjaan
+		item.Lokasi = request.Lokasi
+		item.Kegunaan = request.Kegunaan
+		item.TglPeminjaman = request.TglPeminjaman
+		item.TglPengembalian = request.TglPengembalian
+		if request.Jumlah != nil {
+			item.JumlahDiminta = *request.Jumlah
+		}
+		items = append(items, item)
+	}
+
+	return items
 }
 
-func (s *Service) GetRequestsByStatus(status string) ([]models.Request, error) {
-	return s.repo.GetRequestsByStatus(status)
+// priceItems copies the unit prices of a new request onto its items. Pengadaan and perbaikan items all
+// need a price; peminjaman items lend what is already there and have none.
+func priceItems(request *models.Request, req *models.CreateRequestRequest) error {
+	items := request.Items
+	switch {
+	case len(req.HargaSatuanArray) > 0:
+		if len(req.HargaSatuanArray) != len(items) {
+			return errors.New("harga_satuan_array must have one price per item")
+		}
+		for i := range items {
+			items[i].HargaSatuan = &req.HargaSatuanArray[i]
+		}
+	case req.HargaSatuan != nil && len(items) == 1:
+		items[0].HargaSatuan = req.HargaSatuan
+	}
+
+	if request.JenisRequest == models.JenisPeminjaman {
+		return nil
+	}
+	if len(items) == 0 {
+		return errors.New("the request needs at least one item with a price")
+	}
+	for _, item := range items {
+		if item.HargaSatuan == nil {
+			return fmt.Errorf("item %d has no price (harga_satuan)", item.ItemNo)
+		}
+	}
+	return nil
 }
 
-func (s *Service) UpdateRequestStatus(id string, req *models.UpdateRequestRequest) error {
-	return s.repo.UpdateRequestStatus(id, req.StatusRequest, req.ApprovedBy, req.AcceptedBy, req.Keterangan)
+func stringAt(values []string, i int) *string {
+	if i < len(values) {
+		return &values[i]
+	}
+	return nil
 }
 
-func (s *Service) DeleteRequest(id string) error {
-	return s.repo.DeleteRequest(id)
+// parseDates parses a list of YYYY-MM-DD strings
+func parseDates(values []string) ([]time.Time, error) {
+	if values == nil {
+		return nil, nil
+	}
+	dates := make([]time.Time, len(values))
+	for i, v := range values {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, err
+		}
+		dates[i] = parsed
+	}
+	return dates, nil
 }
 
-// GetRequestsByUser returns requests created by a specific user
-func (s *Service) GetRequestsByUser(userID string) ([]models.Request, error) {
-	// This would need to be implemented in the repository
-	// For now, we'll get all and filter by requested_by
-	allRequests, err := s.repo.GetAllRequests()
+func (s *Service) GetRequestByID(id string, actor *Actor) (*models.Request, error) {
+	request, err := s.repo.GetRequestByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get user to check requested_by
-	user, err := s.repo.GetUserByID(userID)
+	if err := s.authorizeViewRequest(actor, request); err != nil {
+		return nil, err
+	}
+
+	if err := s.applySLA(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// ListRequests returns one page of requests matching the filter
+func (s *Service) ListRequests(filter *models.RequestFilter, pagination *models.PaginationRequest, actor *Actor) (*models.PaginationResponse, error) {
+	if filter.RequestedByID != "" {
+		if _, err := uuid.Parse(filter.RequestedByID); err != nil {
+			return nil, fmt.Errorf("%w: requested_by must be a user ID", ErrInvalidFilter)
+		}
+	}
+	for _, date := range []string{filter.TglFrom, filter.TglTo} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("%w: dates must use the YYYY-MM-DD format", ErrInvalidFilter)
+		}
+	}
+	if !repository.IsValidRequestSort(filter.Sort) {
+		return nil, fmt.Errorf("%w: unsupported sort %q", ErrInvalidFilter, filter.Sort)
+	}
+
+	scope, err := s.requestScope(actor)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (pagination.Page - 1) * pagination.Limit
+	requests, total, err := s.repo.GetRequests(filter, scope, pagination.Limit, offset)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.applySLAToList(requests); err != nil {
+		return nil, err
+	}
+
+	return &models.PaginationResponse{
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		Total:      total,
+		TotalPages: int((total + int64(pagination.Limit) - 1) / int64(pagination.Limit)),
+		Data:       requests,
+	}, nil
+}
 
-	var userRequests []models.Request
-	for _, req := range allRequests {
-		if req.RequestedBy == user.Name {
-			userRequests = append(userRequests, req)
+// SearchRequests runs a full-text search over the requests visible to the actor
+func (s *Service) SearchRequests(q string, pagination *models.PaginationRequest, actor *Actor) (*models.PaginationResponse, error) {
+	scope, err := s.requestScope(actor)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (pagination.Page - 1) * pagination.Limit
+	results, total, err := s.repo.SearchRequests(q, scope, pagination.Limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	requests := make([]*models.Request, len(results))
+	for i := range results {
+		requests[i] = &results[i].Request
+	}
+	if err := s.applySLA(requests...); err != nil {
+		return nil, err
+	}
+
+	return &models.PaginationResponse{
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		Total:      total,
+		TotalPages: int((total + int64(pagination.Limit) - 1) / int64(pagination.Limit)),
+		Data:       results,
+	}, nil
+}
+
+func (s *Service) UpdateRequestStatus(id string, req *models.UpdateRequestRequest, actor *Actor) error {
+	request, err := s.repo.GetRequestByID(id)
+	if err != nil {
+		return err
+	}
+
+	// Validate the status change against the workflow for this request type. Delegations do not apply here:
+	// a substitute only decides approval steps, through DecideApproval.
+	if err := s.authorizeDecideRequest(actor); err != nil {
+		return err
+	}
+	permissions, err := s.permissionList(actor)
+	if err != nil {
+		return err
+	}
+	if err := CheckTransition(request.JenisRequest, request.StatusRequest, req.StatusRequest, permissions); err != nil {
+		return err
+	}
+
+	// Requests with an approval chain leave DIAJUKAN only through their approval steps
+	if request.StatusRequest == models.StatusDiajukan && len(request.Approvals) > 0 {
+		return ErrApprovalChainRequired
+	}
+
+	user, err := s.repo.GetUserByID(actor.UserID)
+	if err != nil {
+		return err
+	}
+	userID := actor.UserID
+
+	update := &repository.StatusUpdate{
+		RequestID:     id,
+		CurrentStatus: request.StatusRequest,
+		NewStatus:     req.StatusRequest,
+		ApprovedBy:    request.ApprovedBy,
+		ApprovedByID:  request.ApprovedByID,
+		AcceptedBy:    request.AcceptedBy,
+		AcceptedByID:  request.AcceptedByID,
+		Keterangan:    req.Keterangan,
+		ActorID:       userID,
+	}
+
+	// Link the decision to the acting user; the name is filled in when the client omits it. Every status
+	// sets both fields, so a decision that is taken again replaces the old one and a request back at
+	// DIAJUKAN carries neither.
+	switch req.StatusRequest {
+	case models.StatusDiajukan:
+		update.ApprovedBy, update.ApprovedByID = nil, nil
+		update.AcceptedBy, update.AcceptedByID = nil, nil
+	case models.StatusDisetujui, models.StatusDitolak:
+		update.ApprovedBy, update.ApprovedByID = req.ApprovedBy, &userID
+		if update.ApprovedBy == nil {
+			update.ApprovedBy = &user.Name
 		}
+		update.AcceptedBy, update.AcceptedByID = nil, nil
+	case models.StatusDiproses:
+		update.AcceptedBy, update.AcceptedByID = req.AcceptedBy, &userID
+		if update.AcceptedBy == nil {
+			update.AcceptedBy = &user.Name
+		}
+	}
+
+	return s.repo.UpdateRequestStatus(update)
+}
+
+// UpdateRequestItem records an approver decision on a single item of a request
+func (s *Service) UpdateRequestItem(requestID string, itemID string, req *models.UpdateRequestItemRequest, actor *Actor) (*models.RequestItem, error) {
+	if err := s.authorizeApproveItems(actor); err != nil {
+		return nil, err
+	}
+
+	// A request with no way out of its status, DITOLAK or SELESAI, is closed
+	request, err := s.repo.GetRequestByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if len(AllowedTransitions(request.JenisRequest, request.StatusRequest)) == 0 {
+		return nil, ErrRequestClosed
+	}
+
+	item, err := s.repo.GetRequestItem(requestID, itemID)
+	if err != nil {
+		return nil, err
 	}
 
-	return userRequests, nil
+	item.StatusItem = req.StatusItem
+	item.Catatan = req.Catatan
+	item.JumlahDisetujui = req.JumlahDisetujui
+
+	switch req.StatusItem {
+	case models.StatusDisetujui:
+		// Approve the full requested quantity unless a smaller amount was given
+		if item.JumlahDisetujui == nil {
+			item.JumlahDisetujui = &item.JumlahDiminta
+		}
+		if *item.JumlahDisetujui > item.JumlahDiminta {
+			return nil, errors.New("approved quantity cannot exceed requested quantity")
+		}
+	case models.StatusDitolak:
+		zero := 0
+		item.JumlahDisetujui = &zero
+	default:
+		item.JumlahDisetujui = nil
+	}
+
+	if err := s.repo.UpdateRequestItem(item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// GetRequestHistory returns the status timeline of a request
+func (s *Service) GetRequestHistory(id string, actor *Actor) ([]models.RequestStatusHistory, error) {
+	// Loading the request reports unknown IDs as not found and applies the visibility rules
+	if _, err := s.GetRequestByID(id, actor); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetRequestStatusHistory(id)
+}
+
+func (s *Service) DeleteRequest(id string, actor *Actor) error {
+	request, err := s.repo.GetRequestByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authorizeDeleteRequest(actor, request); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteRequest(id)
+}
+
+// GetRequestsByUser returns requests created by a specific user
+func (s *Service) GetRequestsByUser(userID string) ([]models.Request, error) {
+	requests, err := s.repo.GetRequestsByRequesterID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return requests, s.applySLAToList(requests)
 }
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"web-work-request-backend/config"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+	"web-work-request-backend/throttle"
+	"web-work-request-backend/utils"
+)
+
+// ErrInvalidCredentials is returned for an unknown username or a wrong password
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// userLoginPolicy backs off after a few failures for one username and locks the account after
+// LOGIN_MAX_FAILURES failures
+func userLoginPolicy(cfg *config.Config) throttle.Policy {
+	return throttle.Policy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    cfg.LoginMaxFailures,
+		LockoutDuration: cfg.LoginLockoutTTL(),
+		Window:          time.Hour,
+	}
+}
+
+// ipLoginPolicy only backs off: many users can share one address behind NAT, so an IP is never locked out
+var ipLoginPolicy = throttle.Policy{
+	FreeAttempts: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     5 * time.Minute,
+	Window:       time.Hour,
+}
+
+func userLoginKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginUser checks credentials, throttling repeated failures per username and per client IP.
+// A blocked login returns a *throttle.BlockedError.
+func (s *Service) LoginUser(req *models.LoginRequest, clientIP string) (*models.AuthResponse, error) {
+	userKey, ipKey := userLoginKey(req.Username), ipLoginKey(clientIP)
+
+	if err := s.userLogins.Check(userKey); err != nil {
+		return nil, err
+	}
+	if err := s.ipLogins.Check(ipKey); err != nil {
+		return nil, err
+	}
+
+	user, err := s.authenticate(req.Username, req.Password)
+	if err != nil && !errors.Is(err, ErrInvalidCredentials) {
+		return nil, err
+	}
+
+	// Unknown usernames count as failures too, so probing for accounts is throttled the same way
+	if user == nil {
+		if err := s.userLogins.RecordFailure(userKey); err != nil {
+			return nil, err
+		}
+		if err := s.ipLogins.RecordFailure(ipKey); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	// The password was right, so the user may learn that the account cannot be used yet
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
+
+	// The failure counter is kept until the second factor succeeds too, so a known password cannot be
+	// used to keep resetting it while guessing codes
+	challenge, err := s.twoFactorChallenge(user)
+	if err != nil || challenge != nil {
+		return challenge, err
+	}
+
+	// Only the username counter is cleared; one valid account must not reset an attacker's IP counter
+	if err := s.userLogins.Reset(userKey); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user)
+}
+
+// authenticate tries each authenticator in turn and returns the user of the first that accepts the credentials
+func (s *Service) authenticate(username, password string) (*models.User, error) {
+	for _, authenticator := range s.authenticators {
+		user, err := authenticator.Authenticate(username, password)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return nil, fmt.Errorf("%s authentication failed: %w", authenticator.Name(), err)
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// UnlockUser lifts a login lockout and clears the failure counter of a user
+func (s *Service) UnlockUser(id string, actor *Actor) error {
+	user, err := s.repo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authorizeManageRole(actor, user.Role); err != nil {
+		return err
+	}
+
+	return s.userLogins.Reset(userLoginKey(user.Username))
+}
+
+// issueTokens creates an access token and a new refresh token for the user
+func (s *Service) issueTokens(user *models.User) (*models.AuthResponse, error) {
+	token, err := s.keys.GenerateJWT(user.ID.String(), user.Username, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: refreshHash,
+		ExpiresAt: time.Now().Add(s.config.RefreshTokenTTL()),
+	}
+	if err := s.repo.CreateRefreshToken(stored); err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.keys.AccessTokenTTL().Seconds()),
+		User:         *user,
+	}, nil
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token can be used once; presenting a rotated token again revokes all of the user's sessions
+// because it means the token was stolen.
+func (s *Service) RefreshSession(refreshToken string) (*models.AuthResponse, error) {
+	stored, err := s.repo.GetRefreshTokenByHash(utils.HashOpaqueToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	userID := stored.UserID.String()
+
+	if stored.RevokedAt != nil {
+		if stored.ReplacedBy != nil {
+			if err := s.repo.RevokeUserSessions(userID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if checkActive(user) != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	token, err := s.keys.GenerateJWT(userID, user.Username, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	newRefreshToken, newHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	replacement := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: newHash,
+		ExpiresAt: time.Now().Add(s.config.RefreshTokenTTL()),
+	}
+	if err := s.repo.RotateRefreshToken(stored.ID.String(), replacement); err != nil {
+		// Another request rotated the same token first
+		if errors.Is(err, repository.ErrTokenRevoked) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int64(s.keys.AccessTokenTTL().Seconds()),
+		User:         *user,
+	}, nil
+}
+
+// Logout revokes a refresh token; the matching access token expires on its own shortly after
+func (s *Service) Logout(refreshToken string) error {
+	return s.repo.RevokeRefreshToken(utils.HashOpaqueToken(refreshToken))
+}
+
+// ParseAccessToken validates an access token against the configured signing keys
+func (s *Service) ParseAccessToken(token string) (*utils.AccessClaims, error) {
+	return s.keys.ParseAccessToken(token)
+}
+
+// JWKS returns the public keys other services can use to verify our access tokens
+func (s *Service) JWKS() utils.JSONWebKeySet {
+	return s.keys.JWKS()
+}
+
+// CheckSession reports the current role of a user and whether access tokens with the given version are still valid
+func (s *Service) CheckSession(userID string, tokenVersion int) (string, bool, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	if user.TokenVersion != tokenVersion || checkActive(user) != nil {
+		return "", false, nil
+	}
+
+	return user.Role, true, nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+
+	"github.com/google/uuid"
+)
+
+// EscalationTarget picks the approver an overdue request escalates to. heads are the unit heads of the
+// request's unit and its parents, nearest first; level is the number of escalations so far and holder
+// the approver the request currently waits for, if known. It returns the next head above both and the new
+// level, or false when the top of the unit tree has been reached.
+func EscalationTarget(heads []uuid.UUID, level int, holder *uuid.UUID) (uuid.UUID, int, bool) {
+	next := level
+	if holder != nil {
+		for i := next; i < len(heads); i++ {
+			if heads[i] == *holder {
+				next = i + 1
+				break
+			}
+		}
+	}
+
+	if next >= len(heads) {
+		return uuid.Nil, level, false
+	}
+	return heads[next], next + 1, true
+}
+
+// slaTargets indexes the SLA targets by request type and status
+func (s *Service) slaTargets() (map[[2]string]int, error) {
+	targets, err := s.repo.GetSLATargets()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[[2]string]int, len(targets))
+	for _, target := range targets {
+		index[[2]string{target.JenisRequest, target.Status}] = target.WorkingDays
+	}
+	return index, nil
+}
+
+// applySLA fills in the due date and breach flag of requests whose current status has an SLA target
+func (s *Service) applySLA(requests ...*models.Request) error {
+	targets, err := s.slaTargets()
+	if err != nil {
+		return err
+	}
+	cal, err := s.calendar.Calendar()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, request := range requests {
+		days, ok := targets[[2]string{request.JenisRequest, request.StatusRequest}]
+		if !ok {
+			continue
+		}
+		due := cal.AddWorkingDays(request.StatusSince, days)
+		request.DueAt = &due
+		request.SLABreached = now.After(due)
+	}
+	return nil
+}
+
+// applySLAToList is applySLA for a page of requests
+func (s *Service) applySLAToList(requests []models.Request) error {
+	pointers := make([]*models.Request, len(requests))
+	for i := range requests {
+		pointers[i] = &requests[i]
+	}
+	return s.applySLA(pointers...)
+}
+
+func (s *Service) GetSLATargets() ([]models.SLATarget, error) {
+	return s.repo.GetSLATargets()
+}
+
+// SaveSLATarget sets the target of a request type and status; it applies to open requests right away
+func (s *Service) SaveSLATarget(req *models.SLATargetRequest) (*models.SLATarget, error) {
+	target := &models.SLATarget{JenisRequest: req.JenisRequest, Status: req.Status, WorkingDays: req.WorkingDays}
+	if err := s.repo.SaveSLATarget(target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func (s *Service) DeleteSLATarget(jenisRequest, status string) error {
+	return s.repo.DeleteSLATarget(jenisRequest, status)
+}
+
+// RunEscalations escalates overdue requests every interval until the process exits
+func (s *Service) RunEscalations(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		escalated, err := s.EscalateOverdueRequests(time.Now())
+		if err != nil {
+			log.Printf("SLA escalation failed: %v", err)
+			continue
+		}
+		if escalated > 0 {
+			log.Printf("SLA escalation: %d overdue requests escalated", escalated)
+		}
+	}
+}
+
+// EscalateOverdueRequests escalates every request that has been in its status longer than its SLA target
+// allows, counting again from the last escalation, and returns how many were escalated
+func (s *Service) EscalateOverdueRequests(now time.Time) (int, error) {
+	requests, err := s.repo.GetRequestsUnderSLA()
+	if err != nil {
+		return 0, err
+	}
+	cal, err := s.calendar.Calendar()
+	if err != nil {
+		return 0, err
+	}
+
+	escalated := 0
+	for i := range requests {
+		request := &requests[i]
+
+		clock := request.StatusSince
+		if request.EscalatedAt != nil && request.EscalatedAt.After(clock) {
+			clock = *request.EscalatedAt
+		}
+		due := cal.AddWorkingDays(clock, request.WorkingDays)
+		if !now.After(due) {
+			continue
+		}
+
+		done, err := s.escalate(&request.Request, request.WorkingDays, cal.WorkingDaysBetween(due, now))
+		if err != nil {
+			// One broken request must not hold up the others
+			log.Printf("SLA escalation of request %d failed: %v", request.ID, err)
+			continue
+		}
+		if done {
+			escalated++
+		}
+	}
+	return escalated, nil
+}
+
+// escalate moves an overdue request to the next unit head above its current approver. A pending approval
+// step is reassigned to that head; otherwise the head is recorded so they can follow the request up.
+func (s *Service) escalate(request *models.Request, workingDays, overdueDays int) (bool, error) {
+	lineage, err := s.unitLineage(request.UnitID)
+	if err != nil {
+		return false, err
+	}
+
+	// Heads who left or are deactivated are skipped
+	var heads []uuid.UUID
+	names := make(map[uuid.UUID]string)
+	for _, unit := range lineage {
+		if unit.HeadUserID == nil {
+			continue
+		}
+		if _, seen := names[*unit.HeadUserID]; seen {
+			continue
+		}
+		head, err := s.repo.GetUserByID(unit.HeadUserID.String())
+		if err != nil {
+			return false, err
+		}
+		names[head.ID] = head.Name
+		if head.Status == models.UserStatusActive {
+			heads = append(heads, head.ID)
+		}
+	}
+
+	approvals, err := s.repo.GetRequestApprovals(fmt.Sprint(request.ID))
+	if err != nil {
+		return false, err
+	}
+	request.Approvals = approvals
+
+	var holder *uuid.UUID
+	var approvalID int64
+	if approval := currentApproval(request); approval != nil && request.StatusRequest == models.StatusDiajukan {
+		holder, approvalID = approval.ApproverUserID, approval.ID
+	}
+
+	target, level, ok := EscalationTarget(heads, request.EscalationLevel, holder)
+	if !ok {
+		return false, nil
+	}
+
+	escalation := &repository.Escalation{
+		RequestID:  request.ID,
+		Status:     request.StatusRequest,
+		FromLevel:  request.EscalationLevel,
+		ToLevel:    level,
+		TargetID:   target.String(),
+		ApprovalID: approvalID,
+		HistoryNote: fmt.Sprintf("SLA of %d working days in %s exceeded by %d working days; escalated to %s (level %d)",
+			workingDays, request.StatusRequest, overdueDays, names[target], level),
+	}
+	if err := s.repo.EscalateRequest(escalation); err != nil {
+		if errors.Is(err, repository.ErrStatusChanged) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"errors"
+	"time"
+	"web-work-request-backend/models"
+	"web-work-request-backend/utils"
+)
+
+// twoFactorChallengeTTL is how long a user has to complete the second login step
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// recoveryCodeCount is how many recovery codes are issued when 2FA is enabled
+const recoveryCodeCount = 10
+
+var (
+	// ErrInvalidChallenge is returned for an unknown or expired second-step challenge token
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+	// ErrInvalidTwoFactorCode is returned when a TOTP or recovery code does not match
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	// ErrTwoFactorAlreadyEnabled is returned when enrolling a user who already uses 2FA
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnrolled is returned when confirming or disabling 2FA that was never started
+	ErrTwoFactorNotEnrolled = errors.New("two-factor enrollment has not been started")
+)
+
+// requiresTwoFactor reports whether users with the role must use 2FA
+func (s *Service) requiresTwoFactor(role string) bool {
+	for _, required := range s.config.TOTPRequiredRoles {
+		if required == role {
+			return true
+		}
+	}
+	return false
+}
+
+// twoFactorChallenge returns the response for the second login step, or nil when the user can log in
+// with the password alone
+func (s *Service) twoFactorChallenge(user *models.User) (*models.AuthResponse, error) {
+	step := ""
+	switch {
+	case user.TOTPEnabled:
+		step = models.TwoFactorVerify
+	case s.requiresTwoFactor(user.Role):
+		step = models.TwoFactorEnroll
+	default:
+		return nil, nil
+	}
+
+	token, err := s.keys.GenerateChallengeToken(user.ID.String(), utils.TokenTypeTwoFactor, twoFactorChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{ChallengeToken: token, TwoFactor: step}, nil
+}
+
+// challengeUser resolves a challenge token to its user and applies the login throttle to them. A challenge
+// that already completed a login is refused.
+func (s *Service) challengeUser(challengeToken, clientIP string) (*models.User, *utils.ChallengeClaims, error) {
+	claims, err := s.keys.ParseChallengeToken(challengeToken, utils.TokenTypeTwoFactor)
+	if err != nil {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	used, err := s.repo.LoginChallengeUsed(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if used {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	user, err := s.repo.GetUserByID(claims.UserID)
+	if err != nil || checkActive(user) != nil {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	if err := s.userLogins.Check(userLoginKey(user.Username)); err != nil {
+		return nil, nil, err
+	}
+	if err := s.ipLogins.Check(ipLoginKey(clientIP)); err != nil {
+		return nil, nil, err
+	}
+
+	return user, claims, nil
+}
+
+// useChallenge marks a challenge as used when it completes a login; of two logins racing with the same
+// challenge only one gets through
+func (s *Service) useChallenge(claims *utils.ChallengeClaims) error {
+	unused, err := s.repo.UseLoginChallenge(claims.ID, claims.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if !unused {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// recordSecondFactorFailure counts a wrong code like a wrong password, so codes cannot be brute-forced
+func (s *Service) recordSecondFactorFailure(user *models.User, clientIP string) error {
+	if err := s.userLogins.RecordFailure(userLoginKey(user.Username)); err != nil {
+		return err
+	}
+	if err := s.ipLogins.RecordFailure(ipLoginKey(clientIP)); err != nil {
+		return err
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code
+func (s *Service) checkSecondFactor(user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == nil {
+		return false, nil
+	}
+
+	if step, ok := utils.ValidateTOTP(*user.TOTPSecret, code, time.Now()); ok {
+		// A code that was already used, or an older one, is rejected
+		return s.repo.AdvanceTOTPStep(user.ID.String(), step)
+	}
+
+	return s.repo.UseRecoveryCode(user.ID.String(), utils.HashOpaqueToken(utils.NormalizeRecoveryCode(code)))
+}
+
+// VerifyTwoFactor completes a login with a TOTP or recovery code
+func (s *Service) VerifyTwoFactor(req *models.TwoFactorChallengeRequest, clientIP string) (*models.AuthResponse, error) {
+	user, challenge, err := s.challengeUser(req.ChallengeToken, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	ok, err := s.checkSecondFactor(user, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.recordSecondFactorFailure(user, clientIP)
+	}
+
+	if err := s.useChallenge(challenge); err != nil {
+		return nil, err
+	}
+	if err := s.userLogins.Reset(userLoginKey(user.Username)); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user)
+}
+
+// StartTwoFactorEnrollment creates a new secret for the user; it is only used once confirmed with a code
+func (s *Service) StartTwoFactorEnrollment(userID string) (*models.TwoFactorEnrollment, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetPendingTOTPSecret(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.config.TOTPIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTwoFactorEnrollment enables 2FA once the user proves their app works, and returns the
+// recovery codes; they are shown only this once
+func (s *Service) ConfirmTwoFactorEnrollment(userID, code string) ([]string, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	step, ok := utils.ValidateTOTP(*user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, recoveryCode := range codes {
+		hashes[i] = utils.HashOpaqueToken(utils.NormalizeRecoveryCode(recoveryCode))
+	}
+
+	if err := s.repo.EnableTOTP(userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// StartChallengeEnrollment starts enrollment during login for a user whose role requires 2FA
+func (s *Service) StartChallengeEnrollment(req *models.TwoFactorEnrollmentRequest, clientIP string) (*models.TwoFactorEnrollment, error) {
+	user, _, err := s.challengeUser(req.ChallengeToken, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	return s.StartTwoFactorEnrollment(user.ID.String())
+}
+
+// ConfirmChallengeEnrollment enables 2FA during login and completes the login
+func (s *Service) ConfirmChallengeEnrollment(req *models.TwoFactorChallengeRequest, clientIP string) (*models.AuthResponse, []string, error) {
+	user, challenge, err := s.challengeUser(req.ChallengeToken, clientIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codes, err := s.ConfirmTwoFactorEnrollment(user.ID.String(), req.Code)
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		return nil, nil, s.recordSecondFactorFailure(user, clientIP)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.useChallenge(challenge); err != nil {
+		return nil, nil, err
+	}
+	if err := s.userLogins.Reset(userLoginKey(user.Username)); err != nil {
+		return nil, nil, err
+	}
+
+	response, err := s.issueTokens(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return response, codes, nil
+}
+
+// DisableTwoFactor turns 2FA off for the user after checking a current code; roles that require 2FA cannot
+func (s *Service) DisableTwoFactor(userID, role, code string) error {
+	if s.requiresTwoFactor(role) {
+		return &ForbiddenError{Action: ActionDisable2FA, Reason: "two-factor authentication is required for your role"}
+	}
+
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnrolled
+	}
+
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return s.repo.DisableTOTP(userID)
+}
+
+// ResetTwoFactor removes a user's second factor, for example after they lost their device and recovery
+// codes; their sessions are revoked and they enroll again at the next login if their role requires it
+func (s *Service) ResetTwoFactor(id string, actor *Actor) error {
+	user, err := s.repo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authorizeManageRole(actor, user.Role); err != nil {
+		return err
+	}
+
+	if err := s.repo.DisableTOTP(id); err != nil {
+		return err
+	}
+	return s.repo.RevokeUserSessions(id)
+}
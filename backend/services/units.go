@@ -0,0 +1,217 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownUnit is returned when a user or request names a unit that does not exist
+	ErrUnknownUnit = errors.New("unknown unit")
+	// ErrUnitExists is returned when a unit name or cost center is already used by another unit
+	ErrUnitExists = errors.New("a unit with this name or cost center already exists")
+	// ErrUnitInUse is returned when deleting a unit that still has child units, users or requests
+	ErrUnitInUse = errors.New("the unit still has child units, users or requests; merge it instead")
+	// ErrUnitCycle is returned when a unit would become its own ancestor
+	ErrUnitCycle = errors.New("a unit cannot be placed under itself or one of its children")
+)
+
+// NormalizeUnitName trims a unit name and collapses runs of whitespace, the way existing free-text units
+// were deduplicated
+func NormalizeUnitName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// resolveUnit finds the unit given by ID or, when no ID is given, by name
+func (s *Service) resolveUnit(unitID, name string) (*models.Unit, error) {
+	var unit *models.Unit
+	var err error
+
+	switch {
+	case unitID != "":
+		if _, parseErr := uuid.Parse(unitID); parseErr != nil {
+			return nil, ErrUnknownUnit
+		}
+		unit, err = s.repo.GetUnitByID(unitID)
+	case NormalizeUnitName(name) != "":
+		unit, err = s.repo.GetUnitByName(NormalizeUnitName(name))
+	default:
+		return nil, ErrUnknownUnit
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnknownUnit
+	}
+	return unit, err
+}
+
+// externalUnit maps a unit name from an identity provider onto a unit, following the names of merged units.
+// Names without a unit are kept as they are, without a unit reference, until an operator creates the unit.
+func externalUnit(repo *repository.Repository, name string) (string, *uuid.UUID, error) {
+	unit, err := repo.FindUnitByName(NormalizeUnitName(name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return name, nil, nil
+		}
+		return "", nil, err
+	}
+	return unit.Name, &unit.ID, nil
+}
+
+func (s *Service) GetUnits() ([]models.Unit, error) {
+	return s.repo.GetAllUnits()
+}
+
+func (s *Service) GetUnitByID(id string) (*models.Unit, error) {
+	return s.repo.GetUnitByID(id)
+}
+
+func (s *Service) CreateUnit(req *models.UnitRequest) (*models.Unit, error) {
+	unit := &models.Unit{}
+	if err := s.applyUnitRequest(unit, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateUnit(unit); err != nil {
+		return nil, err
+	}
+	return unit, nil
+}
+
+// UpdateUnit replaces a unit's fields; a new name is copied onto its users and requests
+func (s *Service) UpdateUnit(id string, req *models.UnitRequest) (*models.Unit, error) {
+	unit, err := s.repo.GetUnitByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyUnitRequest(unit, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateUnit(unit); err != nil {
+		return nil, err
+	}
+	return unit, nil
+}
+
+// applyUnitRequest validates a unit request against the other units and copies it onto unit
+func (s *Service) applyUnitRequest(unit *models.Unit, req *models.UnitRequest) error {
+	name := NormalizeUnitName(req.Name)
+	if name == "" {
+		return errors.New("unit name is required")
+	}
+	if existing, err := s.repo.GetUnitByName(name); err == nil && existing.ID != unit.ID {
+		return ErrUnitExists
+	}
+
+	var costCenter *string
+	if code := strings.TrimSpace(req.CostCenter); code != "" {
+		if existing, err := s.repo.GetUnitByCostCenter(code); err == nil && existing.ID != unit.ID {
+			return ErrUnitExists
+		}
+		costCenter = &code
+	}
+
+	var parentID *uuid.UUID
+	if req.ParentID != "" {
+		parent, err := s.resolveUnit(req.ParentID, "")
+		if err != nil {
+			return err
+		}
+		if err := s.checkUnitParent(unit.ID, parent); err != nil {
+			return err
+		}
+		parentID = &parent.ID
+	}
+
+	var headUserID *uuid.UUID
+	if req.HeadUserID != "" {
+		if _, err := uuid.Parse(req.HeadUserID); err != nil {
+			return errors.New("unit head not found")
+		}
+		head, err := s.repo.GetUserByID(req.HeadUserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("unit head not found")
+			}
+			return err
+		}
+		if head.Status != models.UserStatusActive {
+			return errors.New("the unit head must be an active user")
+		}
+		headUserID = &head.ID
+	}
+
+	unit.Name, unit.CostCenter, unit.ParentID, unit.HeadUserID = name, costCenter, parentID, headUserID
+	return nil
+}
+
+// checkUnitParent walks up from the new parent and refuses a parent that is the unit itself or below it
+func (s *Service) checkUnitParent(id uuid.UUID, parent *models.Unit) error {
+	for ancestor := parent; ; {
+		if ancestor.ID == id {
+			return ErrUnitCycle
+		}
+		if ancestor.ParentID == nil {
+			return nil
+		}
+
+		next, err := s.repo.GetUnitByID(ancestor.ParentID.String())
+		if err != nil {
+			return err
+		}
+		ancestor = next
+	}
+}
+
+// DeleteUnit removes a unit nothing refers to any more
+func (s *Service) DeleteUnit(id string) error {
+	if _, err := s.repo.GetUnitByID(id); err != nil {
+		return err
+	}
+
+	references, err := s.repo.CountUnitReferences(id)
+	if err != nil {
+		return err
+	}
+	if references > 0 {
+		return ErrUnitInUse
+	}
+
+	return s.repo.DeleteUnit(id)
+}
+
+// MergeUnit folds a duplicate unit into another: its child units, users and requests move over and the
+// duplicate is deleted. Directory logins that still name the duplicate land in the unit it was merged into.
+func (s *Service) MergeUnit(id string, req *models.MergeUnitRequest, actor *Actor) (*models.Unit, error) {
+	from, err := s.repo.GetUnitByID(id)
+	if err != nil {
+		return nil, err
+	}
+	into, err := s.resolveUnit(req.Into, "")
+	if err != nil {
+		return nil, err
+	}
+	if into.ID == from.ID {
+		return nil, errors.New("a unit cannot be merged into itself")
+	}
+
+	// The target must not sit below the merged unit, or its children would end up under themselves
+	if err := s.checkUnitParent(from.ID, into); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.MergeUnits(id, into); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Unit %s merged into %s by %s", from.Name, into.Name, actor.UserID)
+	return into, nil
+}
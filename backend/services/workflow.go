@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"web-work-request-backend/models"
+)
+
+// Transition describes a single allowed status change and the permission needed to trigger it
+type Transition struct {
+	From       string
+	To         string
+	Permission string
+}
+
+// workflows holds the allowed status transitions per jenis_request
+var workflows = map[string][]Transition{
+	models.JenisPengadaan: {
+		{From: models.StatusDiajukan, To: models.StatusDisetujui, Permission: models.PermissionRequestApprove},
+		{From: models.StatusDiajukan, To: models.StatusDitolak, Permission: models.PermissionRequestApprove},
+		{From: models.StatusDisetujui, To: models.StatusDiproses, Permission: models.PermissionRequestProcess},
+		{From: models.StatusDiproses, To: models.StatusSelesai, Permission: models.PermissionRequestProcess},
+	},
+	models.JenisPerbaikan: {
+		{From: models.StatusDiajukan, To: models.StatusDisetujui, Permission: models.PermissionRequestApprove},
+		{From: models.StatusDiajukan, To: models.StatusDitolak, Permission: models.PermissionRequestApprove},
+		// Repairs can be picked up directly without a separate approval step
+		{From: models.StatusDiajukan, To: models.StatusDiproses, Permission: models.PermissionRequestProcess},
+		{From: models.StatusDisetujui, To: models.StatusDiproses, Permission: models.PermissionRequestProcess},
+		{From: models.StatusDiproses, To: models.StatusSelesai, Permission: models.PermissionRequestProcess},
+	},
+	models.JenisPeminjaman: {
+		{From: models.StatusDiajukan, To: models.StatusDisetujui, Permission: models.PermissionRequestApprove},
+		{From: models.StatusDiajukan, To: models.StatusDitolak, Permission: models.PermissionRequestApprove},
+		// DIPROSES means the item has been handed over to the borrower
+		{From: models.StatusDisetujui, To: models.StatusDiproses, Permission: models.PermissionRequestProcess},
+		// SELESAI means the item has been returned
+		{From: models.StatusDiproses, To: models.StatusSelesai, Permission: models.PermissionRequestProcess},
+	},
+}
+
+// TransitionError is returned when a status change is not allowed by the workflow
+type TransitionError struct {
+	JenisRequest string
+	From         string
+	To           string
+	Allowed      []string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change %s request status from %s to %s", e.JenisRequest, e.From, e.To)
+}
+
+// TransitionForbiddenError is returned when the transition exists but the caller lacks the permission it needs
+type TransitionForbiddenError struct {
+	From       string
+	To         string
+	Permission string
+}
+
+func (e *TransitionForbiddenError) Error() string {
+	return fmt.Sprintf("permission %s is required to change status from %s to %s", e.Permission, e.From, e.To)
+}
+
+// Is makes errors.Is(err, ErrForbidden) true so the error is reported like other authorization failures
+func (e *TransitionForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// AllowedTransitions returns the statuses a request of the given type can move to from its current status
+func AllowedTransitions(jenisRequest, from string) []string {
+	var allowed []string
+	for _, t := range workflows[jenisRequest] {
+		if t.From == from {
+			allowed = append(allowed, t.To)
+		}
+	}
+	return allowed
+}
+
+// CheckTransition validates that a caller holding permissions may move a request of the given type
+// from one status to another
+func CheckTransition(jenisRequest, from, to string, permissions []string) error {
+	for _, t := range workflows[jenisRequest] {
+		if t.From != from || t.To != to {
+			continue
+		}
+		for _, p := range permissions {
+			if p == t.Permission {
+				return nil
+			}
+		}
+		return &TransitionForbiddenError{From: from, To: to, Permission: t.Permission}
+	}
+
+	return &TransitionError{
+		JenisRequest: jenisRequest,
+		From:         from,
+		To:           to,
+		Allowed:      AllowedTransitions(jenisRequest, from),
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+// approvalFixture has a purchase chain for every unit and a longer one for large purchases in Keuangan
+type approvalFixture struct {
+	env                   *testEnv
+	keuangan              *models.Unit
+	rina, kiki, oki, dina *models.User
+}
+
+func newApprovalFixture(t *testing.T) *approvalFixture {
+	env := newTestEnv(t)
+	direktorat := env.unit(t, "Direktorat", nil)
+	keuangan := env.unit(t, "Keuangan", direktorat)
+	f := &approvalFixture{
+		env:      env,
+		keuangan: keuangan,
+		rina:     env.user(t, "rina", models.RoleUser, keuangan),
+		kiki:     env.user(t, "kiki", models.RoleUser, keuangan),
+		oki:      env.user(t, "oki", models.RoleOperator, direktorat),
+		dina:     env.user(t, "dina", models.RoleUser, direktorat),
+	}
+	env.setUnitHead(t, direktorat, f.dina)
+	env.setUnitHead(t, keuangan, f.kiki)
+
+	for _, chain := range []*models.ApprovalChainRequest{
+		{
+			Name:         "Pengadaan",
+			JenisRequest: models.JenisPengadaan,
+			Steps:        []models.ApprovalStepRequest{{Name: "Bagian umum", ApproverType: models.ApproverRole, ApproverRole: models.RoleOperator}},
+		},
+		{
+			Name:         "Pengadaan besar Keuangan",
+			JenisRequest: models.JenisPengadaan,
+			UnitID:       keuangan.ID.String(),
+			MinValue:     10000000,
+			Steps: []models.ApprovalStepRequest{
+				{Name: "Kepala unit", ApproverType: models.ApproverUnitHead},
+				{Name: "Bagian umum", ApproverType: models.ApproverRole, ApproverRole: models.RoleOperator},
+			},
+		},
+	} {
+		if _, err := env.service.CreateApprovalChain(chain); err != nil {
+			t.Fatalf("Failed to create chain %s: %v", chain.Name, err)
+		}
+	}
+	return f
+}
+
+func (f *approvalFixture) decide(request *models.Request, decision string, approver *models.User) (*models.Request, error) {
+	return f.env.service.DecideApproval(fmt.Sprint(request.ID), &models.ApprovalDecisionRequest{Decision: decision}, actorOf(approver))
+}
+
+// pendingFor reports whether request is among the approvals waiting for approver
+func (f *approvalFixture) pendingFor(t *testing.T, approver *models.User, request *models.Request) bool {
+	t.Helper()
+
+	pending, err := f.env.service.GetPendingApprovals(actorOf(approver))
+	if err != nil {
+		t.Fatalf("Failed to list the approvals of %s: %v", approver.Username, err)
+	}
+	for _, p := range pending {
+		if p.ID == request.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSubmittedRequestGetsTheMatchingChain(t *testing.T) {
+	f := newApprovalFixture(t)
+
+	small := f.env.pengadaan(t, f.rina, 500000, "Mouse")
+	if approvals := f.env.reload(t, small).Approvals; len(approvals) != 1 || approvals[0].Name != "Bagian umum" {
+		t.Errorf("Expected the chain for every unit below the minimum value, got %+v", approvals)
+	}
+
+	// Two laptops together reach the minimum value of the Keuangan chain
+	large := f.env.pengadaan(t, f.rina, 6000000, "Laptop", "Laptop")
+	approvals := f.env.reload(t, large).Approvals
+	if len(approvals) != 2 {
+		t.Fatalf("Expected the two steps of the Keuangan chain, got %d", len(approvals))
+	}
+	head, role := approvals[0], approvals[1]
+	if head.ApproverUserID == nil || *head.ApproverUserID != f.kiki.ID || head.Decision != models.StatusDiajukan {
+		t.Errorf("Expected the first step to wait for the head of Keuangan, got %+v", head)
+	}
+	if role.ApproverRole == nil || *role.ApproverRole != models.RoleOperator || role.ApproverUserID != nil {
+		t.Errorf("Expected the second step to wait for any operator, got %+v", role)
+	}
+
+	perbaikan := f.env.submit(t, f.rina, &models.CreateRequestRequest{
+		JenisRequest:             models.JenisPerbaikan,
+		UnitID:                   f.keuangan.ID.String(),
+		NamaBarangPerbaikanArray: []string{"Printer"},
+		JumlahPerbaikanArray:     []int{1},
+		LokasiPerbaikanArray:     []string{"Ruang Arsip"},
+		HargaSatuanArray:         []float64{50000000},
+		TglRequest:               "2024-03-01",
+	})
+	if approvals := f.env.reload(t, perbaikan).Approvals; len(approvals) != 0 {
+		t.Errorf("Expected a request type without chains to be approved in one step, got %d steps", len(approvals))
+	}
+}
+
+func TestApprovalStepsAreDecidedInOrder(t *testing.T) {
+	f := newApprovalFixture(t)
+	request := f.env.pengadaan(t, f.rina, 15000000, "Laptop")
+
+	if !f.pendingFor(t, f.kiki, request) || f.pendingFor(t, f.oki, request) {
+		t.Error("Expected only the unit head to see the first step")
+	}
+	if _, err := f.decide(request, models.StatusDisetujui, f.oki); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected an operator not to decide the unit head step, got %v", err)
+	}
+	err := f.env.service.UpdateRequestStatus(fmt.Sprint(request.ID), &models.UpdateRequestRequest{StatusRequest: models.StatusDisetujui}, actorOf(f.oki))
+	if !errors.Is(err, services.ErrApprovalChainRequired) {
+		t.Errorf("Expected the chain to be required for a direct approval, got %v", err)
+	}
+
+	decided, err := f.decide(request, models.StatusDisetujui, f.kiki)
+	if err != nil {
+		t.Fatalf("Failed to decide the first step: %v", err)
+	}
+	if decided.StatusRequest != models.StatusDiajukan {
+		t.Errorf("Expected the request to wait for the second step, got %s", decided.StatusRequest)
+	}
+	if step := decided.Approvals[0]; step.Decision != models.StatusDisetujui || step.DecidedByID == nil || *step.DecidedByID != f.kiki.ID {
+		t.Errorf("Expected the unit head's decision on the first step, got %+v", step)
+	}
+
+	if f.pendingFor(t, f.kiki, request) || !f.pendingFor(t, f.oki, request) {
+		t.Error("Expected the request to move on to the operators")
+	}
+	if _, err := f.decide(request, models.StatusDisetujui, f.dina); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected a user without the operator role to be refused, got %v", err)
+	}
+
+	decided, err = f.decide(request, models.StatusDisetujui, f.oki)
+	if err != nil {
+		t.Fatalf("Failed to decide the second step: %v", err)
+	}
+	if decided.StatusRequest != models.StatusDisetujui || decided.ApprovedByID == nil || *decided.ApprovedByID != f.oki.ID.String() {
+		t.Errorf("Expected the last step to approve the request, got %s by %v", decided.StatusRequest, decided.ApprovedByID)
+	}
+
+	if _, err := f.decide(request, models.StatusDisetujui, f.oki); !errors.Is(err, services.ErrNoPendingApproval) {
+		t.Errorf("Expected a decided request to have no open step, got %v", err)
+	}
+}
+
+func TestRejectedStepRejectsTheRequest(t *testing.T) {
+	f := newApprovalFixture(t)
+	request := f.env.pengadaan(t, f.rina, 15000000, "Laptop")
+
+	decided, err := f.decide(request, models.StatusDitolak, f.kiki)
+	if err != nil {
+		t.Fatalf("Failed to reject the first step: %v", err)
+	}
+	if decided.StatusRequest != models.StatusDitolak {
+		t.Errorf("Expected a rejected step to reject the request, got %s", decided.StatusRequest)
+	}
+	if decided.Approvals[1].Decision != models.StatusDiajukan {
+		t.Errorf("Expected the later step to stay undecided, got %s", decided.Approvals[1].Decision)
+	}
+	if f.pendingFor(t, f.oki, request) {
+		t.Error("Expected a rejected request to leave the operators' approvals")
+	}
+}
+
+func TestRequesterCannotDecideOwnRequest(t *testing.T) {
+	f := newApprovalFixture(t)
+
+	// The head of Keuangan submits a large purchase, so the unit head step goes to the head above
+	request := f.env.pengadaan(t, f.kiki, 15000000, "Laptop")
+
+	step := f.env.reload(t, request).Approvals[0]
+	if step.ApproverUserID == nil || *step.ApproverUserID != f.dina.ID {
+		t.Fatalf("Expected the unit head step to go to the head of the Direktorat, got %v", step.ApproverUserID)
+	}
+	if f.pendingFor(t, f.kiki, request) || !f.pendingFor(t, f.dina, request) {
+		t.Error("Expected the request to wait for the Direktorat head rather than its requester")
+	}
+	if _, err := f.decide(request, models.StatusDisetujui, f.kiki); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected the requester to be refused, got %v", err)
+	}
+	if _, err := f.decide(request, models.StatusDisetujui, f.dina); err != nil {
+		t.Errorf("Expected the Direktorat head to decide the step, got %v", err)
+	}
+}
+
+func TestTopUnitHeadRequestFallsToApprovers(t *testing.T) {
+	f := newApprovalFixture(t)
+
+	// Nobody heads a unit above the Direktorat, so anyone with request.approve decides its head's request
+	if _, err := f.env.service.CreateApprovalChain(&models.ApprovalChainRequest{
+		Name:         "Pengadaan Direktorat",
+		JenisRequest: models.JenisPengadaan,
+		UnitID:       f.dina.UnitID.String(),
+		Steps:        []models.ApprovalStepRequest{{Name: "Kepala unit", ApproverType: models.ApproverUnitHead}},
+	}); err != nil {
+		t.Fatalf("Failed to create the chain: %v", err)
+	}
+	request := f.env.pengadaan(t, f.dina, 500000, "Laptop")
+
+	step := f.env.reload(t, request).Approvals[0]
+	if step.ApproverType != models.ApproverUnitHead || step.ApproverUserID != nil {
+		t.Fatalf("Expected the step to have no head, got %+v", step)
+	}
+	if !f.pendingFor(t, f.oki, request) || f.pendingFor(t, f.dina, request) {
+		t.Error("Expected the request to wait for the operators")
+	}
+	if _, err := f.decide(request, models.StatusDisetujui, f.dina); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected the requester to be refused, got %v", err)
+	}
+	decided, err := f.decide(request, models.StatusDisetujui, f.oki)
+	if err != nil {
+		t.Fatalf("Expected an operator to decide the step, got %v", err)
+	}
+	if decided.StatusRequest != models.StatusDisetujui {
+		t.Errorf("Expected the request to be approved, got %s", decided.StatusRequest)
+	}
+}
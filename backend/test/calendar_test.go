@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"web-work-request-backend/calendar"
+)
+
+func TestAddWorkingDays(t *testing.T) {
+	// 2024-03-01 is a Friday; 2024-03-11 (Nyepi) is a Monday holiday
+	friday := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	cal := calendar.New(time.UTC, []time.Time{time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)})
+
+	cases := []struct {
+		name     string
+		cal      *calendar.Calendar
+		start    time.Time
+		days     int
+		expected time.Time
+	}{
+		{"same day", nil, friday, 0, friday},
+		{"over the weekend", nil, friday, 1, time.Date(2024, 3, 4, 10, 30, 0, 0, time.UTC)},
+		{"two working days", nil, friday, 2, time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)},
+		{"a full week", nil, friday, 5, time.Date(2024, 3, 8, 10, 30, 0, 0, time.UTC)},
+		{"from saturday", nil, time.Date(2024, 3, 2, 15, 0, 0, 0, time.UTC), 1, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"over a holiday", cal, time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC), 1, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)},
+		{"from a holiday", cal, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC), 1, time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		if got := tc.cal.AddWorkingDays(tc.start, tc.days); !got.Equal(tc.expected) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestCalendarCountsDaysInItsLocation(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("Failed to load Asia/Jakarta: %v", err)
+	}
+	cal := calendar.New(jakarta, nil)
+
+	// Friday 20:00 UTC is already Saturday morning in Jakarta
+	fridayEvening := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	if cal.IsWorkingDay(fridayEvening) {
+		t.Error("Expected Friday evening UTC to be a Saturday in Jakarta")
+	}
+
+	// Counting starts at Monday midnight in Jakarta and keeps the Jakarta time of day
+	expected := time.Date(2024, 3, 5, 0, 0, 0, 0, jakarta)
+	if got := cal.AddWorkingDays(fridayEvening, 1); !got.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestWorkingDaysBetween(t *testing.T) {
+	cal := calendar.New(time.UTC, []time.Time{time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)})
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		from, to time.Time
+		expected int
+	}{
+		{day(1), day(1), 0},
+		{day(1), day(4), 1},
+		{day(4), day(8), 4},
+		{day(8), day(12), 1},
+		{day(1), day(15), 9},
+		{day(12), day(8), -1},
+	}
+
+	for _, tc := range cases {
+		if got := cal.WorkingDaysBetween(tc.from, tc.to); got != tc.expected {
+			t.Errorf("WorkingDaysBetween(%s, %s) = %d, expected %d", tc.from.Format("2006-01-02"), tc.to.Format("2006-01-02"), got, tc.expected)
+		}
+	}
+
+	// Counting back what AddWorkingDays added gives the same number
+	start := time.Date(2024, 3, 6, 8, 0, 0, 0, time.UTC)
+	for n := 0; n < 15; n++ {
+		if got := cal.WorkingDaysBetween(start, cal.AddWorkingDays(start, n)); got != n {
+			t.Errorf("WorkingDaysBetween after AddWorkingDays(%d) = %d", n, got)
+		}
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20240311",
+		"DTEND;VALUE=DATE:20240312",
+		"SUMMARY:Hari Suci Nyepi",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20240410",
+		"DTEND;VALUE=DATE:20240412",
+		"SUMMARY:Hari Raya Idul Fitri\\, 1445 H",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20240501T000000Z",
+		"SUMMARY:Hari Buruh Inter",
+		" nasional",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20240502",
+		"SUMMARY:Cancelled",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	holidays, err := calendar.ParseICS(strings.NewReader(ics))
+	if err != nil {
+		t.Fatalf("ParseICS failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"2024-03-11": "Hari Suci Nyepi",
+		"2024-04-10": "Hari Raya Idul Fitri, 1445 H",
+		"2024-04-11": "Hari Raya Idul Fitri, 1445 H",
+		"2024-05-01": "Hari Buruh Internasional",
+	}
+	if len(holidays) != len(expected) {
+		t.Fatalf("expected %d holidays, got %d: %+v", len(expected), len(holidays), holidays)
+	}
+	for _, holiday := range holidays {
+		if name := expected[holiday.Date.Format("2006-01-02")]; name != holiday.Name {
+			t.Errorf("holiday on %s is %q, expected %q", holiday.Date.Format("2006-01-02"), holiday.Name, name)
+		}
+	}
+
+	if _, err := calendar.ParseICS(strings.NewReader("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")); !errors.Is(err, calendar.ErrNoHolidays) {
+		t.Errorf("expected ErrNoHolidays for an empty calendar, got %v", err)
+	}
+}
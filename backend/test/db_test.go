@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"web-work-request-backend/config"
+	"web-work-request-backend/database"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+	"web-work-request-backend/services"
+	"web-work-request-backend/utils"
+
+	"github.com/google/uuid"
+)
+
+// testDBEnv names a scratch PostgreSQL database, reached with the other DB_* settings, for the tests that
+// need one. Its public schema is dropped before every such test, so it must never hold real data. Without
+// it those tests are skipped.
+const testDBEnv = "TEST_DB_NAME"
+
+// testPassword satisfies the default password policy
+const testPassword = "Correct-Horse-42"
+
+// testEnv is a service backed by a freshly migrated database
+type testEnv struct {
+	cfg     *config.Config
+	db      *sql.DB
+	repo    *repository.Repository
+	service *services.Service
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	name := os.Getenv(testDBEnv)
+	if name == "" {
+		t.Skipf("%s is not set; skipping a test that needs PostgreSQL", testDBEnv)
+	}
+
+	cfg := config.Load()
+	cfg.DBName = name
+	cfg.SelfRegistration = true
+	cfg.TOTPRequiredRoles = nil
+
+	resetTestDB(t, cfg)
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("Failed to initialize the test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	keys, err := utils.NewKeyManager(&config.Config{JWTSecret: "test-secret", JWTKeyID: "default", JWTExpiry: "15m"})
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	repo := repository.NewRepository(db)
+	return &testEnv{cfg: cfg, db: db, repo: repo, service: services.NewService(repo, cfg, keys)}
+}
+
+// resetTestDB drops everything InitDB created, so each test starts from an empty schema
+func resetTestDB(t *testing.T, cfg *config.Config) {
+	t.Helper()
+
+	db, err := sql.Open("postgres", fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode))
+	if err != nil {
+		t.Fatalf("Failed to open the test database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`DROP SCHEMA IF EXISTS public CASCADE; CREATE SCHEMA public`); err != nil {
+		t.Fatalf("Failed to reset the test database: %v", err)
+	}
+}
+
+// exec runs a statement the service has no API for, such as moving a clock back
+func (e *testEnv) exec(t *testing.T, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := e.db.Exec(query, args...); err != nil {
+		t.Fatalf("Failed to run %q: %v", query, err)
+	}
+}
+
+// unit creates a unit below parent, or at the top when parent is nil
+func (e *testEnv) unit(t *testing.T, name string, parent *models.Unit) *models.Unit {
+	t.Helper()
+
+	req := &models.UnitRequest{Name: name}
+	if parent != nil {
+		req.ParentID = parent.ID.String()
+	}
+	unit, err := e.service.CreateUnit(req)
+	if err != nil {
+		t.Fatalf("Failed to create unit %s: %v", name, err)
+	}
+	return unit
+}
+
+// setUnitHead makes user the head of unit
+func (e *testEnv) setUnitHead(t *testing.T, unit *models.Unit, head *models.User) {
+	t.Helper()
+
+	req := &models.UnitRequest{Name: unit.Name, HeadUserID: head.ID.String()}
+	if unit.ParentID != nil {
+		req.ParentID = unit.ParentID.String()
+	}
+	updated, err := e.service.UpdateUnit(unit.ID.String(), req)
+	if err != nil {
+		t.Fatalf("Failed to make %s head of %s: %v", head.Username, unit.Name, err)
+	}
+	*unit = *updated
+}
+
+// user creates an active local account with testPassword in unit
+func (e *testEnv) user(t *testing.T, username, role string, unit *models.Unit) *models.User {
+	t.Helper()
+
+	hash, err := utils.HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	user := &models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Name:         strings.ToUpper(username[:1]) + username[1:],
+		Email:        username + "@example.com",
+		Unit:         unit.Name,
+		UnitID:       &unit.ID,
+		Role:         role,
+	}
+	if err := e.repo.CreateUser(user); err != nil {
+		t.Fatalf("Failed to create user %s: %v", username, err)
+	}
+	return user
+}
+
+func actorOf(user *models.User) *services.Actor {
+	return &services.Actor{UserID: user.ID.String(), Role: user.Role}
+}
+
+// pengadaan submits a purchase request for the given items, each priced at harga and requested once
+func (e *testEnv) pengadaan(t *testing.T, requester *models.User, harga float64, items ...string) *models.Request {
+	t.Helper()
+
+	req := &models.CreateRequestRequest{
+		JenisRequest:    models.JenisPengadaan,
+		UnitID:          requester.UnitID.String(),
+		NamaBarangArray: items,
+		TglRequest:      time.Now().Format("2006-01-02"),
+	}
+	for range items {
+		req.JumlahArray = append(req.JumlahArray, 1)
+		req.HargaSatuanArray = append(req.HargaSatuanArray, harga)
+	}
+	return e.submit(t, requester, req)
+}
+
+func (e *testEnv) submit(t *testing.T, requester *models.User, req *models.CreateRequestRequest) *models.Request {
+	t.Helper()
+
+	request, err := e.service.CreateRequest(req, requester.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	return request
+}
+
+// reload reads a request back as a holder of request.view_all would see it
+func (e *testEnv) reload(t *testing.T, request *models.Request) *models.Request {
+	t.Helper()
+
+	reloaded, err := e.service.GetRequestByID(fmt.Sprint(request.ID), &services.Actor{UserID: uuid.Nil.String(), Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatalf("Failed to reload request %d: %v", request.ID, err)
+	}
+	return reloaded
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+// delegationFixture has a single unit head step on every purchase; kiki heads Keuangan and Gudang and sari,
+// from Gudang, can stand in for kiki
+type delegationFixture struct {
+	*approvalFixture
+	sari, budi *models.User
+}
+
+func newDelegationFixture(t *testing.T) *delegationFixture {
+	env := newTestEnv(t)
+	keuangan, gudang := env.unit(t, "Keuangan", nil), env.unit(t, "Gudang", nil)
+	f := &delegationFixture{
+		approvalFixture: &approvalFixture{
+			env:      env,
+			keuangan: keuangan,
+			rina:     env.user(t, "rina", models.RoleUser, keuangan),
+			kiki:     env.user(t, "kiki", models.RoleOperator, keuangan),
+		},
+		sari: env.user(t, "sari", models.RoleUser, gudang),
+		budi: env.user(t, "budi", models.RoleUser, gudang),
+	}
+	env.setUnitHead(t, keuangan, f.kiki)
+	env.setUnitHead(t, gudang, f.kiki)
+
+	_, err := env.service.CreateApprovalChain(&models.ApprovalChainRequest{
+		Name:         "Pengadaan",
+		JenisRequest: models.JenisPengadaan,
+		Steps:        []models.ApprovalStepRequest{{Name: "Kepala unit", ApproverType: models.ApproverUnitHead}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create the chain: %v", err)
+	}
+	return f
+}
+
+// delegate lets substitute stand in for kiki from start to end days from today
+func (f *delegationFixture) delegate(t *testing.T, substitute *models.User, start, end int) *models.Delegation {
+	t.Helper()
+
+	today := time.Now()
+	delegation, err := f.env.service.CreateDelegation(&models.DelegationRequest{
+		SubstituteID: substitute.ID.String(),
+		StartDate:    today.AddDate(0, 0, start).Format("2006-01-02"),
+		EndDate:      today.AddDate(0, 0, end).Format("2006-01-02"),
+		Reason:       "Cuti",
+	}, actorOf(f.kiki))
+	if err != nil {
+		t.Fatalf("Failed to delegate to %s: %v", substitute.Username, err)
+	}
+	return delegation
+}
+
+func TestDelegationsReportTheirStatus(t *testing.T) {
+	f := newDelegationFixture(t)
+
+	active := f.delegate(t, f.sari, 0, 2)
+	scheduled := f.delegate(t, f.budi, 5, 6)
+	if active.Status != models.DelegationActive || scheduled.Status != models.DelegationScheduled {
+		t.Errorf("Expected an active and a scheduled delegation, got %s and %s", active.Status, scheduled.Status)
+	}
+
+	_, err := f.env.service.CreateDelegation(&models.DelegationRequest{
+		SubstituteID: f.budi.ID.String(),
+		StartDate:    time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+		EndDate:      time.Now().AddDate(0, 0, 3).Format("2006-01-02"),
+	}, actorOf(f.kiki))
+	if !errors.Is(err, services.ErrDelegationOverlap) {
+		t.Errorf("Expected a second substitute for the same days to be refused, got %v", err)
+	}
+
+	_, err = f.env.service.CreateDelegation(&models.DelegationRequest{
+		DelegatorID:  f.kiki.ID.String(),
+		SubstituteID: f.budi.ID.String(),
+		StartDate:    time.Now().AddDate(0, 0, 10).Format("2006-01-02"),
+		EndDate:      time.Now().AddDate(0, 0, 11).Format("2006-01-02"),
+	}, actorOf(f.sari))
+	if !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected a user not to register substitutes for someone else, got %v", err)
+	}
+
+	// An expired delegation is only listed on request
+	f.env.exec(t, `UPDATE approval_delegations SET start_date = start_date - 10, end_date = end_date - 10 WHERE id = $1`, active.ID)
+	listed, err := f.env.service.GetDelegations(actorOf(f.kiki), true)
+	if err != nil {
+		t.Fatalf("Failed to list delegations: %v", err)
+	}
+	statuses := make(map[int64]string)
+	for _, delegation := range listed {
+		statuses[delegation.ID] = delegation.Status
+	}
+	if statuses[active.ID] != models.DelegationExpired || statuses[scheduled.ID] != models.DelegationScheduled {
+		t.Errorf("Expected an expired and a scheduled delegation, got %v", statuses)
+	}
+
+	current, err := f.env.service.GetDelegations(actorOf(f.sari), false)
+	if err != nil {
+		t.Fatalf("Failed to list delegations: %v", err)
+	}
+	if len(current) != 0 {
+		t.Errorf("Expected sari's expired delegation to be hidden, got %d", len(current))
+	}
+}
+
+func TestSubstituteDecidesOnBehalfOfApprover(t *testing.T) {
+	f := newDelegationFixture(t)
+	request := f.env.pengadaan(t, f.rina, 500000, "Proyektor")
+	id := fmt.Sprint(request.ID)
+
+	if _, err := f.env.service.GetRequestByID(id, actorOf(f.sari)); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected sari not to see a Keuangan request before the delegation, got %v", err)
+	}
+
+	f.delegate(t, f.sari, 0, 2)
+
+	if _, err := f.env.service.GetRequestByID(id, actorOf(f.sari)); err != nil {
+		t.Errorf("Expected the substitute to see the request, got %v", err)
+	}
+	if !f.pendingFor(t, f.sari, request) {
+		t.Error("Expected the request among the substitute's approvals")
+	}
+
+	// Standing in for an operator only covers approval steps
+	err := f.env.service.UpdateRequestStatus(id, &models.UpdateRequestRequest{StatusRequest: models.StatusDiproses}, actorOf(f.sari))
+	if !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected the substitute not to change statuses directly, got %v", err)
+	}
+
+	decided, err := f.decide(request, models.StatusDisetujui, f.sari)
+	if err != nil {
+		t.Fatalf("Failed to decide as the substitute: %v", err)
+	}
+	step := decided.Approvals[0]
+	if decided.StatusRequest != models.StatusDisetujui || step.DecidedByID == nil || *step.DecidedByID != f.sari.ID {
+		t.Errorf("Expected sari to approve the request, got %s by %v", decided.StatusRequest, step.DecidedByID)
+	}
+	if step.OnBehalfOfID == nil || *step.OnBehalfOfID != f.kiki.ID {
+		t.Errorf("Expected the step to record kiki as the absent approver, got %v", step.OnBehalfOfID)
+	}
+
+	history, err := f.env.service.GetRequestHistory(id, actorOf(f.rina))
+	if err != nil {
+		t.Fatalf("Failed to load the history: %v", err)
+	}
+	last := history[len(history)-1]
+	if last.OnBehalfOf == nil || *last.OnBehalfOf != f.kiki.ID.String() || *last.ChangedBy != f.sari.ID.String() {
+		t.Errorf("Expected the history to show sari acting for kiki, got %+v", last)
+	}
+}
+
+func TestSubstituteCannotDecideOwnRequest(t *testing.T) {
+	f := newDelegationFixture(t)
+	f.delegate(t, f.sari, 0, 2)
+
+	// kiki heads Gudang too, so sari's own request waits for the approver sari stands in for
+	request := f.env.pengadaan(t, f.sari, 500000, "Kursi")
+
+	if f.pendingFor(t, f.sari, request) {
+		t.Error("Expected an own request to be left out of the substitute's approvals")
+	}
+	if _, err := f.decide(request, models.StatusDisetujui, f.sari); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected the substitute to be refused on their own request, got %v", err)
+	}
+}
+
+func TestWithdrawnDelegationEndsTheStandIn(t *testing.T) {
+	f := newDelegationFixture(t)
+	delegation := f.delegate(t, f.sari, 0, 2)
+	request := f.env.pengadaan(t, f.rina, 500000, "Proyektor")
+
+	if err := f.env.service.DeleteDelegation(fmt.Sprint(delegation.ID), actorOf(f.sari)); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected the substitute not to withdraw the delegation, got %v", err)
+	}
+	if err := f.env.service.DeleteDelegation(fmt.Sprint(delegation.ID), actorOf(f.kiki)); err != nil {
+		t.Fatalf("Failed to withdraw the delegation: %v", err)
+	}
+
+	if _, err := f.decide(request, models.StatusDisetujui, f.sari); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected sari to be refused once the delegation is withdrawn, got %v", err)
+	}
+	if f.pendingFor(t, f.sari, request) {
+		t.Error("Expected the request to leave sari's approvals")
+	}
+}
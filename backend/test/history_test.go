@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+func TestStatusChangesBuildTheTimeline(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+
+	request := env.pengadaan(t, requester, 500000, "Proyektor")
+	id := fmt.Sprint(request.ID)
+
+	for _, change := range []models.UpdateRequestRequest{
+		{StatusRequest: models.StatusDisetujui, Keterangan: "Anggaran tersedia"},
+		{StatusRequest: models.StatusDiproses},
+	} {
+		if err := env.service.UpdateRequestStatus(id, &change, actorOf(operator)); err != nil {
+			t.Fatalf("Failed to move the request to %s: %v", change.StatusRequest, err)
+		}
+	}
+
+	history, err := env.service.GetRequestHistory(id, actorOf(requester))
+	if err != nil {
+		t.Fatalf("Failed to load the history: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected the submission and two changes, got %d entries", len(history))
+	}
+
+	submitted, approved, processed := history[0], history[1], history[2]
+	if submitted.OldStatus != nil || submitted.NewStatus != models.StatusDiajukan || *submitted.ChangedBy != requester.ID.String() {
+		t.Errorf("Unexpected submission entry: %+v", submitted)
+	}
+	if *approved.OldStatus != models.StatusDiajukan || approved.NewStatus != models.StatusDisetujui {
+		t.Errorf("Unexpected approval entry: %s -> %s", *approved.OldStatus, approved.NewStatus)
+	}
+	if approved.ChangedByName == nil || *approved.ChangedByName != operator.Name || approved.Catatan == nil || *approved.Catatan != "Anggaran tersedia" {
+		t.Errorf("Expected the approval to name the operator and keep the note, got %+v", approved)
+	}
+	if *processed.OldStatus != models.StatusDisetujui || processed.NewStatus != models.StatusDiproses {
+		t.Errorf("Unexpected processing entry: %s -> %s", *processed.OldStatus, processed.NewStatus)
+	}
+	if processed.CreatedAt.Before(approved.CreatedAt) {
+		t.Error("Expected the timeline oldest first")
+	}
+
+	current := env.reload(t, request)
+	if current.ApprovedByID == nil || *current.ApprovedByID != operator.ID.String() {
+		t.Errorf("Expected the operator as approver, got %v", current.ApprovedByID)
+	}
+	if current.AcceptedBy == nil || *current.AcceptedBy != operator.Name {
+		t.Errorf("Expected the operator as acceptor, got %v", current.AcceptedBy)
+	}
+}
+
+func TestRejectedStatusChangeLeavesNoHistory(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+
+	request := env.pengadaan(t, requester, 500000, "Proyektor")
+	id := fmt.Sprint(request.ID)
+
+	err := env.service.UpdateRequestStatus(id, &models.UpdateRequestRequest{StatusRequest: models.StatusSelesai}, actorOf(operator))
+	var transitionErr *services.TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("Expected a TransitionError, got %v", err)
+	}
+
+	history, err := env.service.GetRequestHistory(id, actorOf(requester))
+	if err != nil {
+		t.Fatalf("Failed to load the history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("Expected only the submission, got %d entries", len(history))
+	}
+}
+
+func TestHistoryFollowsRequestVisibility(t *testing.T) {
+	env := newTestEnv(t)
+	requester := env.user(t, "rina", models.RoleUser, env.unit(t, "Keuangan", nil))
+	outsider := env.user(t, "budi", models.RoleUser, env.unit(t, "Gudang", nil))
+
+	request := env.pengadaan(t, requester, 500000, "Proyektor")
+
+	_, err := env.service.GetRequestHistory(fmt.Sprint(request.ID), actorOf(outsider))
+	if !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected a user from another unit to be forbidden, got %v", err)
+	}
+}
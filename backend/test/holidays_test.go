@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"web-work-request-backend/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importHolidays posts body to the holiday import handler as an authenticated operator
+func importHolidays(t *testing.T, body *bytes.Buffer, contentType string) int {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/holidays/import", func(c *gin.Context) {
+		c.Set("user_id", "00000000-0000-0000-0000-000000000001")
+		c.Set("user_role", "operator")
+	}, handlers.NewHandler(nil).ImportHolidays)
+
+	req := httptest.NewRequest(http.MethodPost, "/holidays/import", body)
+	req.Header.Set("Content-Type", contentType)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestImportHolidaysRejectsLargeMultipartUpload(t *testing.T) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "holidays.ics")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte(strings.Repeat("X", 2<<20)))
+	form.Close()
+
+	if code := importHolidays(t, &body, form.FormDataContentType()); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a 2MB multipart upload, got %d", code)
+	}
+}
+
+func TestImportHolidaysRejectsLargeRawBody(t *testing.T) {
+	body := bytes.NewBufferString("BEGIN:VCALENDAR\r\n" + strings.Repeat("X-FILLER:padding\r\n", 1<<17))
+
+	if code := importHolidays(t, body, "text/calendar"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a 2MB calendar body, got %d", code)
+	}
+}
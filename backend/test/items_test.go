@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+func TestRequestItemsArePersistedWithTheRequest(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+
+	request := env.submit(t, requester, &models.CreateRequestRequest{
+		JenisRequest:     models.JenisPengadaan,
+		UnitID:           unit.ID.String(),
+		NamaBarangArray:  []string{"Laptop", "Mouse"},
+		TypeModelArray:   []string{"ThinkPad T14", "MX Master"},
+		JumlahArray:      []int{2, 5},
+		KeteranganArray:  []string{"Staf baru", "Cadangan"},
+		HargaSatuanArray: []float64{15000000, 1000000},
+		TglRequest:       "2024-03-01",
+	})
+
+	items := env.reload(t, request).Items
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+
+	laptop, mouse := items[0], items[1]
+	if laptop.ItemNo != 1 || *laptop.NamaBarang != "Laptop" || *laptop.TypeModel != "ThinkPad T14" || *laptop.Keterangan != "Staf baru" {
+		t.Errorf("Unexpected first item: %+v", laptop)
+	}
+	if mouse.ItemNo != 2 || *mouse.NamaBarang != "Mouse" || mouse.JumlahDiminta != 5 {
+		t.Errorf("Unexpected second item: %+v", mouse)
+	}
+	for _, item := range items {
+		if item.StatusItem != models.StatusDiajukan || item.JumlahDisetujui != nil {
+			t.Errorf("Expected item %d to wait for a decision, got %s", item.ItemNo, item.StatusItem)
+		}
+	}
+	if laptop.HargaSatuan == nil || *laptop.HargaSatuan != 15000000 {
+		t.Errorf("Expected the laptop price to be stored, got %v", laptop.HargaSatuan)
+	}
+	if request.NilaiEstimasi != 35000000 {
+		t.Errorf("Expected an estimated value of 35000000, got %v", request.NilaiEstimasi)
+	}
+}
+
+func TestRequestItemsAreDecidedOneByOne(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+
+	request := env.submit(t, requester, &models.CreateRequestRequest{
+		JenisRequest:     models.JenisPengadaan,
+		UnitID:           unit.ID.String(),
+		NamaBarangArray:  []string{"Laptop", "Mouse"},
+		JumlahArray:      []int{4, 5},
+		HargaSatuanArray: []float64{15000000, 1000000},
+		TglRequest:       "2024-03-01",
+	})
+	items := env.reload(t, request).Items
+	requestID := fmt.Sprint(request.ID)
+
+	two := 2
+	if _, err := env.service.UpdateRequestItem(requestID, fmt.Sprint(items[0].ID), &models.UpdateRequestItemRequest{
+		StatusItem: models.StatusDisetujui, JumlahDisetujui: &two,
+	}, actorOf(operator)); err != nil {
+		t.Fatalf("Failed to approve the first item: %v", err)
+	}
+	if _, err := env.service.UpdateRequestItem(requestID, fmt.Sprint(items[1].ID), &models.UpdateRequestItemRequest{
+		StatusItem: models.StatusDitolak,
+	}, actorOf(operator)); err != nil {
+		t.Fatalf("Failed to reject the second item: %v", err)
+	}
+
+	tooMany := 5
+	if _, err := env.service.UpdateRequestItem(requestID, fmt.Sprint(items[0].ID), &models.UpdateRequestItemRequest{
+		StatusItem: models.StatusDisetujui, JumlahDisetujui: &tooMany,
+	}, actorOf(operator)); err == nil {
+		t.Error("Expected approving more than was requested to fail")
+	}
+	if _, err := env.service.UpdateRequestItem(requestID, fmt.Sprint(items[0].ID), &models.UpdateRequestItemRequest{
+		StatusItem: models.StatusDitolak,
+	}, actorOf(requester)); err == nil {
+		t.Error("Expected a requester without request.approve to be refused")
+	}
+
+	decided := env.reload(t, request).Items
+	if decided[0].StatusItem != models.StatusDisetujui || decided[0].JumlahDisetujui == nil || *decided[0].JumlahDisetujui != 2 {
+		t.Errorf("Expected 2 of the first item to be approved, got %s %v", decided[0].StatusItem, decided[0].JumlahDisetujui)
+	}
+	if decided[1].StatusItem != models.StatusDitolak || decided[1].JumlahDisetujui == nil || *decided[1].JumlahDisetujui != 0 {
+		t.Errorf("Expected the second item to be rejected with nothing approved, got %s %v", decided[1].StatusItem, decided[1].JumlahDisetujui)
+	}
+	if env.reload(t, request).StatusRequest != models.StatusDiajukan {
+		t.Error("Expected item decisions to leave the request status alone")
+	}
+}
+
+func TestItemsOfClosedRequestsCannotBeDecided(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+
+	request := env.pengadaan(t, requester, 500000, "Proyektor")
+	requestID := fmt.Sprint(request.ID)
+	item := env.reload(t, request).Items[0]
+
+	if err := env.service.UpdateRequestStatus(requestID, &models.UpdateRequestRequest{StatusRequest: models.StatusDitolak}, actorOf(operator)); err != nil {
+		t.Fatalf("Failed to reject the request: %v", err)
+	}
+
+	_, err := env.service.UpdateRequestItem(requestID, fmt.Sprint(item.ID), &models.UpdateRequestItemRequest{
+		StatusItem: models.StatusDisetujui,
+	}, actorOf(operator))
+	if !errors.Is(err, services.ErrRequestClosed) {
+		t.Errorf("Expected an item of a rejected request to stay as it is, got %v", err)
+	}
+	if env.reload(t, request).Items[0].StatusItem != models.StatusDiajukan {
+		t.Error("Expected the item decision not to be stored")
+	}
+}
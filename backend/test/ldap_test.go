@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"web-work-request-backend/config"
+	"web-work-request-backend/directory"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapStubEntry is a directory entry served by ldapStub
+type ldapStubEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// ldapStub is an in-process LDAP server that answers simple binds and equality searches
+type ldapStub struct {
+	listener net.Listener
+	entries  []ldapStubEntry
+}
+
+func startLDAPStub(t *testing.T, entries ...ldapStubEntry) *ldapStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start LDAP stub: %v", err)
+	}
+	stub := &ldapStub{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *ldapStub) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStub) serve(conn net.Conn) {
+	defer conn.Close()
+
+	bound := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if entry := s.find(func(e ldapStubEntry) bool { return e.dn == dn }); entry != nil && entry.password == password {
+				code, bound = ldap.LDAPResultSuccess, true
+			}
+			s.write(conn, messageID, ldapResult(ldap.ApplicationBindResponse, code))
+
+		case ldap.ApplicationSearchRequest:
+			if !bound {
+				s.write(conn, messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				continue
+			}
+
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			attribute, value, _ := strings.Cut(strings.Trim(filter, "()"), "=")
+			if entry := s.find(func(e ldapStubEntry) bool { return contains(e.attributes[attribute], value) }); entry != nil {
+				s.write(conn, messageID, ldapSearchEntry(entry))
+			}
+			s.write(conn, messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *ldapStub) find(match func(ldapStubEntry) bool) *ldapStubEntry {
+	for i := range s.entries {
+		if match(s.entries[i]) {
+			return &s.entries[i]
+		}
+	}
+	return nil
+}
+
+func (s *ldapStub) write(w io.Writer, messageID int64, op *ber.Packet) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	envelope.AppendChild(op)
+	w.Write(envelope.Bytes())
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return op
+}
+
+func ldapSearchEntry(entry *ldapStubEntry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "objectName"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range entry.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+	return op
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestDirectory(stub *ldapStub) *directory.Client {
+	return directory.NewClient(&config.Config{
+		LDAPURL:                 stub.url(),
+		LDAPBindDN:              "cn=service,dc=example,dc=org",
+		LDAPBindPassword:        "service-secret",
+		LDAPBaseDN:              "dc=example,dc=org",
+		LDAPUserFilter:          "(uid=%s)",
+		LDAPUsernameAttribute:   "uid",
+		LDAPNameAttribute:       "displayName",
+		LDAPEmailAttribute:      "mail",
+		LDAPDepartmentAttribute: "department",
+		LDAPGroupAttribute:      "memberOf",
+	})
+}
+
+var testDirectoryEntries = []ldapStubEntry{
+	{dn: "cn=service,dc=example,dc=org", password: "service-secret", attributes: map[string][]string{"cn": {"service"}}},
+	{
+		dn:       "uid=siti,ou=people,dc=example,dc=org",
+		password: "directory-password",
+		attributes: map[string][]string{
+			"uid":         {"siti"},
+			"displayName": {"Siti Rahma"},
+			"mail":        {"siti@example.org"},
+			"department":  {"Keuangan"},
+			"memberOf":    {"cn=staff,ou=groups,dc=example,dc=org", "cn=IT Support,ou=groups,dc=example,dc=org"},
+		},
+	},
+}
+
+func TestDirectoryAuthenticate(t *testing.T) {
+	client := newTestDirectory(startLDAPStub(t, testDirectoryEntries...))
+
+	entry, err := client.Authenticate("siti", "directory-password")
+	if err != nil {
+		t.Fatalf("Expected directory login to succeed, got %v", err)
+	}
+
+	if entry.DN != "uid=siti,ou=people,dc=example,dc=org" || entry.Username != "siti" || entry.Name != "Siti Rahma" ||
+		entry.Email != "siti@example.org" || entry.Department != "Keuangan" || len(entry.Groups) != 2 {
+		t.Errorf("Unexpected directory entry: %+v", entry)
+	}
+}
+
+func TestDirectoryRejectsInvalidCredentials(t *testing.T) {
+	client := newTestDirectory(startLDAPStub(t, testDirectoryEntries...))
+
+	cases := map[string][2]string{
+		"wrong password": {"siti", "wrong-password"},
+		"unknown user":   {"nobody", "directory-password"},
+		// An empty password would be an unauthenticated bind, which real servers accept
+		"empty password": {"siti", ""},
+	}
+	for name, credentials := range cases {
+		if _, err := client.Authenticate(credentials[0], credentials[1]); !errors.Is(err, directory.ErrInvalidCredentials) {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+}
+
+func TestDirectoryServiceBindFailure(t *testing.T) {
+	stub := startLDAPStub(t, testDirectoryEntries[1:]...)
+	client := newTestDirectory(stub)
+
+	_, err := client.Authenticate("siti", "directory-password")
+	if err == nil || errors.Is(err, directory.ErrInvalidCredentials) {
+		t.Errorf("Expected a configuration error when the service account cannot bind, got %v", err)
+	}
+}
+
+func TestDirectoryRoleMapping(t *testing.T) {
+	mapping, err := directory.ParseRoleMapping("admin:cn=Admins,ou=groups,dc=example,dc=org; operator:IT Support", "user")
+	if err != nil {
+		t.Fatalf("Failed to parse role mapping: %v", err)
+	}
+
+	cases := []struct {
+		groups []string
+		role   string
+	}{
+		{[]string{"cn=staff,ou=groups,dc=example,dc=org", "CN=IT Support,OU=Groups,DC=example,DC=org"}, "operator"},
+		{[]string{"CN=admins,OU=groups,DC=example,DC=org", "cn=IT Support,ou=groups,dc=example,dc=org"}, "admin"},
+		{[]string{"cn=Admins,ou=other,dc=example,dc=org"}, "user"},
+		{nil, "user"},
+	}
+	for _, c := range cases {
+		if role := mapping.Role(c.groups); role != c.role {
+			t.Errorf("Expected role %s for groups %v, got %s", c.role, c.groups, role)
+		}
+	}
+
+	if _, err := directory.ParseRoleMapping("operator", "user"); err == nil {
+		t.Error("Expected a group role without a group to be rejected")
+	}
+}
+
+func TestConfigValidatesAuthProviders(t *testing.T) {
+	cfg := &config.Config{
+		ServerMode:           config.ModeDebug,
+		DBPassword:           "a-real-database-password",
+		JWTSecret:            strings.Repeat("x", config.MinJWTSecretLength),
+		JWTExpiry:            "15m",
+		RefreshTokenExpiry:   "720h",
+		LoginLockoutDuration: "15m",
+		PasswordResetExpiry:  "24h",
+		LoginMaxFailures:     10,
+		AuthProviders:        []string{config.AuthProviderLocal, config.AuthProviderLDAP, "kerberos"},
+		LDAPURL:              "ldap://directory.example.org",
+		LDAPUserFilter:       "(uid=%s)",
+	}
+
+	problems := strings.Join(cfg.Problems(), "\n")
+	for _, expected := range []string{"unknown provider kerberos", "LDAP_BASE_DN", "without LDAP_START_TLS"} {
+		if !strings.Contains(problems, expected) {
+			t.Errorf("Expected a problem mentioning %q, got:\n%s", expected, problems)
+		}
+	}
+}
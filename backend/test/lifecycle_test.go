@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+func TestInactiveUsersCannotUseTheirSessions(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+
+	for _, status := range []string{models.UserStatusDeactivated, models.UserStatusDeleted} {
+		user := env.user(t, "user-"+status, models.RoleUser, unit)
+		session, err := env.service.LoginUser(&models.LoginRequest{Username: user.Username, Password: testPassword}, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("%s: failed to sign in: %v", status, err)
+		}
+
+		// Only the status changes, so the refresh token and token version stay valid and the status check
+		// alone has to stop the user
+		env.exec(t, `UPDATE users SET status = $1 WHERE id = $2`, status, user.ID)
+
+		if err := login(env, user.Username); !errors.Is(err, services.ErrAccountNotActive) {
+			t.Errorf("%s: expected the login to be refused, got %v", status, err)
+		}
+		if _, err := env.service.RefreshSession(session.RefreshToken); !errors.Is(err, services.ErrInvalidRefreshToken) {
+			t.Errorf("%s: expected the refresh to be refused, got %v", status, err)
+		}
+		if _, valid, err := env.service.CheckSession(user.ID.String(), user.TokenVersion); err != nil || valid {
+			t.Errorf("%s: expected the session check to fail, got valid=%v err=%v", status, valid, err)
+		}
+
+		env.exec(t, `UPDATE users SET status = $1 WHERE id = $2`, models.UserStatusActive, user.ID)
+
+		if _, err := env.service.RefreshSession(session.RefreshToken); err != nil {
+			t.Errorf("%s: expected the refresh to work once the user is active again, got %v", status, err)
+		}
+	}
+}
+
+func TestDeactivateAndReactivateUser(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+	user := env.user(t, "rina", models.RoleUser, unit)
+
+	session, err := env.service.LoginUser(&models.LoginRequest{Username: "rina", Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Failed to sign in: %v", err)
+	}
+
+	if _, err := env.service.DeactivateUser(user.ID.String(), actorOf(operator)); err != nil {
+		t.Fatalf("Failed to deactivate: %v", err)
+	}
+	if err := login(env, "rina"); !errors.Is(err, services.ErrAccountNotActive) {
+		t.Errorf("Expected a deactivated user to be refused, got %v", err)
+	}
+	if _, err := env.service.RefreshSession(session.RefreshToken); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("Expected deactivation to end the session, got %v", err)
+	}
+	if _, err := env.service.DeactivateUser(user.ID.String(), actorOf(operator)); !errors.Is(err, services.ErrUserStatus) {
+		t.Errorf("Expected deactivating twice to fail, got %v", err)
+	}
+
+	if _, err := env.service.ReactivateUser(user.ID.String(), actorOf(operator)); err != nil {
+		t.Fatalf("Failed to reactivate: %v", err)
+	}
+	if err := login(env, "rina"); err != nil {
+		t.Errorf("Expected a reactivated user to sign in, got %v", err)
+	}
+
+	if _, err := env.service.DeactivateUser(operator.ID.String(), actorOf(operator)); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("Expected operators not to deactivate themselves, got %v", err)
+	}
+}
+
+func TestDeleteAndRestoreUser(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+	user := env.user(t, "rina", models.RoleUser, unit)
+	request := env.pengadaan(t, user, 500000, "Proyektor")
+
+	if err := env.service.DeleteUser(user.ID.String(), actorOf(operator)); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := login(env, "rina"); !errors.Is(err, services.ErrAccountNotActive) {
+		t.Errorf("Expected a deleted user to be refused, got %v", err)
+	}
+
+	users, err := env.service.GetAllUsers(false)
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	for _, listed := range users {
+		if listed.ID == user.ID {
+			t.Error("Expected the deleted user to be hidden from the user list")
+		}
+	}
+	if env.reload(t, request).RequestedBy != user.Name {
+		t.Error("Expected the deleted user's request to keep its requester")
+	}
+
+	restored, err := env.service.RestoreUser(user.ID.String(), actorOf(operator))
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if restored.Status != models.UserStatusActive || restored.DeletedAt != nil {
+		t.Errorf("Expected an active user without a deletion time, got %s %v", restored.Status, restored.DeletedAt)
+	}
+	if err := login(env, "rina"); err != nil {
+		t.Errorf("Expected a restored user to sign in, got %v", err)
+	}
+
+	if _, err := env.service.RestoreUser(user.ID.String(), actorOf(operator)); !errors.Is(err, services.ErrUserStatus) {
+		t.Errorf("Expected restoring an active user to fail, got %v", err)
+	}
+}
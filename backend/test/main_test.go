@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"web-work-request-backend/config"
 )
@@ -36,3 +39,109 @@ func TestConfigDefaultValues(t *testing.T) {
 		t.Errorf("Expected DBPort to be 5432, got %d", cfg.DBPort)
 	}
 }
+
+func TestConfigRefusesDefaultsInProduction(t *testing.T) {
+	cfg := config.Load()
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected defaults to be accepted in debug mode, got %v", err)
+	}
+
+	cfg.ServerMode = config.ModeRelease
+	err := cfg.Validate()
+
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError in release mode, got %v", err)
+	}
+	if len(validationErr.Problems) != 2 {
+		t.Errorf("Expected default DB password and JWT secret to be reported, got %v", validationErr.Problems)
+	}
+
+	cfg.DBPassword = "a-real-database-password"
+	cfg.JWTSecret = "short"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a short JWT secret to be rejected in release mode")
+	}
+
+	// Placeholders from the shipped env files are long enough, but must still be replaced
+	for _, placeholder := range []string{
+		"your-secret-key-here-change-in-production",
+		"your_super_secret_jwt_key_change_in_production",
+		"our-own-long-secret-but-CHANGE-IN-PRODUCTION",
+	} {
+		cfg.JWTSecret = placeholder
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected placeholder JWT secret %q to be rejected in release mode", placeholder)
+		}
+	}
+
+	cfg.JWTSecret = strings.Repeat("k", config.MinJWTSecretLength)
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected strong settings to be accepted, got %v", err)
+	}
+}
+
+func TestConfigRedactsSecrets(t *testing.T) {
+	cfg := config.Load()
+	cfg.DBPassword = "db-secret-value"
+	cfg.JWTSecret = "jwt-secret-value"
+
+	logged := fmt.Sprint(cfg)
+	if strings.Contains(logged, "db-secret-value") || strings.Contains(logged, "jwt-secret-value") {
+		t.Errorf("Expected secrets to be redacted, got %s", logged)
+	}
+
+	for _, setting := range cfg.Settings() {
+		if setting.Key == "DB_PASSWORD" && setting.Value != config.Redact("password") {
+			t.Errorf("Expected DB_PASSWORD to be redacted in settings, got %s", setting.Value)
+		}
+		if setting.Source != config.SourceDefault && setting.Source != config.SourceEnvironment {
+			t.Errorf("Unexpected source %s for %s", setting.Source, setting.Key)
+		}
+	}
+}
+
+func TestConfigSelfRegistration(t *testing.T) {
+	if !config.Load().SelfRegistration {
+		t.Error("Expected self-registration to be enabled by default")
+	}
+
+	t.Setenv("SELF_REGISTRATION", "false")
+	if config.Load().SelfRegistration {
+		t.Error("Expected SELF_REGISTRATION=false to disable self-registration")
+	}
+}
+
+func TestConfigChecksCORSOrigins(t *testing.T) {
+	cfg := config.Load()
+	if !cfg.AllowsOrigin("http://localhost:3000") || cfg.AllowsOrigin("http://evil.example") || cfg.AllowsOrigin("") {
+		t.Errorf("Expected only the default frontend origin to be allowed, got %v", cfg.CORSAllowedOrigins)
+	}
+
+	cfg.CORSAllowedOrigins = []string{"*"}
+	if !containsProblem(cfg.Problems(), "CORS_ALLOWED_ORIGINS") {
+		t.Errorf("Expected * to be reported, got %v", cfg.Problems())
+	}
+
+	// The single sign-on callback page must be able to call the API with credentials
+	cfg.CORSAllowedOrigins = []string{"https://work.example.org"}
+	cfg.OIDCIssuer, cfg.OIDCClientID = "https://login.example.org", "work-request"
+	cfg.OIDCRedirectURL = "https://other.example.org/login/oidc"
+	if !containsProblem(cfg.Problems(), "OIDC_REDIRECT_URL") {
+		t.Errorf("Expected a redirect URL on an unlisted origin to be reported, got %v", cfg.Problems())
+	}
+	cfg.OIDCRedirectURL = "https://work.example.org/login/oidc"
+	if containsProblem(cfg.Problems(), "OIDC_REDIRECT_URL") {
+		t.Errorf("Expected a redirect URL on a listed origin to be accepted, got %v", cfg.Problems())
+	}
+}
+
+func containsProblem(problems []string, setting string) bool {
+	for _, problem := range problems {
+		if strings.HasPrefix(problem, setting) {
+			return true
+		}
+	}
+	return false
+}
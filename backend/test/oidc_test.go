@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+	"web-work-request-backend/config"
+	"web-work-request-backend/handlers"
+	"web-work-request-backend/middleware"
+	"web-work-request-backend/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mockClientID     = "work-request"
+	mockClientSecret = "client-secret"
+	mockRedirectURL  = "http://localhost:3000/login/callback"
+)
+
+// mockAuthorization is what the mock issuer remembers about an authorization code
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+// mockIssuer is a local OpenID Connect provider: discovery, JWKS and a token endpoint that checks PKCE
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+	// claims are added to every ID token and may override the defaults
+	claims jwt.MapClaims
+}
+
+func startMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	m := &mockIssuer{key: key, codes: make(map[string]mockAuthorization), claims: jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.token)
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize plays the user signing in at the provider and returns the code sent to the callback
+func (m *mockIssuer) authorize(t *testing.T, authURL string) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid authorization URL: %v", err)
+	}
+	query := parsed.Query()
+
+	if query.Get("client_id") != mockClientID || query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != "S256" || query.Get("redirect_uri") != mockRedirectURL {
+		t.Fatalf("Unexpected authorization request: %s", authURL)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := "code-" + query.Get("state")
+	m.codes[code] = mockAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	return code
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, _ := r.BasicAuth()
+	if clientID != mockClientID || secret != mockClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	authorization, ok := m.codes[r.FormValue("code")]
+	delete(m.codes, r.FormValue("code"))
+	m.mu.Unlock()
+
+	if !ok || oidc.S256Challenge(r.FormValue("code_verifier")) != authorization.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            mockClientID,
+		"sub":            "subject-42",
+		"nonce":          authorization.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"email":          "dewi@example.org",
+		"email_verified": true,
+		"name":           "Dewi Lestari",
+		"groups":         []string{"staff", "IT Support"},
+	}
+	for name, value := range m.claims {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock-key"
+	idToken, _ := token.SignedString(m.key)
+
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": idToken})
+}
+
+func newTestProvider(issuer *mockIssuer) *oidc.Provider {
+	return oidc.NewProvider(&config.Config{
+		OIDCIssuer:       issuer.server.URL,
+		OIDCClientID:     mockClientID,
+		OIDCClientSecret: mockClientSecret,
+		OIDCRedirectURL:  mockRedirectURL,
+		OIDCScopes:       []string{"openid", "profile", "email"},
+	})
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	issuer := startMockIssuer(t)
+	provider := newTestProvider(issuer)
+
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		t.Fatalf("Failed to generate verifier: %v", err)
+	}
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("Failed to build authorization URL: %v", err)
+	}
+	code := issuer.authorize(t, authURL)
+
+	idToken, err := provider.Exchange(code, verifier)
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(idToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("Failed to verify ID token: %v", err)
+	}
+
+	if claims.Subject() != "subject-42" || claims.String("email") != "dewi@example.org" || !claims.EmailVerified() {
+		t.Errorf("Unexpected claims: %v", claims)
+	}
+	if groups := claims.Strings("groups"); len(groups) != 2 || groups[1] != "IT Support" {
+		t.Errorf("Expected groups claim to be read as a list, got %v", groups)
+	}
+
+	// A code can only be redeemed once
+	if _, err := provider.Exchange(code, verifier); err == nil {
+		t.Error("Expected a used code to be rejected")
+	}
+}
+
+func TestOIDCRejectsWrongVerifier(t *testing.T) {
+	issuer := startMockIssuer(t)
+	provider := newTestProvider(issuer)
+
+	verifier, _ := oidc.GenerateVerifier()
+	authURL, _ := provider.AuthCodeURL("state-1", "nonce-1", verifier)
+	code := issuer.authorize(t, authURL)
+
+	other, _ := oidc.GenerateVerifier()
+	if _, err := provider.Exchange(code, other); err == nil {
+		t.Error("Expected the token endpoint to reject a code with the wrong PKCE verifier")
+	}
+}
+
+func TestOIDCVerifiesIDTokenClaims(t *testing.T) {
+	cases := map[string]struct {
+		claims jwt.MapClaims
+		nonce  string
+	}{
+		"wrong nonce":    {jwt.MapClaims{}, "other-nonce"},
+		"wrong audience": {jwt.MapClaims{"aud": "another-client"}, "nonce-1"},
+		"wrong issuer":   {jwt.MapClaims{"iss": "https://evil.example.org"}, "nonce-1"},
+		"expired":        {jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, "nonce-1"},
+		"no subject":     {jwt.MapClaims{"sub": ""}, "nonce-1"},
+	}
+
+	for name, c := range cases {
+		issuer := startMockIssuer(t)
+		issuer.claims = c.claims
+		provider := newTestProvider(issuer)
+
+		verifier, _ := oidc.GenerateVerifier()
+		authURL, _ := provider.AuthCodeURL("state-1", "nonce-1", verifier)
+		idToken, err := provider.Exchange(issuer.authorize(t, authURL), verifier)
+		if err != nil {
+			t.Fatalf("%s: failed to exchange code: %v", name, err)
+		}
+
+		if _, err := provider.VerifyIDToken(idToken, c.nonce); !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Errorf("%s: expected ErrInvalidIDToken, got %v", name, err)
+		}
+	}
+}
+
+func TestOIDCRejectsUnsignedIDToken(t *testing.T) {
+	issuer := startMockIssuer(t)
+	provider := newTestProvider(issuer)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"iss":   issuer.server.URL,
+		"aud":   mockClientID,
+		"sub":   "subject-42",
+		"nonce": "nonce-1",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+	unsigned, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	if _, err := provider.VerifyIDToken(unsigned, "nonce-1"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Expected an unsigned ID token to be rejected, got %v", err)
+	}
+}
+
+func TestOIDCChecksDiscoveredIssuer(t *testing.T) {
+	issuer := startMockIssuer(t)
+	provider := oidc.NewProvider(&config.Config{
+		// Same server under another name: the discovery document names a different issuer
+		OIDCIssuer:      "http://localhost:" + mustParseURL(t, issuer.server.URL).Port(),
+		OIDCClientID:    mockClientID,
+		OIDCRedirectURL: mockRedirectURL,
+	})
+
+	if _, err := provider.AuthCodeURL("state-1", "nonce-1", "verifier"); err == nil {
+		t.Error("Expected discovery to fail when the document is for another issuer")
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("Invalid URL %s: %v", raw, err)
+	}
+	return parsed
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// Example from RFC 7636 appendix B
+	if got := oidc.S256Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("Unexpected S256 challenge %s", got)
+	}
+}
+
+func TestCORSEchoesAllowedOriginWithCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{CORSAllowedOrigins: []string{"http://localhost:3000"}}
+	router := gin.New()
+	router.Use(middleware.CORSMiddleware(cfg.AllowsOrigin))
+	router.GET("/api/auth/oidc/callback", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for origin, allowed := range map[string]bool{"http://localhost:3000": true, "http://evil.example": false} {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback", nil)
+		req.Header.Set("Origin", origin)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		got := recorder.Header().Get("Access-Control-Allow-Origin")
+		if allowed && (got != origin || recorder.Header().Get("Access-Control-Allow-Credentials") != "true") {
+			t.Errorf("Expected %s to be allowed with credentials, got origin %q", origin, got)
+		}
+		if !allowed && got != "" {
+			t.Errorf("Expected %s to be refused, got origin %q", origin, got)
+		}
+	}
+}
+
+func TestStateCookieTrustsForwardedProtoOnlyFromProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, err := handlers.NewHandler(nil).WithTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+	router := gin.New()
+	router.GET("/api/auth/oidc/callback", handler.OIDCCallback)
+
+	// A refused sign-in clears the state cookie without reaching the service
+	for remote, secure := range map[string]bool{"10.1.2.3:40000": true, "203.0.113.9:40000": false} {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?error=access_denied", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-Proto", "https")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		cookies := recorder.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Secure != secure {
+			t.Errorf("Expected a state cookie with Secure=%v from %s, got %v", secure, remote, cookies)
+		}
+	}
+}
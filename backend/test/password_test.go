@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"web-work-request-backend/services"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := services.PasswordPolicy{MinLength: 10, RequiredClasses: []string{"lower", "upper", "digit"}}
+
+	if err := policy.Validate("Pengadaan2026"); err != nil {
+		t.Errorf("Expected password to satisfy the policy, got %v", err)
+	}
+
+	err := policy.Validate("short")
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expected PasswordPolicyError, got %v", err)
+	}
+
+	// Too short, no uppercase letter and no digit
+	if len(policyErr.Problems) != 3 {
+		t.Errorf("Expected 3 problems, got %v", policyErr.Problems)
+	}
+}
+
+func TestPasswordPolicySymbols(t *testing.T) {
+	policy := services.PasswordPolicy{MinLength: 8, RequiredClasses: []string{"symbol"}}
+
+	if err := policy.Validate("password"); err == nil {
+		t.Error("Expected a password without symbols to be rejected")
+	}
+	if err := policy.Validate("pass-word"); err != nil {
+		t.Errorf("Expected a password with a symbol to be accepted, got %v", err)
+	}
+}
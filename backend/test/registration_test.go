@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+// register signs up username in unit, asking for role
+func register(t *testing.T, env *testEnv, username, role string, unit *models.Unit) *models.User {
+	t.Helper()
+
+	user, err := env.service.RegisterUser(&models.RegisterRequest{
+		Username: username,
+		Password: testPassword,
+		Name:     "Self Registered",
+		Email:    username + "@example.com",
+		Unit:     unit.Name,
+		Role:     role,
+	})
+	if err != nil {
+		t.Fatalf("Failed to register %s: %v", username, err)
+	}
+	return user
+}
+
+func login(env *testEnv, username string) error {
+	_, err := env.service.LoginUser(&models.LoginRequest{Username: username, Password: testPassword}, "192.0.2.1")
+	return err
+}
+
+func TestSelfRegistrationIsPendingUser(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+
+	// The handler only binds "user", but the service must not trust the role either
+	registered := register(t, env, "rina", models.RoleAdmin, unit)
+
+	stored, err := env.repo.GetUserByID(registered.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to load the registered user: %v", err)
+	}
+	if stored.Role != models.RoleUser || stored.Status != models.UserStatusPending {
+		t.Errorf("Expected a pending user, got role %s and status %s", stored.Role, stored.Status)
+	}
+	if stored.UnitID == nil || *stored.UnitID != unit.ID {
+		t.Errorf("Expected the registration to be linked to %s, got %v", unit.Name, stored.UnitID)
+	}
+
+	if err := login(env, "rina"); !errors.Is(err, services.ErrAccountNotActive) {
+		t.Errorf("Expected a pending account to be refused at login, got %v", err)
+	}
+
+	pending, err := env.service.GetPendingRegistrations()
+	if err != nil {
+		t.Fatalf("Failed to list pending registrations: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != registered.ID {
+		t.Errorf("Expected the registration to wait for approval, got %d pending", len(pending))
+	}
+}
+
+func TestApprovedRegistrationCanSignIn(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+	registered := register(t, env, "rina", "", unit)
+
+	approved, err := env.service.ApproveRegistration(registered.ID.String(), actorOf(operator))
+	if err != nil {
+		t.Fatalf("Failed to approve the registration: %v", err)
+	}
+	if approved.Status != models.UserStatusActive || approved.Role != models.RoleUser {
+		t.Errorf("Expected an active user, got role %s and status %s", approved.Role, approved.Status)
+	}
+
+	if err := login(env, "rina"); err != nil {
+		t.Errorf("Expected the approved user to sign in, got %v", err)
+	}
+
+	if _, err := env.service.ApproveRegistration(registered.ID.String(), actorOf(operator)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected approving an active user again to find no pending registration, got %v", err)
+	}
+}
+
+func TestRejectedRegistrationIsRemoved(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+	registered := register(t, env, "rina", "", unit)
+
+	if err := env.service.RejectRegistration(registered.ID.String(), actorOf(operator)); err != nil {
+		t.Fatalf("Failed to reject the registration: %v", err)
+	}
+	if _, err := env.repo.GetUserByID(registered.ID.String()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the rejected account to be removed, got %v", err)
+	}
+
+	// The username and email are free again
+	register(t, env, "rina", "", unit)
+
+	if err := env.service.RejectRegistration(operator.ID.String(), actorOf(operator)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected an active account not to be rejected, got %v", err)
+	}
+}
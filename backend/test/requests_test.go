@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+// listFixture holds three requests from two units on different dates
+type listFixture struct {
+	env                        *testEnv
+	operator, rina, budi       *models.User
+	laptop, printer, proyektor *models.Request
+}
+
+func newListFixture(t *testing.T) *listFixture {
+	env := newTestEnv(t)
+	keuangan, gudang := env.unit(t, "Keuangan", nil), env.unit(t, "Gudang", nil)
+	f := &listFixture{
+		env:      env,
+		operator: env.user(t, "oki", models.RoleOperator, keuangan),
+		rina:     env.user(t, "rina", models.RoleUser, keuangan),
+		budi:     env.user(t, "budi", models.RoleUser, gudang),
+	}
+
+	f.laptop = env.submit(t, f.rina, &models.CreateRequestRequest{
+		JenisRequest:     models.JenisPengadaan,
+		UnitID:           keuangan.ID.String(),
+		NamaBarangArray:  []string{"Laptop"},
+		JumlahArray:      []int{1},
+		HargaSatuanArray: []float64{15000000},
+		TglRequest:       "2024-03-01",
+	})
+	f.printer = env.submit(t, f.budi, &models.CreateRequestRequest{
+		JenisRequest:             models.JenisPerbaikan,
+		UnitID:                   gudang.ID.String(),
+		NamaBarangPerbaikanArray: []string{"Printer"},
+		JumlahPerbaikanArray:     []int{1},
+		LokasiPerbaikanArray:     []string{"Ruang Arsip"},
+		HargaSatuanArray:         []float64{750000},
+		TglRequest:               "2024-03-10",
+	})
+	f.proyektor = env.submit(t, f.rina, &models.CreateRequestRequest{
+		JenisRequest:          models.JenisPeminjaman,
+		UnitID:                keuangan.ID.String(),
+		LokasiPeminjamanArray: []string{"Ruang Rapat"},
+		KegunaanArray:         []string{"Proyektor untuk rapat"},
+		TglRequest:            "2024-03-20",
+	})
+	return f
+}
+
+// list returns the IDs of one page of requests and the pagination totals
+func (f *listFixture) list(t *testing.T, actor *models.User, filter models.RequestFilter, page, limit int) ([]int64, *models.PaginationResponse) {
+	t.Helper()
+
+	response, err := f.env.service.ListRequests(&filter, &models.PaginationRequest{Page: page, Limit: limit}, actorOf(actor))
+	if err != nil {
+		t.Fatalf("Failed to list requests with %+v: %v", filter, err)
+	}
+	var ids []int64
+	for _, request := range response.Data.([]models.Request) {
+		ids = append(ids, request.ID)
+	}
+	return ids, response
+}
+
+func sameIDs(got []int64, want ...*models.Request) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListRequestsFilters(t *testing.T) {
+	f := newListFixture(t)
+
+	cases := []struct {
+		name     string
+		filter   models.RequestFilter
+		expected []*models.Request
+	}{
+		{"request type", models.RequestFilter{JenisRequest: models.JenisPerbaikan}, []*models.Request{f.printer}},
+		{"unit name in any case", models.RequestFilter{Unit: "keuangan", Sort: "id"}, []*models.Request{f.laptop, f.proyektor}},
+		{"requester", models.RequestFilter{RequestedByID: f.budi.ID.String()}, []*models.Request{f.printer}},
+		{"date range", models.RequestFilter{TglFrom: "2024-03-05", TglTo: "2024-03-20", Sort: "tgl_request"}, []*models.Request{f.printer, f.proyektor}},
+		{"combined", models.RequestFilter{Unit: "Keuangan", TglTo: "2024-03-10"}, []*models.Request{f.laptop}},
+		{"nothing matches", models.RequestFilter{Status: models.StatusSelesai}, nil},
+	}
+
+	for _, tc := range cases {
+		ids, response := f.list(t, f.operator, tc.filter, 1, 10)
+		if !sameIDs(ids, tc.expected...) || response.Total != int64(len(tc.expected)) {
+			t.Errorf("%s: got %v (total %d), expected %d requests", tc.name, ids, response.Total, len(tc.expected))
+		}
+	}
+}
+
+func TestListRequestsSortsAndPaginates(t *testing.T) {
+	f := newListFixture(t)
+
+	ids, response := f.list(t, f.operator, models.RequestFilter{Sort: "-tgl_request"}, 1, 2)
+	if !sameIDs(ids, f.proyektor, f.printer) {
+		t.Errorf("Expected the newest two requests on page 1, got %v", ids)
+	}
+	if response.Total != 3 || response.TotalPages != 2 {
+		t.Errorf("Expected 3 requests on 2 pages, got %d on %d", response.Total, response.TotalPages)
+	}
+
+	ids, _ = f.list(t, f.operator, models.RequestFilter{Sort: "-tgl_request"}, 2, 2)
+	if !sameIDs(ids, f.laptop) {
+		t.Errorf("Expected the oldest request on page 2, got %v", ids)
+	}
+
+	ids, _ = f.list(t, f.operator, models.RequestFilter{Sort: "jenis_request"}, 1, 10)
+	if !sameIDs(ids, f.proyektor, f.laptop, f.printer) {
+		t.Errorf("Expected peminjaman, pengadaan, perbaikan, got %v", ids)
+	}
+}
+
+func TestListRequestsRejectsUnknownSortKeys(t *testing.T) {
+	f := newListFixture(t)
+
+	for _, sort := range []string{"password_hash", "-requested_by", "created_at; DROP TABLE request", "--id"} {
+		_, err := f.env.service.ListRequests(&models.RequestFilter{Sort: sort}, &models.PaginationRequest{Page: 1, Limit: 10}, actorOf(f.operator))
+		if !errors.Is(err, services.ErrInvalidFilter) {
+			t.Errorf("Expected sort %q to be refused, got %v", sort, err)
+		}
+	}
+}
+
+func TestListRequestsOnlyShowsVisibleRequests(t *testing.T) {
+	f := newListFixture(t)
+
+	ids, response := f.list(t, f.budi, models.RequestFilter{}, 1, 10)
+	if !sameIDs(ids, f.printer) || response.Total != 1 {
+		t.Errorf("Expected budi to see only the Gudang request, got %v (total %d)", ids, response.Total)
+	}
+
+	ids, _ = f.list(t, f.budi, models.RequestFilter{Unit: "Keuangan"}, 1, 10)
+	if len(ids) != 0 {
+		t.Errorf("Expected a filter not to widen what budi sees, got %v", ids)
+	}
+}
+
+func TestCreateRequestChecksItemArrays(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+
+	cases := []struct {
+		name  string
+		req   models.CreateRequestRequest
+		valid bool
+	}{
+		{"pengadaan with its required arrays", models.CreateRequestRequest{
+			JenisRequest: models.JenisPengadaan, NamaBarangArray: []string{"Laptop"}, JumlahArray: []int{1}, HargaSatuanArray: []float64{15000000},
+		}, true},
+		{"pengadaan without quantities", models.CreateRequestRequest{
+			JenisRequest: models.JenisPengadaan, NamaBarangArray: []string{"Laptop"}, HargaSatuanArray: []float64{15000000},
+		}, false},
+		{"pengadaan with only an optional array", models.CreateRequestRequest{
+			JenisRequest: models.JenisPengadaan, TypeModelArray: []string{"ThinkPad T14"},
+		}, false},
+		{"pengadaan with a short optional array", models.CreateRequestRequest{
+			JenisRequest: models.JenisPengadaan, NamaBarangArray: []string{"Laptop", "Mouse"}, JumlahArray: []int{1, 2},
+			TypeModelArray: []string{"ThinkPad T14"}, HargaSatuanArray: []float64{15000000, 100000},
+		}, false},
+		{"pengadaan with a zero quantity", models.CreateRequestRequest{
+			JenisRequest: models.JenisPengadaan, NamaBarangArray: []string{"Laptop"}, JumlahArray: []int{0}, HargaSatuanArray: []float64{15000000},
+		}, false},
+		{"perbaikan without a location", models.CreateRequestRequest{
+			JenisRequest: models.JenisPerbaikan, NamaBarangPerbaikanArray: []string{"Printer"}, JumlahPerbaikanArray: []int{1},
+			HargaSatuanArray: []float64{750000},
+		}, false},
+		{"peminjaman with only a location", models.CreateRequestRequest{
+			JenisRequest: models.JenisPeminjaman, LokasiPeminjamanArray: []string{"Ruang Rapat"},
+		}, true},
+		{"peminjaman without a location", models.CreateRequestRequest{
+			JenisRequest: models.JenisPeminjaman, KegunaanArray: []string{"Rapat"},
+		}, false},
+	}
+
+	for _, tc := range cases {
+		tc.req.UnitID = unit.ID.String()
+		tc.req.TglRequest = "2024-03-01"
+		_, err := env.service.CreateRequest(&tc.req, requester.ID.String())
+		if tc.valid && err != nil {
+			t.Errorf("%s: expected the request to be accepted, got %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected the request to be refused", tc.name)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"web-work-request-backend/database"
+	"web-work-request-backend/models"
+)
+
+func TestEmptiedRoleStaysEmptyAcrossRestarts(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.user(t, "adi", models.RoleAdmin, env.unit(t, "Sekretariat", nil))
+
+	if _, err := env.service.UpsertRole(models.RoleOperator, &models.UpsertRoleRequest{Description: "Handles requests"}, actorOf(admin)); err != nil {
+		t.Fatalf("Failed to empty the operator role: %v", err)
+	}
+
+	// A restart runs the schema setup again
+	db, err := database.InitDB(env.cfg)
+	if err != nil {
+		t.Fatalf("Failed to initialize the database again: %v", err)
+	}
+	db.Close()
+
+	roles, err := env.service.GetRoles()
+	if err != nil {
+		t.Fatalf("Failed to list roles: %v", err)
+	}
+	for _, role := range roles {
+		switch role.Name {
+		case models.RoleOperator:
+			if len(role.Permissions) != 0 {
+				t.Errorf("Expected the emptied operator role to stay empty, got %v", role.Permissions)
+			}
+		case models.RoleUser:
+			if len(role.Permissions) != 1 || role.Permissions[0] != models.PermissionRequestCreate {
+				t.Errorf("Expected the user role to keep its default grant, got %v", role.Permissions)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+)
+
+func TestHighlightSnippetEscapesRequestText(t *testing.T) {
+	headline := "<script>alert(1)</script> \uE000laptop\uE001 & \"mouse\""
+
+	got := repository.HighlightSnippet(headline)
+	want := "&lt;script&gt;alert(1)&lt;/script&gt; <mark>laptop</mark> &amp; &#34;mouse&#34;"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestHighlightSnippetBalancesMarks(t *testing.T) {
+	// A stray stop marker is dropped and an unterminated match is closed
+	got := repository.HighlightSnippet("a\uE001 \uE000b")
+	if got != "a <mark>b</mark>" {
+		t.Errorf("Expected balanced marks, got %q", got)
+	}
+}
+
+// search returns the results of a full-text search as the given user
+func search(t *testing.T, env *testEnv, actor *models.User, q string) []models.RequestSearchResult {
+	t.Helper()
+
+	response, err := env.service.SearchRequests(q, &models.PaginationRequest{Page: 1, Limit: 10}, actorOf(actor))
+	if err != nil {
+		t.Fatalf("Failed to search for %q: %v", q, err)
+	}
+	return response.Data.([]models.RequestSearchResult)
+}
+
+func TestSearchRanksItemNamesAboveNotes(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+	operator := env.user(t, "oki", models.RoleOperator, unit)
+
+	cable := env.submit(t, requester, &models.CreateRequestRequest{
+		JenisRequest:     models.JenisPengadaan,
+		UnitID:           unit.ID.String(),
+		NamaBarangArray:  []string{"Kabel HDMI"},
+		JumlahArray:      []int{1},
+		KeteranganArray:  []string{"Untuk proyektor ruang rapat"},
+		HargaSatuanArray: []float64{150000},
+		TglRequest:       "2024-03-01",
+	})
+	projector := env.pengadaan(t, requester, 8000000, "Proyektor")
+	env.pengadaan(t, requester, 15000000, "Laptop")
+
+	results := search(t, env, operator, "proyektor")
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(results))
+	}
+	if results[0].ID != projector.ID || results[1].ID != cable.ID {
+		t.Errorf("Expected the item name match before the note match, got %d then %d", results[0].ID, results[1].ID)
+	}
+	if results[0].Rank <= results[1].Rank {
+		t.Errorf("Expected a higher rank for the item name match, got %v and %v", results[0].Rank, results[1].Rank)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>Proyektor</mark>") {
+		t.Errorf("Expected the match to be highlighted, got %q", results[0].Snippet)
+	}
+
+	if results := search(t, env, operator, "rina"); len(results) != 3 {
+		t.Errorf("Expected the requester name to match all 3 requests, got %d", len(results))
+	}
+}
+
+func TestSearchOnlyFindsVisibleRequests(t *testing.T) {
+	env := newTestEnv(t)
+	requester := env.user(t, "rina", models.RoleUser, env.unit(t, "Keuangan", nil))
+	outsider := env.user(t, "budi", models.RoleUser, env.unit(t, "Gudang", nil))
+
+	env.pengadaan(t, requester, 8000000, "Proyektor")
+
+	if results := search(t, env, outsider, "proyektor"); len(results) != 0 {
+		t.Errorf("Expected a user from another unit to find nothing, got %d results", len(results))
+	}
+	if results := search(t, env, requester, "proyektor"); len(results) != 1 {
+		t.Errorf("Expected the requester to find their request, got %d results", len(results))
+	}
+}
+
+func TestSearchSnippetsEscapeRequestText(t *testing.T) {
+	env := newTestEnv(t)
+	unit := env.unit(t, "Keuangan", nil)
+	requester := env.user(t, "rina", models.RoleUser, unit)
+
+	env.submit(t, requester, &models.CreateRequestRequest{
+		JenisRequest:     models.JenisPengadaan,
+		UnitID:           unit.ID.String(),
+		NamaBarangArray:  []string{"<img src=x onerror=alert(1)> proyektor"},
+		JumlahArray:      []int{1},
+		HargaSatuanArray: []float64{100000},
+		TglRequest:       "2024-03-01",
+	})
+
+	results := search(t, env, requester, "proyektor")
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(results))
+	}
+	if strings.Contains(results[0].Snippet, "<img") {
+		t.Errorf("Expected the request text to be escaped, got %q", results[0].Snippet)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"web-work-request-backend/models"
+	"web-work-request-backend/repository"
+)
+
+// slaFixture has a two working day target on submitted purchases in Keuangan, headed by kiki, below the
+// Direktorat, headed by dina
+type slaFixture struct {
+	env                   *testEnv
+	keuangan              *models.Unit
+	rina, kiki, dina, oki *models.User
+}
+
+func newSLAFixture(t *testing.T) *slaFixture {
+	env := newTestEnv(t)
+	direktorat := env.unit(t, "Direktorat", nil)
+	keuangan := env.unit(t, "Keuangan", direktorat)
+	f := &slaFixture{
+		env:      env,
+		keuangan: keuangan,
+		rina:     env.user(t, "rina", models.RoleUser, keuangan),
+		kiki:     env.user(t, "kiki", models.RoleUser, keuangan),
+		dina:     env.user(t, "dina", models.RoleUser, direktorat),
+		oki:      env.user(t, "oki", models.RoleOperator, direktorat),
+	}
+	env.setUnitHead(t, direktorat, f.dina)
+	env.setUnitHead(t, keuangan, f.kiki)
+
+	if _, err := env.service.SaveSLATarget(&models.SLATargetRequest{JenisRequest: models.JenisPengadaan, Status: models.StatusDiajukan, WorkingDays: 2}); err != nil {
+		t.Fatalf("Failed to save the SLA target: %v", err)
+	}
+	return f
+}
+
+// overdue moves the SLA clock of a request, and of its last escalation, a month back
+func (f *slaFixture) overdue(t *testing.T, request *models.Request) {
+	t.Helper()
+	f.env.exec(t, `UPDATE request SET status_since = status_since - INTERVAL '30 days',
+		escalated_at = escalated_at - INTERVAL '30 days' WHERE id = $1`, request.ID)
+}
+
+func (f *slaFixture) escalate(t *testing.T) int {
+	t.Helper()
+
+	escalated, err := f.env.service.EscalateOverdueRequests(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to escalate: %v", err)
+	}
+	return escalated
+}
+
+func TestSLAFlagsBreachedRequests(t *testing.T) {
+	f := newSLAFixture(t)
+	request := f.env.pengadaan(t, f.rina, 500000, "Proyektor")
+
+	fresh := f.env.reload(t, request)
+	if fresh.DueAt == nil || !fresh.DueAt.After(fresh.StatusSince) || fresh.SLABreached {
+		t.Errorf("Expected a due date ahead and no breach, got due %v breached %v", fresh.DueAt, fresh.SLABreached)
+	}
+
+	f.overdue(t, request)
+	late := f.env.reload(t, request)
+	if late.DueAt == nil || !late.SLABreached {
+		t.Errorf("Expected the request to breach its SLA, got due %v breached %v", late.DueAt, late.SLABreached)
+	}
+
+	// DISETUJUI has no target
+	err := f.env.service.UpdateRequestStatus(fmt.Sprint(request.ID), &models.UpdateRequestRequest{StatusRequest: models.StatusDisetujui}, actorOf(f.oki))
+	if err != nil {
+		t.Fatalf("Failed to approve: %v", err)
+	}
+	approved := f.env.reload(t, request)
+	if approved.DueAt != nil || approved.SLABreached {
+		t.Errorf("Expected no SLA on a status without a target, got due %v breached %v", approved.DueAt, approved.SLABreached)
+	}
+}
+
+func TestOverdueRequestsEscalateUpTheUnitTree(t *testing.T) {
+	f := newSLAFixture(t)
+	request := f.env.pengadaan(t, f.rina, 500000, "Proyektor")
+
+	if escalated := f.escalate(t); escalated != 0 {
+		t.Errorf("Expected nothing to escalate within the SLA, got %d", escalated)
+	}
+
+	f.overdue(t, request)
+	if escalated := f.escalate(t); escalated != 1 {
+		t.Fatalf("Expected the overdue request to escalate, got %d", escalated)
+	}
+	first := f.env.reload(t, request)
+	if first.EscalationLevel != 1 || first.EscalatedToID == nil || *first.EscalatedToID != f.kiki.ID.String() || first.EscalatedAt == nil {
+		t.Errorf("Expected an escalation to the head of Keuangan, got level %d to %v", first.EscalationLevel, first.EscalatedToID)
+	}
+
+	// The SLA runs again from the escalation, so the next run leaves the request alone
+	if escalated := f.escalate(t); escalated != 0 {
+		t.Errorf("Expected no second escalation right after the first, got %d", escalated)
+	}
+
+	f.overdue(t, request)
+	if escalated := f.escalate(t); escalated != 1 {
+		t.Fatalf("Expected the request to escalate again once overdue, got %d", escalated)
+	}
+	second := f.env.reload(t, request)
+	if second.EscalationLevel != 2 || second.EscalatedToID == nil || *second.EscalatedToID != f.dina.ID.String() {
+		t.Errorf("Expected an escalation to the head of the Direktorat, got level %d to %v", second.EscalationLevel, second.EscalatedToID)
+	}
+
+	// Nobody is above the Direktorat
+	f.overdue(t, request)
+	if escalated := f.escalate(t); escalated != 0 {
+		t.Errorf("Expected the top of the unit tree to end the escalations, got %d", escalated)
+	}
+
+	history, err := f.env.service.GetRequestHistory(fmt.Sprint(request.ID), actorOf(f.rina))
+	if err != nil {
+		t.Fatalf("Failed to load the history: %v", err)
+	}
+	last := history[len(history)-1]
+	if last.NewStatus != models.StatusDiajukan || last.ChangedBy != nil || last.Catatan == nil || !strings.Contains(*last.Catatan, "escalated to Dina (level 2)") {
+		t.Errorf("Expected the history to record the escalation, got %+v", last)
+	}
+}
+
+func TestEscalationReassignsTheOpenStep(t *testing.T) {
+	f := newSLAFixture(t)
+	_, err := f.env.service.CreateApprovalChain(&models.ApprovalChainRequest{
+		Name:         "Pengadaan",
+		JenisRequest: models.JenisPengadaan,
+		Steps:        []models.ApprovalStepRequest{{Name: "Kepala unit", ApproverType: models.ApproverUnitHead}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create the chain: %v", err)
+	}
+	request := f.env.pengadaan(t, f.rina, 500000, "Proyektor")
+
+	f.overdue(t, request)
+	if escalated := f.escalate(t); escalated != 1 {
+		t.Fatalf("Expected the overdue request to escalate, got %d", escalated)
+	}
+
+	// kiki already holds the step, so it skips to the head above
+	escalated := f.env.reload(t, request)
+	step := escalated.Approvals[0]
+	if escalated.EscalationLevel != 2 || step.ApproverUserID == nil || *step.ApproverUserID != f.dina.ID || step.ApproverType != models.ApproverUser {
+		t.Errorf("Expected the step to move to the head of the Direktorat, got level %d and %+v", escalated.EscalationLevel, step)
+	}
+
+	decided, err := f.env.service.DecideApproval(fmt.Sprint(request.ID), &models.ApprovalDecisionRequest{Decision: models.StatusDisetujui}, actorOf(f.dina))
+	if err != nil {
+		t.Fatalf("Expected the head the step escalated to to decide it, got %v", err)
+	}
+	if decided.StatusRequest != models.StatusDisetujui || decided.EscalationLevel != 0 || decided.EscalatedToID != nil {
+		t.Errorf("Expected the decision to approve the request and restart its clock, got %s at level %d", decided.StatusRequest, decided.EscalationLevel)
+	}
+}
+
+func TestStatusChangeRestartsTheSLAClock(t *testing.T) {
+	f := newSLAFixture(t)
+	if _, err := f.env.service.SaveSLATarget(&models.SLATargetRequest{JenisRequest: models.JenisPengadaan, Status: models.StatusDisetujui, WorkingDays: 2}); err != nil {
+		t.Fatalf("Failed to save the SLA target: %v", err)
+	}
+	request := f.env.pengadaan(t, f.rina, 500000, "Proyektor")
+
+	f.overdue(t, request)
+	f.escalate(t)
+	escalated := f.env.reload(t, request)
+
+	err := f.env.service.UpdateRequestStatus(fmt.Sprint(request.ID), &models.UpdateRequestRequest{StatusRequest: models.StatusDisetujui}, actorOf(f.oki))
+	if err != nil {
+		t.Fatalf("Failed to approve: %v", err)
+	}
+
+	approved := f.env.reload(t, request)
+	if !approved.StatusSince.After(escalated.StatusSince) || approved.SLABreached {
+		t.Errorf("Expected the clock to restart in the new status, got since %v breached %v", approved.StatusSince, approved.SLABreached)
+	}
+	if approved.EscalationLevel != 0 || approved.EscalatedAt != nil || approved.EscalatedToID != nil {
+		t.Errorf("Expected the escalation to be cleared, got level %d to %v", approved.EscalationLevel, approved.EscalatedToID)
+	}
+}
+
+func TestEscalateRequestRefusesStaleEscalations(t *testing.T) {
+	f := newSLAFixture(t)
+	request := f.env.pengadaan(t, f.rina, 500000, "Proyektor")
+
+	f.overdue(t, request)
+	f.escalate(t)
+
+	// Two runs that both saw level 0, or a run that saw the request before it changed status
+	for name, escalation := range map[string]*repository.Escalation{
+		"stale level":  {RequestID: request.ID, Status: models.StatusDiajukan, FromLevel: 0, ToLevel: 1, TargetID: f.dina.ID.String()},
+		"stale status": {RequestID: request.ID, Status: models.StatusDisetujui, FromLevel: 1, ToLevel: 2, TargetID: f.dina.ID.String()},
+	} {
+		if err := f.env.repo.EscalateRequest(escalation); !errors.Is(err, repository.ErrStatusChanged) {
+			t.Errorf("%s: expected the escalation to be refused, got %v", name, err)
+		}
+	}
+
+	current := f.env.reload(t, request)
+	if current.EscalationLevel != 1 || current.EscalatedToID == nil || *current.EscalatedToID != f.kiki.ID.String() {
+		t.Errorf("Expected the first escalation to stand, got level %d to %v", current.EscalationLevel, current.EscalatedToID)
+	}
+}
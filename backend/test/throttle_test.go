@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"web-work-request-backend/throttle"
+)
+
+// fakeClock lets tests move time forward without sleeping
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(clock *fakeClock) *throttle.Limiter {
+	policy := throttle.Policy{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        8 * time.Second,
+		LockoutAfter:    5,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	return throttle.NewLimiter(throttle.NewMemoryStore(), policy).WithClock(clock.Now)
+}
+
+func TestLimiterExponentialBackoff(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)}
+	limiter := newTestLimiter(clock)
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Check("user:budi"); err != nil {
+			t.Fatalf("Expected free attempt %d to be allowed, got %v", i+1, err)
+		}
+		limiter.RecordFailure("user:budi")
+	}
+
+	// The third failure starts the backoff: 1s, then 2s after the fourth
+	limiter.RecordFailure("user:budi")
+	var blocked *throttle.BlockedError
+	if err := limiter.Check("user:budi"); !errors.As(err, &blocked) || blocked.RetryAfter != time.Second {
+		t.Fatalf("Expected a 1s backoff, got %v", err)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if err := limiter.Check("user:budi"); err != nil {
+		t.Fatalf("Expected attempt after the backoff to be allowed, got %v", err)
+	}
+
+	limiter.RecordFailure("user:budi")
+	if err := limiter.Check("user:budi"); !errors.As(err, &blocked) || blocked.RetryAfter != 2*time.Second {
+		t.Fatalf("Expected the backoff to double to 2s, got %v", err)
+	}
+
+	// Other keys are not affected
+	if err := limiter.Check("user:siti"); err != nil {
+		t.Errorf("Expected another user to be allowed, got %v", err)
+	}
+}
+
+func TestLimiterLockoutAndReset(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)}
+	limiter := newTestLimiter(clock)
+
+	for i := 0; i < 5; i++ {
+		limiter.RecordFailure("user:budi")
+	}
+
+	var blocked *throttle.BlockedError
+	if err := limiter.Check("user:budi"); !errors.As(err, &blocked) || !blocked.Locked {
+		t.Fatalf("Expected the account to be locked, got %v", err)
+	}
+
+	// Backoff alone has long passed, but the lockout still applies
+	clock.now = clock.now.Add(10 * time.Minute)
+	if err := limiter.Check("user:budi"); !errors.As(err, &blocked) || !blocked.Locked {
+		t.Fatalf("Expected the account to stay locked, got %v", err)
+	}
+
+	// An operator unlock clears the lockout immediately
+	limiter.Reset("user:budi")
+	if err := limiter.Check("user:budi"); err != nil {
+		t.Errorf("Expected unlocked account to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"web-work-request-backend/config"
+	"web-work-request-backend/utils"
+)
+
+func TestAccessTokenLifetime(t *testing.T) {
+	cfg := &config.Config{JWTExpiry: "10m"}
+	if cfg.AccessTokenTTL() != 10*time.Minute {
+		t.Errorf("Expected access token TTL of 10m, got %v", cfg.AccessTokenTTL())
+	}
+
+	cfg.JWTExpiry = "not-a-duration"
+	if cfg.AccessTokenTTL() != 15*time.Minute {
+		t.Errorf("Expected invalid JWTExpiry to fall back to 15m, got %v", cfg.AccessTokenTTL())
+	}
+}
+
+func TestParseAccessTokenCarriesVersion(t *testing.T) {
+	keys, err := utils.NewKeyManager(&config.Config{JWTSecret: "test-secret", JWTKeyID: "default", JWTExpiry: "15m"})
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	token, err := keys.GenerateJWT("user-1", "budi", "operator", 3)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	claims, err := keys.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+
+	if claims.UserID != "user-1" || claims.Role != "operator" || claims.TokenVersion != 3 {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if len(keys.JWKS().Keys) != 0 {
+		t.Error("HMAC secrets must not be published in the JWKS")
+	}
+}
+
+// writeEd25519Key stores a new Ed25519 private key as <kid>.pem in dir
+func writeEd25519Key(t *testing.T, dir, kid string) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "2026-01")
+
+	oldKeys, err := utils.NewKeyManager(&config.Config{JWTKeysDir: dir, JWTKeyID: "2026-01"})
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	token, err := oldKeys.GenerateJWT("user-1", "budi", "user", 0)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// Rotate: add a new key and make it the signing key
+	writeEd25519Key(t, dir, "2026-02")
+	newKeys, err := utils.NewKeyManager(&config.Config{JWTKeysDir: dir, JWTKeyID: "2026-02"})
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	if _, err := newKeys.ParseAccessToken(token); err != nil {
+		t.Errorf("Expected token signed with the previous key to stay valid, got %v", err)
+	}
+
+	jwks := newKeys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected both public keys in the JWKS, got %d", len(jwks.Keys))
+	}
+	for _, key := range jwks.Keys {
+		if key.KeyType != "OKP" || key.Algorithm != "EdDSA" || key.X == "" {
+			t.Errorf("Unexpected JWK: %+v", key)
+		}
+	}
+
+	// Once the old key is removed its tokens are rejected
+	os.Remove(filepath.Join(dir, "2026-01.pem"))
+	rotatedKeys, err := utils.NewKeyManager(&config.Config{JWTKeysDir: dir, JWTKeyID: "2026-02"})
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+	if _, err := rotatedKeys.ParseAccessToken(token); err == nil {
+		t.Error("Expected token signed with a removed key to be rejected")
+	}
+}
+
+func TestKeyManagerFromEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "2026-03")
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_KEY_ID", "2026-03")
+
+	keys, err := utils.NewKeyManager(config.Load())
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "2026-03" || jwks.Keys[0].Algorithm != "EdDSA" {
+		t.Errorf("Expected JWT_KEYS_DIR and JWT_KEY_ID to select the Ed25519 key, got %+v", jwks.Keys)
+	}
+}
+
+func TestOpaqueTokenHash(t *testing.T) {
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	if hash == token {
+		t.Error("Tokens must not be stored in plain text")
+	}
+	if utils.HashOpaqueToken(token) != hash {
+		t.Error("Expected hashing the token again to give the stored hash")
+	}
+
+	other, _, _ := utils.GenerateOpaqueToken()
+	if other == token {
+		t.Error("Expected tokens to be unique")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"web-work-request-backend/config"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+	"web-work-request-backend/utils"
+)
+
+// rfc6238Secret is the SHA1 test key from RFC 6238, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; the last 6 digits are the 6 digit codes
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, want := range vectors {
+		got, err := utils.TOTPCode(rfc6238Secret, utils.TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("Failed to compute code: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected code %s at %d, got %s", want, unix, got)
+		}
+	}
+}
+
+func TestValidateTOTPAllowsClockSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := utils.TOTPStep(now)
+
+	previous, _ := utils.TOTPCode(rfc6238Secret, step-1)
+	if matched, ok := utils.ValidateTOTP(rfc6238Secret, previous, now); !ok || matched != step-1 {
+		t.Errorf("Expected the previous period's code to match step %d, got %d (%v)", step-1, matched, ok)
+	}
+
+	stale, _ := utils.TOTPCode(rfc6238Secret, step-2)
+	if _, ok := utils.ValidateTOTP(rfc6238Secret, stale, now); ok {
+		t.Error("Expected a code two periods old to be rejected")
+	}
+
+	if _, ok := utils.ValidateTOTP(rfc6238Secret, "12345", now); ok {
+		t.Error("Expected a code of the wrong length to be rejected")
+	}
+}
+
+func TestRecoveryCodesNormalize(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("Failed to generate recovery codes: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Unexpected recovery code format: %q", code)
+		}
+		seen[code] = true
+
+		typed := " " + strings.ToUpper(strings.ReplaceAll(code, "-", "")) + " "
+		if utils.NormalizeRecoveryCode(typed) != utils.NormalizeRecoveryCode(code) {
+			t.Errorf("Expected %q to normalize like %q", typed, code)
+		}
+	}
+	if len(seen) != len(codes) {
+		t.Error("Expected recovery codes to be unique")
+	}
+}
+
+func TestChallengeTokenDoesNotGrantAccess(t *testing.T) {
+	keys, err := utils.NewKeyManager(&config.Config{JWTSecret: "test-secret", JWTKeyID: "default", JWTExpiry: "15m"})
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	challenge, err := keys.GenerateChallengeToken("user-1", utils.TokenTypeTwoFactor, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate challenge token: %v", err)
+	}
+
+	if _, err := keys.ParseAccessToken(challenge); err == nil {
+		t.Error("Expected a challenge token to be rejected as an access token")
+	}
+
+	claims, err := keys.ParseChallengeToken(challenge, utils.TokenTypeTwoFactor)
+	if err != nil || claims.UserID != "user-1" {
+		t.Fatalf("Expected challenge token for user-1, got %+v (%v)", claims, err)
+	}
+	if claims.ID == "" || !claims.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected the challenge to carry an ID and an expiry, got %+v", claims)
+	}
+
+	second, _ := keys.GenerateChallengeToken("user-1", utils.TokenTypeTwoFactor, 5*time.Minute)
+	if other, err := keys.ParseChallengeToken(second, utils.TokenTypeTwoFactor); err != nil || other.ID == claims.ID {
+		t.Errorf("Expected every challenge to get its own ID, got %v (%v)", other, err)
+	}
+
+	access, _ := keys.GenerateJWT("user-1", "budi", "user", 0)
+	if _, err := keys.ParseChallengeToken(access, utils.TokenTypeTwoFactor); err == nil {
+		t.Error("Expected an access token to be rejected as a challenge token")
+	}
+}
+
+func TestChallengeCompletesOneLogin(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.user(t, "rina", models.RoleUser, env.unit(t, "Keuangan", nil))
+
+	enrollment, err := env.service.StartTwoFactorEnrollment(user.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to start enrollment: %v", err)
+	}
+	code, err := utils.TOTPCode(enrollment.Secret, utils.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatalf("Failed to compute code: %v", err)
+	}
+	recoveryCodes, err := env.service.ConfirmTwoFactorEnrollment(user.ID.String(), code)
+	if err != nil {
+		t.Fatalf("Failed to confirm enrollment: %v", err)
+	}
+
+	response, err := env.service.LoginUser(&models.LoginRequest{Username: "rina", Password: testPassword}, "192.0.2.1")
+	if err != nil || response.ChallengeToken == "" {
+		t.Fatalf("Expected a two-factor challenge, got %+v (%v)", response, err)
+	}
+	verify := func(code string) error {
+		_, err := env.service.VerifyTwoFactor(&models.TwoFactorChallengeRequest{ChallengeToken: response.ChallengeToken, Code: code}, "192.0.2.1")
+		return err
+	}
+
+	// A wrong code leaves the challenge usable
+	if err := verify("000000"); !errors.Is(err, services.ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected a wrong code to be refused, got %v", err)
+	}
+	if err := verify(recoveryCodes[0]); err != nil {
+		t.Fatalf("Failed to complete the login: %v", err)
+	}
+
+	if err := verify(recoveryCodes[1]); !errors.Is(err, services.ErrInvalidChallenge) {
+		t.Errorf("Expected a used challenge to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"web-work-request-backend/directory"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizeUnitName(t *testing.T) {
+	cases := map[string]string{
+		"Keuangan":            "Keuangan",
+		"  Keuangan ":         "Keuangan",
+		"IT   Support":        "IT Support",
+		"\tSarana\nPrasarana": "Sarana Prasarana",
+		"   ":                 "",
+	}
+
+	for input, expected := range cases {
+		if got := services.NormalizeUnitName(input); got != expected {
+			t.Errorf("NormalizeUnitName(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestMergeUnitMovesEverythingOver(t *testing.T) {
+	env := newTestEnv(t)
+	finance, keuangan := env.unit(t, "Finance", nil), env.unit(t, "Keuangan", nil)
+	payroll := env.unit(t, "Payroll", finance)
+	operator := env.user(t, "oki", models.RoleOperator, keuangan)
+	user := env.user(t, "rina", models.RoleUser, finance)
+	request := env.pengadaan(t, user, 500000, "Proyektor")
+
+	if _, err := env.service.MergeUnit(finance.ID.String(), &models.MergeUnitRequest{Into: keuangan.ID.String()}, actorOf(operator)); err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+
+	if _, err := env.service.GetUnitByID(finance.ID.String()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the merged unit to be deleted, got %v", err)
+	}
+	moved, err := env.repo.GetUserByID(user.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to load the user: %v", err)
+	}
+	if moved.UnitID == nil || *moved.UnitID != keuangan.ID || moved.Unit != "Keuangan" {
+		t.Errorf("Expected the user to move to Keuangan, got %s %v", moved.Unit, moved.UnitID)
+	}
+	if reloaded := env.reload(t, request); reloaded.UnitID == nil || *reloaded.UnitID != keuangan.ID || reloaded.Unit != "Keuangan" {
+		t.Errorf("Expected the request to move to Keuangan, got %s %v", reloaded.Unit, reloaded.UnitID)
+	}
+	child, err := env.service.GetUnitByID(payroll.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to load the child unit: %v", err)
+	}
+	if child.ParentID == nil || *child.ParentID != keuangan.ID {
+		t.Errorf("Expected the child unit to move under Keuangan, got %v", child.ParentID)
+	}
+
+	alias, err := env.repo.FindUnitByName("finance")
+	if err != nil || alias.ID != keuangan.ID {
+		t.Errorf("Expected the merged name to lead to Keuangan, got %v %v", alias, err)
+	}
+}
+
+func TestUnitParentCannotCreateCycle(t *testing.T) {
+	env := newTestEnv(t)
+	operator := env.user(t, "oki", models.RoleOperator, env.unit(t, "Sekretariat", nil))
+	top := env.unit(t, "Direktorat", nil)
+	middle := env.unit(t, "Divisi", top)
+	bottom := env.unit(t, "Bagian", middle)
+
+	for name, parent := range map[string]*models.Unit{"itself": top, "its child": middle, "its grandchild": bottom} {
+		_, err := env.service.UpdateUnit(top.ID.String(), &models.UnitRequest{Name: top.Name, ParentID: parent.ID.String()})
+		if !errors.Is(err, services.ErrUnitCycle) {
+			t.Errorf("Expected placing the unit under %s to fail, got %v", name, err)
+		}
+	}
+
+	_, err := env.service.MergeUnit(top.ID.String(), &models.MergeUnitRequest{Into: bottom.ID.String()}, actorOf(operator))
+	if !errors.Is(err, services.ErrUnitCycle) {
+		t.Errorf("Expected merging a unit into its own grandchild to fail, got %v", err)
+	}
+
+	// Moving a unit sideways is fine
+	other := env.unit(t, "Direktorat Lain", nil)
+	if _, err := env.service.UpdateUnit(middle.ID.String(), &models.UnitRequest{Name: middle.Name, ParentID: other.ID.String()}); err != nil {
+		t.Errorf("Expected a move under an unrelated unit to work, got %v", err)
+	}
+}
+
+// fakeDirectory accepts any password for its entries
+type fakeDirectory map[string]*directory.Entry
+
+func (d fakeDirectory) Authenticate(username, password string) (*directory.Entry, error) {
+	entry, ok := d[username]
+	if !ok {
+		return nil, directory.ErrInvalidCredentials
+	}
+	return entry, nil
+}
+
+func TestExternalLoginResolvesUnits(t *testing.T) {
+	env := newTestEnv(t)
+	keuangan, finance := env.unit(t, "Keuangan", nil), env.unit(t, "Finance", nil)
+	operator := env.user(t, "oki", models.RoleOperator, keuangan)
+	if _, err := env.service.MergeUnit(finance.ID.String(), &models.MergeUnitRequest{Into: keuangan.ID.String()}, actorOf(operator)); err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+
+	roles, err := directory.ParseRoleMapping("", models.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to parse role mapping: %v", err)
+	}
+	siti := &directory.Entry{DN: "uid=siti", Username: "siti", Name: "Siti Rahma", Email: "siti@example.org"}
+	dir := fakeDirectory{"siti": siti}
+	authenticator := services.NewLDAPAuthenticator(dir, env.repo, roles)
+
+	cases := []struct {
+		name         string
+		department   string
+		expectedUnit string
+		expectedID   *uuid.UUID
+	}{
+		{"spelling differs in case and spacing", "  keuangan ", "Keuangan", &keuangan.ID},
+		{"name of a merged unit", "Finance", "Keuangan", &keuangan.ID},
+		{"unknown department keeps the current unit", "Divisi Baru", "Keuangan", &keuangan.ID},
+	}
+
+	for _, tc := range cases {
+		siti.Department = tc.department
+		user, err := authenticator.Authenticate("siti", "directory-password")
+		if err != nil {
+			t.Fatalf("%s: failed to sign in: %v", tc.name, err)
+		}
+		if user.Unit != tc.expectedUnit || user.UnitID == nil || *user.UnitID != *tc.expectedID {
+			t.Errorf("%s: expected %s, got %s %v", tc.name, tc.expectedUnit, user.Unit, user.UnitID)
+		}
+	}
+
+	if _, err := env.service.GetUnitByID(finance.ID.String()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected logins not to bring the merged unit back, got %v", err)
+	}
+
+	// A new user with an unknown department keeps the name until an operator creates the unit
+	dir["budi"] = &directory.Entry{DN: "uid=budi", Username: "budi", Name: "Budi", Email: "budi@example.org", Department: "Divisi Baru"}
+	user, err := authenticator.Authenticate("budi", "directory-password")
+	if err != nil {
+		t.Fatalf("Failed to provision budi: %v", err)
+	}
+	if user.Unit != "Divisi Baru" || user.UnitID != nil {
+		t.Errorf("Expected an unlinked unit name, got %s %v", user.Unit, user.UnitID)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"web-work-request-backend/models"
+	"web-work-request-backend/services"
+)
+
+var operatorPermissions = []string{models.PermissionRequestApprove, models.PermissionRequestProcess}
+
+func TestCheckTransitionAllowed(t *testing.T) {
+	err := services.CheckTransition(models.JenisPengadaan, models.StatusDiajukan, models.StatusDisetujui, operatorPermissions)
+	if err != nil {
+		t.Errorf("Expected DIAJUKAN -> DISETUJUI to be allowed, got %v", err)
+	}
+
+	err = services.CheckTransition(models.JenisPerbaikan, models.StatusDiajukan, models.StatusDiproses, operatorPermissions)
+	if err != nil {
+		t.Errorf("Expected perbaikan DIAJUKAN -> DIPROSES to be allowed, got %v", err)
+	}
+}
+
+func TestCheckTransitionIllegal(t *testing.T) {
+	err := services.CheckTransition(models.JenisPengadaan, models.StatusSelesai, models.StatusDiajukan, operatorPermissions)
+
+	var transitionErr *services.TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("Expected TransitionError, got %v", err)
+	}
+
+	if len(transitionErr.Allowed) != 0 {
+		t.Errorf("Expected no transitions out of SELESAI, got %v", transitionErr.Allowed)
+	}
+
+	err = services.CheckTransition(models.JenisPeminjaman, models.StatusDitolak, models.StatusDiproses, operatorPermissions)
+	if !errors.As(err, &transitionErr) {
+		t.Errorf("Expected DITOLAK -> DIPROSES to be rejected, got %v", err)
+	}
+}
+
+func TestCheckTransitionForbiddenPermission(t *testing.T) {
+	err := services.CheckTransition(models.JenisPengadaan, models.StatusDiajukan, models.StatusDisetujui, []string{models.PermissionRequestCreate})
+
+	var forbiddenErr *services.TransitionForbiddenError
+	if !errors.As(err, &forbiddenErr) {
+		t.Fatalf("Expected TransitionForbiddenError, got %v", err)
+	}
+	if forbiddenErr.Permission != models.PermissionRequestApprove {
+		t.Errorf("Expected request.approve to be required, got %s", forbiddenErr.Permission)
+	}
+
+	// Approving is not enough to move an approved request into processing
+	err = services.CheckTransition(models.JenisPengadaan, models.StatusDisetujui, models.StatusDiproses, []string{models.PermissionRequestApprove})
+	if !errors.As(err, &forbiddenErr) {
+		t.Errorf("Expected DISETUJUI -> DIPROSES to require request.process, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Package throttle slows down and locks out repeated failed login attempts.
+package throttle
+
+import (
+	"fmt"
+	"time"
+	"web-work-request-backend/models"
+)
+
+// Store keeps failure counters. Repository implements it on PostgreSQL so limits are shared by every
+// backend replica; MemoryStore is a single-process implementation for tests.
+type Store interface {
+	// GetLoginAttempt returns nil when no failures are recorded for key
+	GetLoginAttempt(key string) (*models.LoginAttempt, error)
+	// RecordLoginFailure increments the counter for key, restarting it when the last failure is older than window
+	RecordLoginFailure(key string, at time.Time, window time.Duration) (*models.LoginAttempt, error)
+	LockLoginAttempt(key string, until time.Time) error
+	ResetLoginAttempts(key string) error
+}
+
+// Policy describes how failures are throttled
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before backoff starts
+	FreeAttempts int
+	// BaseDelay is the wait after the first throttled failure; it doubles with every further failure
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter locks the key after this many failures; 0 disables lockout
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+// BlockedError is returned when a key must wait before trying again
+type BlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *BlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account is locked, try again in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Limiter applies a Policy to the counters in a Store
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+// WithClock returns a copy of the limiter that reads the time from now; used by tests
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	clone := *l
+	clone.now = now
+	return &clone
+}
+
+// Check returns a *BlockedError when key is locked or still inside its backoff delay
+func (l *Limiter) Check(key string) error {
+	attempt, err := l.store.GetLoginAttempt(key)
+	if err != nil || attempt == nil {
+		return err
+	}
+
+	now := l.now()
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+		return &BlockedError{RetryAfter: attempt.LockedUntil.Sub(now), Locked: true}
+	}
+
+	// Counters that fell out of the window no longer count
+	if now.Sub(attempt.LastFailure) > l.policy.Window {
+		return nil
+	}
+
+	if next := attempt.LastFailure.Add(l.delay(attempt.Failures)); next.After(now) {
+		return &BlockedError{RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt and locks the key once it reaches the lockout threshold
+func (l *Limiter) RecordFailure(key string) error {
+	now := l.now()
+	attempt, err := l.store.RecordLoginFailure(key, now, l.policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if l.policy.LockoutAfter > 0 && attempt.Failures >= l.policy.LockoutAfter {
+		return l.store.LockLoginAttempt(key, now.Add(l.policy.LockoutDuration))
+	}
+	return nil
+}
+
+// Reset forgets every failure for key, lifting any lockout
+func (l *Limiter) Reset(key string) error {
+	return l.store.ResetLoginAttempts(key)
+}
+
+// delay returns how long to wait after the given number of failures
+func (l *Limiter) delay(failures int) time.Duration {
+	over := failures - l.policy.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := l.policy.BaseDelay
+	for i := 1; i < over && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.policy.MaxDelay {
+		delay = l.policy.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,351 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"web-work-request-backend/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key that can verify access tokens and, when it holds a private key, sign them
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	// signKey is the HMAC secret or private key; nil for verification-only keys
+	signKey interface{}
+	// verifyKey is the HMAC secret or public key
+	verifyKey interface{}
+}
+
+// CanSign reports whether the key holds the material needed to sign tokens
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// KeyManager signs access tokens with the active key and verifies them against every loaded key,
+// so keys can be rotated without invalidating tokens that are still in flight
+type KeyManager struct {
+	active    *SigningKey
+	keys      map[string]*SigningKey
+	accessTTL time.Duration
+}
+
+// NewKeyManager loads the signing keys described by the configuration.
+//
+// Without JWTKeysDir, tokens are signed with HS256 using JWTSecret under the JWTKeyID kid.
+// With JWTKeysDir, every <kid>.pem file in the directory is loaded: RSA keys use RS256 and Ed25519 keys
+// use EdDSA. The key named by JWTKeyID must be a private key and signs new tokens; the others only verify.
+func NewKeyManager(cfg *config.Config) (*KeyManager, error) {
+	m := &KeyManager{
+		keys:      make(map[string]*SigningKey),
+		accessTTL: cfg.AccessTokenTTL(),
+	}
+
+	if cfg.JWTKeysDir == "" {
+		key := &SigningKey{
+			ID:        cfg.JWTKeyID,
+			Method:    jwt.SigningMethodHS256,
+			signKey:   []byte(cfg.JWTSecret),
+			verifyKey: []byte(cfg.JWTSecret),
+		}
+		m.add(key)
+		// Tokens issued before kid headers were added have no kid and were signed with the same secret
+		m.keys[""] = key
+	} else {
+		paths, err := filepath.Glob(filepath.Join(cfg.JWTKeysDir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			key, err := LoadSigningKey(path)
+			if err != nil {
+				return nil, err
+			}
+			m.add(key)
+		}
+	}
+
+	active, ok := m.keys[cfg.JWTKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", cfg.JWTKeyID)
+	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", cfg.JWTKeyID)
+	}
+	m.active = active
+
+	return m, nil
+}
+
+func (m *KeyManager) add(key *SigningKey) {
+	m.keys[key.ID] = key
+}
+
+// LoadSigningKey reads a PEM encoded RSA or Ed25519 key; the key ID is the file name without its extension
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	key, err := ParseSigningKey(id, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseSigningKey parses a PEM encoded private or public RSA or Ed25519 key
+func ParseSigningKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if err := checkRSAKeySize(&k.PublicKey); err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		if err := checkRSAKeySize(k); err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// MinRSAKeyBits is the smallest RSA modulus accepted for signing or verifying tokens
+const MinRSAKeyBits = 2048
+
+func checkRSAKeySize(key *rsa.PublicKey) error {
+	if key.N.BitLen() < MinRSAKeyBits {
+		return fmt.Errorf("RSA key is %d bits, at least %d are required", key.N.BitLen(), MinRSAKeyBits)
+	}
+	return nil
+}
+
+// AccessTokenTTL returns the lifetime of the access tokens the manager issues
+func (m *KeyManager) AccessTokenTTL() time.Duration {
+	return m.accessTTL
+}
+
+// GenerateJWT generates a short-lived access token for a user, signed with the active key
+func (m *KeyManager) GenerateJWT(userID, username, role string, tokenVersion int) (string, error) {
+	now := time.Now()
+
+	// Create the Claims
+	claims := jwt.MapClaims{
+		"user_id":  userID,
+		"username": username,
+		"role":     role,
+		"ver":      tokenVersion,
+		"typ":      TokenTypeAccess,
+		"exp":      now.Add(m.accessTTL).Unix(),
+		"iat":      now.Unix(),
+	}
+
+	// Create token
+	token := jwt.NewWithClaims(m.active.Method, claims)
+	token.Header["kid"] = m.active.ID
+
+	// Generate encoded token
+	return token.SignedString(m.active.signKey)
+}
+
+// ValidateJWT validates a JWT token against the key named in its kid header and returns the claims
+func (m *KeyManager) ValidateJWT(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		// The algorithm is fixed by the key, never by the token
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return key.verifyKey, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, jwt.ErrInvalidKey
+}
+
+// ParseAccessToken validates an access token and returns its claims
+func (m *KeyManager) ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims, err := m.ValidateJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Challenge tokens are signed with the same keys but must never grant access
+	if typ, ok := claims["typ"].(string); ok && typ != TokenTypeAccess {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
+	role, _ := claims["role"].(string)
+
+	// Tokens issued before token versions existed carry no "ver" claim and match version 0
+	version, _ := claims["ver"].(float64)
+
+	return &AccessClaims{UserID: userID, Role: role, TokenVersion: int(version)}, nil
+}
+
+// Token types carried in the "typ" claim
+const (
+	TokenTypeAccess = "access"
+	// TokenTypeTwoFactor is issued after a correct password when a second factor is still required
+	TokenTypeTwoFactor = "2fa"
+)
+
+// GenerateChallengeToken issues a short-lived token that only identifies a user for the given purpose
+func (m *KeyManager) GenerateChallengeToken(userID, purpose string, ttl time.Duration) (string, error) {
+	id, err := randomTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"typ":     purpose,
+		"jti":     id,
+		"exp":     now.Add(ttl).Unix(),
+		"iat":     now.Unix(),
+	}
+
+	token := jwt.NewWithClaims(m.active.Method, claims)
+	token.Header["kid"] = m.active.ID
+	return token.SignedString(m.active.signKey)
+}
+
+// ParseChallengeToken validates a challenge token for purpose and returns its claims
+func (m *KeyManager) ParseChallengeToken(tokenString, purpose string) (*ChallengeClaims, error) {
+	claims, err := m.ValidateJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ, _ := claims["typ"].(string); typ != purpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
+	id, _ := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if id == "" || err != nil || expiresAt == nil {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return &ChallengeClaims{UserID: userID, ID: id, ExpiresAt: expiresAt.Time}, nil
+}
+
+// randomTokenID returns a random, URL-safe token ID
+func randomTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// JSONWebKey is a public key in JWK format (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	// RSA keys
+	Modulus  string `json:"n,omitempty"`
+	Exponent string `json:"e,omitempty"`
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public verification keys; HMAC secrets are never published
+func (m *KeyManager) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	for _, key := range m.keys {
+		jwk := JSONWebKey{KeyID: key.ID, Algorithm: key.Method.Alg(), Use: "sig"}
+
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.Modulus = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"time"
-	"web-work-request-backend/config"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,77 +22,36 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateJWT generates a JWT token for a user
-func GenerateJWT(userID, username, role string) (string, error) {
-	cfg := config.Load()
-
-	// Create the Claims
-	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"role":     role,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(), // 24 hours
-		"iat":      time.Now().Unix(),
-	}
-
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Generate encoded token
-	return token.SignedString([]byte(cfg.JWTSecret))
+// AccessClaims holds the claims AuthMiddleware needs from an access token
+type AccessClaims struct {
+	UserID       string
+	Role         string
+	TokenVersion int
 }
 
-// ValidateJWT validates a JWT token and returns the claims
-func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
-	cfg := config.Load()
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(cfg.JWTSecret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
-	}
-
-	return nil, jwt.ErrInvalidKey
+// ChallengeClaims identifies the user a challenge token was issued to. ID is unique per token, so a
+// challenge can be marked as used.
+type ChallengeClaims struct {
+	UserID    string
+	ID        string
+	ExpiresAt time.Time
 }
 
-// ExtractUserIDFromToken extracts user ID from JWT token
-func ExtractUserIDFromToken(tokenString string) (string, error) {
-	claims, err := ValidateJWT(tokenString)
-	if err != nil {
-		return "", err
-	}
-
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return "", jwt.ErrInvalidKey
+// GenerateOpaqueToken returns a random token, such as a refresh or password reset token, and the hash to store for it
+func GenerateOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
 	}
 
-	return userID, nil
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashOpaqueToken(token), nil
 }
 
-// ExtractRoleFromToken extracts role from JWT token
-func ExtractRoleFromToken(tokenString string) (string, error) {
-	claims, err := ValidateJWT(tokenString)
-	if err != nil {
-		return "", err
-	}
-
-	role, ok := claims["role"].(string)
-	if !ok {
-		return "", jwt.ErrInvalidKey
-	}
-
-	return role, nil
+// HashOpaqueToken returns the SHA-256 hex digest under which an opaque token is stored
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // GeneratePasswordHash is a convenience function that generates a password hash
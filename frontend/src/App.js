@@ -4,6 +4,7 @@ import { AuthProvider, useAuth } from './contexts/AuthContext';
 import { NotificationProvider } from './contexts/NotificationContext';
 import { RiwayatProvider } from './contexts/RiwayatContext';
 import Login from './pages/Login';
+import OidcCallback from './pages/OidcCallback';
 import Dashboard from './pages/Dashboard';
 import Profile from './pages/Profile';
 import Pengajuan from './pages/Pengajuan';
@@ -32,6 +33,7 @@ function AppRoutes() {
   return (
     <Routes>
       <Route path="/login" element={<Login />} />
+      <Route path="/login/oidc" element={<OidcCallback />} />
       <Route path="/" element={<Navigate to="/dashboard" replace />} />
       <Route path="/dashboard" element={
         <ProtectedRoute>
@@ -55,7 +57,7 @@ function AppRoutes() {
         </ProtectedRoute>
       } />
       <Route path="/persetujuan" element={
-        <ProtectedRoute allowedRoles={['operator']}>
+        <ProtectedRoute allowedRoles={['operator', 'admin']}>
           <Layout>
             <Persetujuan />
           </Layout>
@@ -69,7 +71,7 @@ function AppRoutes() {
         </ProtectedRoute>
       } />
       <Route path="/pengguna" element={
-        <ProtectedRoute allowedRoles={['operator']}>
+        <ProtectedRoute allowedRoles={['operator', 'admin']}>
           <Layout>
             <Pengguna />
           </Layout>
@@ -25,12 +25,12 @@ const Layout = ({ children }) => {
   };
 
   const navigation = [
-    { name: 'Dashboard', href: '/dashboard', icon: Home, roles: ['user', 'operator'] },
-    { name: 'Profil', href: '/profile', icon: User, roles: ['user', 'operator'] },
-    { name: 'Pengajuan', href: '/pengajuan', icon: FileText, roles: ['user', 'operator'] },
-    { name: 'Persetujuan', href: '/persetujuan', icon: CheckCircle, roles: ['operator'] },
-    { name: 'Riwayat', href: '/riwayat', icon: History, roles: ['user', 'operator'] },
-    { name: 'Pengguna', href: '/pengguna', icon: Users, roles: ['operator'] },
+    { name: 'Dashboard', href: '/dashboard', icon: Home, roles: ['user', 'operator', 'admin'] },
+    { name: 'Profil', href: '/profile', icon: User, roles: ['user', 'operator', 'admin'] },
+    { name: 'Pengajuan', href: '/pengajuan', icon: FileText, roles: ['user', 'operator', 'admin'] },
+    { name: 'Persetujuan', href: '/persetujuan', icon: CheckCircle, roles: ['operator', 'admin'] },
+    { name: 'Riwayat', href: '/riwayat', icon: History, roles: ['user', 'operator', 'admin'] },
+    { name: 'Pengguna', href: '/pengguna', icon: Users, roles: ['operator', 'admin'] },
   ];
 
   const filteredNavigation = navigation.filter(item => 
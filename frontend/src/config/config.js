@@ -1,6 +1,8 @@
 const config = {
   // API_BASE_URL: process.env.REACT_APP_API_URL || 'http://localhost:8080/api', // development
   API_BASE_URL: process.env.REACT_APP_API_URL || 'http://178.128.54.249:8080/api', // production
+  // Shows the single sign-on button; the backend needs OIDC_ISSUER set
+  SSO_ENABLED: process.env.REACT_APP_SSO_ENABLED === 'true',
   APP_NAME: 'Web Work Request',
   VERSION: '1.0.0'
 };
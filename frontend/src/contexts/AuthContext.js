@@ -43,6 +43,7 @@ export const AuthProvider = ({ children }) => {
             if (isMounted) {
               // Token is invalid, clear it
               localStorage.removeItem('token');
+              localStorage.removeItem('refresh_token');
               localStorage.removeItem('user');
               setUser(null);
               setIsAuthenticated(false);
@@ -51,6 +52,7 @@ export const AuthProvider = ({ children }) => {
       } catch (error) {
         // Invalid user data, clear everything
         localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
         localStorage.removeItem('user');
         setUser(null);
         setIsAuthenticated(false);
@@ -73,19 +75,25 @@ export const AuthProvider = ({ children }) => {
       // Handle both response formats (with and without success flag)
       if (response.success && response.token && response.user) {
         // New format with success flag
-        const { user, token } = response;
+        const { user, token, refresh_token } = response;
         setUser(user);
         setIsAuthenticated(true);
         localStorage.setItem('user', JSON.stringify(user));
         localStorage.setItem('token', token);
+        if (refresh_token) {
+          localStorage.setItem('refresh_token', refresh_token);
+        }
         return { success: true };
       } else if (response.token && response.user) {
         // Direct response format (token + user)
-        const { user, token } = response;
+        const { user, token, refresh_token } = response;
         setUser(user);
         setIsAuthenticated(true);
         localStorage.setItem('user', JSON.stringify(user));
         localStorage.setItem('token', token);
+        if (refresh_token) {
+          localStorage.setItem('refresh_token', refresh_token);
+        }
         return { success: true };
       } else {
         return { success: false, message: 'Invalid response format' };
@@ -95,11 +103,42 @@ export const AuthProvider = ({ children }) => {
     }
   }, []);
 
+  // Single sign-on hands back the same token response as a password login
+  const completeOidcLogin = useCallback(async (code, state) => {
+    try {
+      const response = await api.completeOidcLogin(code, state);
+      if (response.two_factor_required) {
+        return { success: false, message: 'Verifikasi dua langkah belum didukung untuk login SSO' };
+      }
+      if (!response.token || !response.user) {
+        return { success: false, message: 'Invalid response format' };
+      }
+
+      const { user, token, refresh_token } = response;
+      setUser(user);
+      setIsAuthenticated(true);
+      localStorage.setItem('user', JSON.stringify(user));
+      localStorage.setItem('token', token);
+      if (refresh_token) {
+        localStorage.setItem('refresh_token', refresh_token);
+      }
+      return { success: true };
+    } catch (error) {
+      return { success: false, message: error.message || 'Login SSO gagal' };
+    }
+  }, []);
+
   const logout = () => {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (refreshToken) {
+      // Revoke the refresh token on the server; the local session is cleared either way
+      api.logout(refreshToken).catch(() => {});
+    }
     setUser(null);
     setIsAuthenticated(false);
     localStorage.removeItem('user');
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
   };
 
   const updateProfile = (updatedData) => {
@@ -113,9 +152,10 @@ export const AuthProvider = ({ children }) => {
     isAuthenticated,
     loading,
     login,
+    completeOidcLogin,
     logout,
     updateProfile
-  }), [user, isAuthenticated, loading, login, updateProfile]);
+  }), [user, isAuthenticated, loading, login, completeOidcLogin, updateProfile]);
 
   return (
     <AuthContext.Provider value={value}>
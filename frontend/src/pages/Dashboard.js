@@ -147,7 +147,7 @@ const Dashboard = () => {
           color="bg-blue-500"
           href="/pengajuan"
         />
-        {user?.role === 'operator' && (
+        {['operator', 'admin'].includes(user?.role) && (
           <StatCard
             title="Total Persetujuan"
             value={stats.persetujuan}
@@ -163,7 +163,7 @@ const Dashboard = () => {
           color="bg-purple-500"
           href="/riwayat"
         />
-        {user?.role === 'operator' && (
+        {['operator', 'admin'].includes(user?.role) && (
           <StatCard
             title="Total Pengguna"
             value={stats.pengguna}
@@ -192,7 +192,7 @@ const Dashboard = () => {
             href="/riwayat"
             color="bg-purple-500"
           />
-          {user?.role === 'operator' && (
+          {['operator', 'admin'].includes(user?.role) && (
             <>
               <QuickActionCard
                 title="Kelola Persetujuan"
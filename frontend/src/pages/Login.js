@@ -1,6 +1,8 @@
 import React, { useState, useEffect } from 'react';
 import { useNavigate } from 'react-router-dom';
 import { useAuth } from '../contexts/AuthContext';
+import api from '../services/api';
+import config from '../config/config';
 import { LogIn, Building2, Users } from 'lucide-react';
 
 const Login = () => {
@@ -115,6 +117,15 @@ const Login = () => {
           </div>
         </form>
 
+        {config.SSO_ENABLED && (
+          <a
+            href={api.oidcLoginUrl()}
+            className="w-full flex justify-center py-2 px-4 border border-gray-300 text-sm font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-primary-500"
+          >
+            Login dengan SSO
+          </a>
+        )}
+
         <div className="mt-6">
           <div className="relative">
             <div className="absolute inset-0 flex items-center">
@@ -0,0 +1,69 @@
+import React, { useState, useEffect, useRef } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import { useAuth } from '../contexts/AuthContext';
+import { Building2 } from 'lucide-react';
+
+// The identity provider sends the browser back here (OIDC_REDIRECT_URL) with a code and state
+const OidcCallback = () => {
+  const [error, setError] = useState('');
+  const [searchParams] = useSearchParams();
+  const { completeOidcLogin } = useAuth();
+  const navigate = useNavigate();
+  // A code can only be exchanged once, so the login is completed a single time per visit
+  const started = useRef(false);
+
+  useEffect(() => {
+    if (started.current) {
+      return;
+    }
+    started.current = true;
+
+    const providerError = searchParams.get('error');
+    if (providerError) {
+      setError(searchParams.get('error_description') || providerError);
+      return;
+    }
+
+    const code = searchParams.get('code');
+    const state = searchParams.get('state');
+    if (!code || !state) {
+      setError('Respons SSO tidak lengkap');
+      return;
+    }
+
+    completeOidcLogin(code, state).then((result) => {
+      if (result.success) {
+        navigate('/dashboard', { replace: true });
+      } else {
+        setError(result.message || 'Login SSO gagal');
+      }
+    });
+  }, [searchParams, completeOidcLogin, navigate]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+      <div className="max-w-md w-full space-y-8 text-center">
+        <div className="mx-auto h-12 w-12 flex items-center justify-center rounded-full bg-primary-100">
+          <Building2 className="h-8 w-8 text-primary-600" />
+        </div>
+        {error ? (
+          <div className="space-y-4">
+            <div className="rounded-md bg-red-50 p-4">
+              <div className="text-sm text-red-700">{error}</div>
+            </div>
+            <Link to="/login" className="text-sm font-medium text-primary-600 hover:text-primary-500">
+              Kembali ke halaman login
+            </Link>
+          </div>
+        ) : (
+          <div className="flex flex-col items-center space-y-3">
+            <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-primary-600"></div>
+            <p className="text-sm text-gray-600">Menyelesaikan login SSO...</p>
+          </div>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default OidcCallback;
@@ -29,12 +29,14 @@ const Pengajuan = () => {
     type_model_array: [''],
     jumlah_array: [1],
     keterangan_array: [''],
+    harga_satuan_array: [''],
     // For perbaikan: array fields (updated to support multiple items)
     nama_barang_perbaikan_array: [''],
     type_model_perbaikan_array: [''],
     jumlah_perbaikan_array: [1],
     jenis_pekerjaan_array: [''],
     lokasi_perbaikan_array: [''],
+    harga_satuan_perbaikan_array: [''],
     // For peminjaman: array fields (updated to support multiple items)
     lokasi_peminjaman_array: [''],
     kegunaan_array: [''],
@@ -75,7 +77,8 @@ const Pengajuan = () => {
       nama_barang_array: [...prev.nama_barang_array, ''],
       type_model_array: [...prev.type_model_array, ''],
       jumlah_array: [...prev.jumlah_array, 1],
-      keterangan_array: [...prev.keterangan_array, '']
+      keterangan_array: [...prev.keterangan_array, ''],
+      harga_satuan_array: [...prev.harga_satuan_array, '']
     }));
   };
 
@@ -87,7 +90,8 @@ const Pengajuan = () => {
         nama_barang_array: prev.nama_barang_array.filter((_, i) => i !== index),
         type_model_array: prev.type_model_array.filter((_, i) => i !== index),
         jumlah_array: prev.jumlah_array.filter((_, i) => i !== index),
-        keterangan_array: prev.keterangan_array.filter((_, i) => i !== index)
+        keterangan_array: prev.keterangan_array.filter((_, i) => i !== index),
+        harga_satuan_array: prev.harga_satuan_array.filter((_, i) => i !== index)
       }));
     }
   };
@@ -107,12 +111,21 @@ const Pengajuan = () => {
         keterangan: formData.keterangan
       };
 
+      // Keep only the rows whose primary field is filled so that parallel arrays stay the same length
+      const keepRows = (primary, ...columns) => {
+        const indexes = primary.map((item, index) => index).filter(index => String(primary[index]).trim() !== '');
+        return [primary, ...columns].map(column => indexes.map(index => column[index]));
+      };
+
       if (formData.jenis_request === 'pengadaan') {
         // For pengadaan: use array fields AND single fields for backward compatibility
-        const filteredNamaBarang = formData.nama_barang_array.filter(item => item.trim() !== '');
-        const filteredTypeModel = formData.type_model_array.filter(item => item.trim() !== '');
-        const filteredJumlah = formData.jumlah_array.filter((item, index) => formData.nama_barang_array[index].trim() !== '');
-        const filteredKeterangan = formData.keterangan_array.filter(item => item.trim() !== '');
+        const [filteredNamaBarang, filteredTypeModel, filteredJumlah, filteredKeterangan, filteredHarga] = keepRows(
+          formData.nama_barang_array,
+          formData.type_model_array,
+          formData.jumlah_array,
+          formData.keterangan_array,
+          formData.harga_satuan_array
+        );
         
         requestData = {
           ...requestData,
@@ -121,6 +134,8 @@ const Pengajuan = () => {
           type_model_array: filteredTypeModel,
           jumlah_array: filteredJumlah,
           keterangan_array: filteredKeterangan,
+          // Unit prices add up to the estimated value that selects the approval chain
+          harga_satuan_array: filteredHarga.map(Number),
           // Single fields for backward compatibility (use first item from arrays)
           nama_barang: filteredNamaBarang[0] || '',
           type_model: filteredTypeModel[0] || '',
@@ -129,33 +144,48 @@ const Pengajuan = () => {
         };
       } else if (formData.jenis_request === 'perbaikan') {
         // For perbaikan: use array fields (new approach)
+        const [namaBarang, typeModel, jumlah, jenisPekerjaan, lokasi, harga] = keepRows(
+          formData.nama_barang_perbaikan_array,
+          formData.type_model_perbaikan_array,
+          formData.jumlah_perbaikan_array,
+          formData.jenis_pekerjaan_array,
+          formData.lokasi_perbaikan_array,
+          formData.harga_satuan_perbaikan_array
+        );
         requestData = {
           ...requestData,
-          nama_barang_array: formData.nama_barang_perbaikan_array.filter(item => item.trim() !== ''),
-          type_model_array: formData.type_model_perbaikan_array.filter(item => item.trim() !== ''),
-          jumlah_array: formData.jumlah_perbaikan_array.filter((item, index) => formData.nama_barang_perbaikan_array[index].trim() !== ''),
-          jenis_pekerjaan_array: formData.jenis_pekerjaan_array.filter(item => item.trim() !== ''),
-          lokasi_array: formData.lokasi_perbaikan_array.filter(item => item.trim() !== ''),
+          nama_barang_perbaikan_array: namaBarang,
+          type_model_perbaikan_array: typeModel,
+          jumlah_perbaikan_array: jumlah,
+          jenis_pekerjaan_array: jenisPekerjaan,
+          lokasi_perbaikan_array: lokasi,
+          harga_satuan_array: harga.map(Number),
           // Legacy fields for backward compatibility
-          nama_barang: formData.nama_barang_perbaikan_array[0] || '',
-          type_model: formData.type_model_perbaikan_array[0] || '',
-          jumlah: formData.jumlah_perbaikan_array[0] || 1,
-          jenis_pekerjaan: formData.jenis_pekerjaan_array[0] || '',
-          lokasi: formData.lokasi_perbaikan_array[0] || ''
+          nama_barang: namaBarang[0] || '',
+          type_model: typeModel[0] || '',
+          jumlah: jumlah[0] || 1,
+          jenis_pekerjaan: jenisPekerjaan[0] || '',
+          lokasi: lokasi[0] || ''
         };
       } else if (formData.jenis_request === 'peminjaman') {
         // For peminjaman: use array fields (new approach)
+        const [lokasi, kegunaan, tglPeminjaman, tglPengembalian] = keepRows(
+          formData.lokasi_peminjaman_array,
+          formData.kegunaan_array,
+          formData.tgl_peminjaman_array,
+          formData.tgl_pengembalian_array
+        );
         requestData = {
           ...requestData,
-          lokasi_array: formData.lokasi_peminjaman_array.filter(item => item.trim() !== ''),
-          kegunaan_array: formData.kegunaan_array.filter(item => item.trim() !== ''),
-          tgl_peminjaman_array: formData.tgl_peminjaman_array.filter(item => item.trim() !== ''),
-          tgl_pengembalian_array: formData.tgl_pengembalian_array.filter(item => item.trim() !== ''),
+          lokasi_peminjaman_array: lokasi,
+          kegunaan_array: kegunaan,
+          tgl_peminjaman_array: tglPeminjaman,
+          tgl_pengembalian_array: tglPengembalian,
           // Legacy fields for backward compatibility
-          lokasi: formData.lokasi_peminjaman_array[0] || '',
-          kegunaan: formData.kegunaan_array[0] || '',
-          tgl_peminjaman: formData.tgl_peminjaman_array[0] || null,
-          tgl_pengembalian: formData.tgl_pengembalian_array[0] || null
+          lokasi: lokasi[0] || '',
+          kegunaan: kegunaan[0] || '',
+          tgl_peminjaman: tglPeminjaman[0] || null,
+          tgl_pengembalian: tglPengembalian[0] || null
         };
       }
 
@@ -170,11 +200,13 @@ const Pengajuan = () => {
           type_model_array: [''],
           jumlah_array: [1],
           keterangan_array: [''],
+          harga_satuan_array: [''],
           nama_barang_perbaikan_array: [''],
           type_model_perbaikan_array: [''],
           jumlah_perbaikan_array: [1],
           jenis_pekerjaan_array: [''],
           lokasi_perbaikan_array: [''],
+          harga_satuan_perbaikan_array: [''],
           lokasi_peminjaman_array: [''],
           kegunaan_array: [''],
           tgl_peminjaman_array: [''],
@@ -384,6 +416,21 @@ const Pengajuan = () => {
                         required
                       />
                     </div>
+
+                    <div>
+                      <label className="block text-sm font-medium text-gray-700">
+                        Harga Satuan (Rp) *
+                      </label>
+                      <input
+                        type="number"
+                        value={formData.harga_satuan_array[index]}
+                        onChange={(e) => handleArrayFieldChange('harga_satuan_array', index, e.target.value)}
+                        min="0"
+                        className="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500"
+                        placeholder="Contoh: 1500000"
+                        required
+                      />
+                    </div>
                     
                     <div>
                       <label className="block text-sm font-medium text-gray-700">
@@ -417,7 +464,8 @@ const Pengajuan = () => {
                     type_model_perbaikan_array: [...prev.type_model_perbaikan_array, ''],
                     jumlah_perbaikan_array: [...prev.jumlah_perbaikan_array, 1],
                     jenis_pekerjaan_array: [...prev.jenis_pekerjaan_array, ''],
-                    lokasi_perbaikan_array: [...prev.lokasi_perbaikan_array, '']
+                    lokasi_perbaikan_array: [...prev.lokasi_perbaikan_array, ''],
+                    harga_satuan_perbaikan_array: [...prev.harga_satuan_perbaikan_array, '']
                   }));
                 }}
                 className="inline-flex items-center px-3 py-2 border border-transparent text-sm font-medium rounded-md text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500"
@@ -442,7 +490,8 @@ const Pengajuan = () => {
                             type_model_perbaikan_array: prev.type_model_perbaikan_array.filter((_, i) => i !== index),
                             jumlah_perbaikan_array: prev.jumlah_perbaikan_array.filter((_, i) => i !== index),
                             jenis_pekerjaan_array: prev.jenis_pekerjaan_array.filter((_, i) => i !== index),
-                            lokasi_perbaikan_array: prev.lokasi_perbaikan_array.filter((_, i) => i !== index)
+                            lokasi_perbaikan_array: prev.lokasi_perbaikan_array.filter((_, i) => i !== index),
+                            harga_satuan_perbaikan_array: prev.harga_satuan_perbaikan_array.filter((_, i) => i !== index)
                           }));
                         }}
                         className="text-red-600 hover:text-red-800"
@@ -493,6 +542,21 @@ const Pengajuan = () => {
                         required
                       />
                     </div>
+
+                    <div>
+                      <label className="block text-sm font-medium text-gray-700">
+                        Harga Satuan (Rp) *
+                      </label>
+                      <input
+                        type="number"
+                        value={formData.harga_satuan_perbaikan_array[index]}
+                        onChange={(e) => handleArrayFieldChange('harga_satuan_perbaikan_array', index, e.target.value)}
+                        min="0"
+                        className="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500"
+                        placeholder="Contoh: 1500000"
+                        required
+                      />
+                    </div>
                     
                     <div>
                       <label className="block text-sm font-medium text-gray-700">
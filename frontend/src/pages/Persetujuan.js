@@ -24,6 +24,7 @@ const Persetujuan = () => {
     showError 
   } = useNotification();
   const [requests, setRequests] = useState([]);
+  const [pendingApprovalIds, setPendingApprovalIds] = useState([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
   const [selectedRequest, setSelectedRequest] = useState(null);
@@ -31,7 +32,7 @@ const Persetujuan = () => {
   const [updating, setUpdating] = useState(false);
 
   useEffect(() => {
-    if (user?.role === 'operator') {
+    if (['operator', 'admin'].includes(user?.role)) {
       loadRequests();
     }
   }, [user]);
@@ -40,9 +41,13 @@ const Persetujuan = () => {
     try {
       setLoading(true);
       setError(null);
-      const response = await api.getAllRequests();
+      const [response, pendingApprovals] = await Promise.all([
+        api.getAllRequests(),
+        api.getPendingApprovals()
+      ]);
       const data = response.data || response;
       setRequests(data);
+      setPendingApprovalIds((pendingApprovals || []).map(r => r.id));
     } catch (error) {
       setError('Gagal memuat data pengajuan');
       console.error('Failed to load requests:', error);
@@ -55,13 +60,12 @@ const Persetujuan = () => {
     try {
       setUpdating(true);
       
-      const updateData = {
-        status_request: newStatus,
-        approved_by: user?.name,
-        keterangan: keterangan
-      };
-
-      await api.updateRequestStatus(requestId, updateData);
+      // Requests with an approval chain are decided one step at a time; the server records who decided
+      if (selectedRequest?.approvals?.length > 0) {
+        await api.decideApproval(requestId, { decision: newStatus, catatan: keterangan });
+      } else {
+        await api.updateRequestStatus(requestId, { status_request: newStatus, keterangan: keterangan });
+      }
       
       // Show appropriate notification based on status
       const request = requests.find(r => r.id === requestId);
@@ -148,11 +152,24 @@ const Persetujuan = () => {
     }
   };
 
-  const handleViewDetail = (request) => {
+  // The list leaves out the approval steps, so the full request is loaded for review
+  const handleViewDetail = async (request) => {
     setSelectedRequest(request);
     setShowDetail(true);
+    try {
+      const detail = await api.getRequestById(request.id);
+      setSelectedRequest(detail);
+    } catch (error) {
+      console.error('Failed to load request detail:', error);
+    }
   };
 
+  const currentApproval = (request) => (request?.approvals || []).find(a => a.decision === 'DIAJUKAN');
+
+  // A request with approval steps can only be decided by the approver of its current step
+  const canDecide = (request) =>
+    !(request?.approvals?.length > 0) || pendingApprovalIds.includes(request.id);
+
   const formatDate = (dateString) => {
     if (!dateString) return '-';
     return new Date(dateString).toLocaleDateString('id-ID');
@@ -165,7 +182,7 @@ const Persetujuan = () => {
   const [showAllRequests, setShowAllRequests] = useState(false);
   const displayRequests = showAllRequests ? requests : pendingRequests;
 
-  if (user?.role !== 'operator') {
+  if (!['operator', 'admin'].includes(user?.role)) {
     return (
       <div className="text-center py-12">
         <div className="mx-auto h-12 w-12 text-gray-400">
@@ -523,12 +540,37 @@ const Persetujuan = () => {
                 <div className="mt-1 text-sm text-gray-900">{selectedRequest.keterangan}</div>
               </div>
 
+              {/* Approval Steps */}
+              {selectedRequest.approvals?.length > 0 && (
+                <div>
+                  <label className="block text-sm font-medium text-gray-500">Tahapan Persetujuan</label>
+                  <ol className="mt-1 space-y-2">
+                    {selectedRequest.approvals.map((approval) => (
+                      <li key={approval.id} className="flex items-center justify-between text-sm">
+                        <span className="text-gray-900">
+                          {approval.step_no}. {approval.name}
+                          {approval.decided_by_name && (
+                            <span className="text-gray-500"> &middot; {approval.decided_by_name}</span>
+                          )}
+                        </span>
+                        {getStatusBadge(approval.decision)}
+                      </li>
+                    ))}
+                  </ol>
+                </div>
+              )}
+
               {/* Approval Actions */}
               <div className="pt-4 border-t border-gray-200">
+                {!canDecide(selectedRequest) && (
+                  <p className="mb-3 text-sm text-gray-500">
+                    Menunggu keputusan tahap {currentApproval(selectedRequest)?.name || 'berikutnya'} oleh penyetuju lain.
+                  </p>
+                )}
                 <div className="flex justify-end space-x-3">
                   <button
                     onClick={() => handleStatusUpdate(selectedRequest.id, 'DITOLAK', 'Ditolak oleh operator')}
-                    disabled={updating}
+                    disabled={updating || !canDecide(selectedRequest)}
                     className="inline-flex items-center px-4 py-2 border border-transparent text-sm font-medium rounded-md text-white bg-red-600 hover:bg-red-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-red-500 disabled:opacity-50"
                   >
                     {updating ? (
@@ -545,7 +587,7 @@ const Persetujuan = () => {
                   </button>
                   <button
                     onClick={() => handleStatusUpdate(selectedRequest.id, 'DISETUJUI', 'Disetujui oleh operator')}
-                    disabled={updating}
+                    disabled={updating || !canDecide(selectedRequest)}
                     className="inline-flex items-center px-4 py-2 border border-transparent text-sm font-medium rounded-md text-white bg-green-600 hover:bg-green-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-green-500 disabled:opacity-50"
                   >
                     {updating ? (
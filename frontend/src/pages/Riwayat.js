@@ -439,10 +439,10 @@ const Riwayat = () => {
                       <div className="text-sm font-medium text-gray-900">
                         {riwayat.jenis_request === 'pengadaan' && riwayat.nama_barang_array?.[0] 
                           ? riwayat.nama_barang_array[0]
-                          : riwayat.jenis_request === 'perbaikan' && riwayat.nama_barang_array?.[0]
-                          ? riwayat.nama_barang_array[0]
-                          : riwayat.jenis_request === 'peminjaman' && riwayat.lokasi_array?.[0]
-                          ? riwayat.lokasi_array[0]
+                          : riwayat.jenis_request === 'perbaikan' && riwayat.nama_barang_perbaikan_array?.[0]
+                          ? riwayat.nama_barang_perbaikan_array[0]
+                          : riwayat.jenis_request === 'peminjaman' && riwayat.lokasi_peminjaman_array?.[0]
+                          ? riwayat.lokasi_peminjaman_array[0]
                           : 'N/A'
                         }
                       </div>
@@ -451,14 +451,14 @@ const Riwayat = () => {
                           +{riwayat.nama_barang_array.length - 1} barang lainnya
                         </div>
                       )}
-                      {riwayat.jenis_request === 'perbaikan' && riwayat.nama_barang_array?.length > 1 && (
+                      {riwayat.jenis_request === 'perbaikan' && riwayat.nama_barang_perbaikan_array?.length > 1 && (
                         <div className="text-xs text-gray-400">
-                          +{riwayat.nama_barang_array.length - 1} barang lainnya
+                          +{riwayat.nama_barang_perbaikan_array.length - 1} barang lainnya
                         </div>
                       )}
-                      {riwayat.jenis_request === 'peminjaman' && riwayat.lokasi_array?.length > 1 && (
+                      {riwayat.jenis_request === 'peminjaman' && riwayat.lokasi_peminjaman_array?.length > 1 && (
                         <div className="text-xs text-gray-400">
-                          +{riwayat.lokasi_array.length - 1} lokasi lainnya
+                          +{riwayat.lokasi_peminjaman_array.length - 1} lokasi lainnya
                         </div>
                       )}
                     </td>
@@ -617,8 +617,8 @@ const Riwayat = () => {
                 {selectedRiwayat.jenis_request === 'perbaikan' && (
                   <div className="space-y-3">
                     {/* Array-based display for new structure */}
-                    {selectedRiwayat.nama_barang_array && selectedRiwayat.nama_barang_array.length > 0 && selectedRiwayat.nama_barang_array[0] && (
-                      selectedRiwayat.nama_barang_array.map((item, itemIndex) => (
+                    {selectedRiwayat.nama_barang_perbaikan_array && selectedRiwayat.nama_barang_perbaikan_array.length > 0 && selectedRiwayat.nama_barang_perbaikan_array[0] && (
+                      selectedRiwayat.nama_barang_perbaikan_array.map((item, itemIndex) => (
                         <div key={itemIndex} className="border border-gray-200 rounded-lg p-4 space-y-2">
                           <div className="grid grid-cols-3 gap-4 text-sm">
                             <div className="flex items-center space-x-2">
@@ -629,7 +629,7 @@ const Riwayat = () => {
                             <div className="flex items-center space-x-2">
                               <Hash className="h-4 w-4 text-gray-400" />
                               <span className="text-gray-500">Jumlah:</span>
-                              <span className="text-gray-900">{selectedRiwayat.jumlah_array?.[itemIndex] || '-'}</span>
+                              <span className="text-gray-900">{selectedRiwayat.jumlah_perbaikan_array?.[itemIndex] || '-'}</span>
                             </div>
                             <div className="flex items-center space-x-2">
                               <Wrench className="h-4 w-4 text-gray-400" />
@@ -637,14 +637,14 @@ const Riwayat = () => {
                               <span className="text-gray-900">{selectedRiwayat.jenis_pekerjaan_array?.[itemIndex] || '-'}</span>
                             </div>
                           </div>
-                          {selectedRiwayat.type_model_array?.[itemIndex] && (
+                          {selectedRiwayat.type_model_perbaikan_array?.[itemIndex] && (
                             <div className="text-sm text-gray-600">
-                              <span className="font-medium">Type/Model:</span> {selectedRiwayat.type_model_array[itemIndex]}
+                              <span className="font-medium">Type/Model:</span> {selectedRiwayat.type_model_perbaikan_array[itemIndex]}
                             </div>
                           )}
-                          {selectedRiwayat.lokasi_array?.[itemIndex] && (
+                          {selectedRiwayat.lokasi_perbaikan_array?.[itemIndex] && (
                             <div className="text-sm text-gray-600">
-                              <span className="font-medium">Lokasi:</span> {selectedRiwayat.lokasi_array[itemIndex]}
+                              <span className="font-medium">Lokasi:</span> {selectedRiwayat.lokasi_perbaikan_array[itemIndex]}
                             </div>
                           )}
                         </div>
@@ -652,7 +652,7 @@ const Riwayat = () => {
                     )}
                     
                     {/* Single field display for backward compatibility */}
-                    {(!selectedRiwayat.nama_barang_array || selectedRiwayat.nama_barang_array.length === 0 || !selectedRiwayat.nama_barang_array[0]) && (
+                    {(!selectedRiwayat.nama_barang_perbaikan_array || selectedRiwayat.nama_barang_perbaikan_array.length === 0 || !selectedRiwayat.nama_barang_perbaikan_array[0]) && (
                       <div className="border border-gray-200 rounded-lg p-4 space-y-2">
                         <div className="grid grid-cols-2 gap-4 text-sm">
                           <div className="flex items-center space-x-2">
@@ -689,8 +689,8 @@ const Riwayat = () => {
                 {selectedRiwayat.jenis_request === 'peminjaman' && (
                   <div className="space-y-3">
                     {/* Array-based display for new structure */}
-                    {selectedRiwayat.lokasi_array && selectedRiwayat.lokasi_array.length > 0 && selectedRiwayat.lokasi_array[0] && (
-                      selectedRiwayat.lokasi_array.map((item, itemIndex) => (
+                    {selectedRiwayat.lokasi_peminjaman_array && selectedRiwayat.lokasi_peminjaman_array.length > 0 && selectedRiwayat.lokasi_peminjaman_array[0] && (
+                      selectedRiwayat.lokasi_peminjaman_array.map((item, itemIndex) => (
                         <div key={itemIndex} className="border border-gray-200 rounded-lg p-4 space-y-2">
                           <div className="grid grid-cols-2 gap-4 text-sm">
                             <div className="flex items-center space-x-2">
@@ -719,7 +719,7 @@ const Riwayat = () => {
                     )}
                     
                     {/* Single field display for backward compatibility */}
-                    {(!selectedRiwayat.lokasi_array || selectedRiwayat.lokasi_array.length === 0 || !selectedRiwayat.lokasi_array[0]) && (
+                    {(!selectedRiwayat.lokasi_peminjaman_array || selectedRiwayat.lokasi_peminjaman_array.length === 0 || !selectedRiwayat.lokasi_peminjaman_array[0]) && (
                       <div className="border border-gray-200 rounded-lg p-4 space-y-2">
                         <div className="grid grid-cols-2 gap-4 text-sm">
                           <div className="flex items-center space-x-2">
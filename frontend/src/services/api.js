@@ -16,8 +16,40 @@ class ApiService {
     };
   }
 
+  // Exchange the stored refresh token for a new token pair; concurrent callers share one refresh
+  async refreshSession() {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) {
+      return false;
+    }
+
+    if (!this.refreshPromise) {
+      this.refreshPromise = fetch(`${this.baseURL}/auth/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken })
+      })
+        .then(async (response) => {
+          if (!response.ok) {
+            localStorage.removeItem('refresh_token');
+            return false;
+          }
+          const data = await response.json();
+          localStorage.setItem('token', data.token);
+          localStorage.setItem('refresh_token', data.refresh_token);
+          return true;
+        })
+        .catch(() => false)
+        .finally(() => {
+          this.refreshPromise = null;
+        });
+    }
+
+    return this.refreshPromise;
+  }
+
   // Generic request method
-  async request(endpoint, options = {}) {
+  async request(endpoint, options = {}, retried = false) {
     const url = `${this.baseURL}${endpoint}`;
     const config = {
       headers: this.getAuthHeaders(),
@@ -29,6 +61,11 @@ class ApiService {
 
     try {
       const response = await fetch(url, config);
+
+      // Access tokens are short-lived: refresh once and retry the request
+      if (response.status === 401 && !retried && !endpoint.startsWith('/auth/') && await this.refreshSession()) {
+        return this.request(endpoint, options, true);
+      }
       
       console.log('Response status:', response.status);
       console.log('Response headers:', response.headers);
@@ -56,6 +93,25 @@ class ApiService {
     });
   }
 
+  // Single sign-on starts with a full page navigation to the identity provider
+  oidcLoginUrl() {
+    return `${this.baseURL}/auth/oidc/login`;
+  }
+
+  // Finish single sign-on with the code and state the provider sent back to the callback page; the
+  // state cookie set when the login started must be sent along
+  async completeOidcLogin(code, state) {
+    const params = new URLSearchParams({ code, state });
+    return this.request(`/auth/oidc/callback?${params.toString()}`, { credentials: 'include' });
+  }
+
+  async logout(refreshToken) {
+    return this.request('/auth/logout', {
+      method: 'POST',
+      body: JSON.stringify({ refresh_token: refreshToken })
+    });
+  }
+
   async register(userData) {
     return this.request('/auth/register', {
       method: 'POST',
@@ -68,8 +124,75 @@ class ApiService {
     return this.request('/users/me');
   }
 
-  async getAllUsers() {
-    return this.request('/users');
+  async changePassword(oldPassword, newPassword) {
+    return this.request('/users/me/password', {
+      method: 'POST',
+      body: JSON.stringify({ old_password: oldPassword, new_password: newPassword })
+    });
+  }
+
+  async createPasswordReset(id) {
+    return this.request(`/users/${id}/password-reset`, {
+      method: 'POST'
+    });
+  }
+
+  async resetPassword(token, newPassword) {
+    return this.request('/auth/password-reset', {
+      method: 'POST',
+      body: JSON.stringify({ token, new_password: newPassword })
+    });
+  }
+
+  async verifyTwoFactor(challengeToken, code) {
+    return this.request('/auth/2fa/verify', {
+      method: 'POST',
+      body: JSON.stringify({ challenge_token: challengeToken, code })
+    });
+  }
+
+  async startChallengeEnrollment(challengeToken) {
+    return this.request('/auth/2fa/enroll', {
+      method: 'POST',
+      body: JSON.stringify({ challenge_token: challengeToken })
+    });
+  }
+
+  async confirmChallengeEnrollment(challengeToken, code) {
+    return this.request('/auth/2fa/enroll/confirm', {
+      method: 'POST',
+      body: JSON.stringify({ challenge_token: challengeToken, code })
+    });
+  }
+
+  async startTwoFactorEnrollment() {
+    return this.request('/users/me/2fa', {
+      method: 'POST'
+    });
+  }
+
+  async confirmTwoFactorEnrollment(code) {
+    return this.request('/users/me/2fa/confirm', {
+      method: 'POST',
+      body: JSON.stringify({ code })
+    });
+  }
+
+  async disableTwoFactor(code) {
+    return this.request('/users/me/2fa', {
+      method: 'DELETE',
+      body: JSON.stringify({ code })
+    });
+  }
+
+  async resetTwoFactor(id) {
+    return this.request(`/users/${id}/2fa`, {
+      method: 'DELETE'
+    });
+  }
+
+  async getAllUsers(includeDeleted = false) {
+    return this.request(includeDeleted ? '/users?include_deleted=true' : '/users');
   }
 
   async getUserById(id) {
@@ -90,12 +213,111 @@ class ApiService {
     });
   }
 
+  async unlockUser(id) {
+    return this.request(`/users/${id}/unlock`, {
+      method: 'POST'
+    });
+  }
+
   async deleteUser(id) {
     return this.request(`/users/${id}`, {
       method: 'DELETE'
     });
   }
 
+  async deactivateUser(id) {
+    return this.request(`/users/${id}/deactivate`, {
+      method: 'POST'
+    });
+  }
+
+  async reactivateUser(id) {
+    return this.request(`/users/${id}/reactivate`, {
+      method: 'POST'
+    });
+  }
+
+  async restoreUser(id) {
+    return this.request(`/users/${id}/restore`, {
+      method: 'POST'
+    });
+  }
+
+  // Self-registrations waiting for an operator
+  async getPendingRegistrations() {
+    return this.request('/operator/registrations');
+  }
+
+  async approveRegistration(id) {
+    return this.request(`/operator/registrations/${id}/approve`, {
+      method: 'POST'
+    });
+  }
+
+  async rejectRegistration(id) {
+    return this.request(`/operator/registrations/${id}/reject`, {
+      method: 'POST'
+    });
+  }
+
+  // Organizational units; any signed-in user can list them, changes need an operator
+  async getUnits() {
+    return this.request('/units');
+  }
+
+  async createUnit(unitData) {
+    return this.request('/operator/units', {
+      method: 'POST',
+      body: JSON.stringify(unitData)
+    });
+  }
+
+  async updateUnit(id, unitData) {
+    return this.request(`/operator/units/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(unitData)
+    });
+  }
+
+  async deleteUnit(id) {
+    return this.request(`/operator/units/${id}`, {
+      method: 'DELETE'
+    });
+  }
+
+  async mergeUnit(id, intoId) {
+    return this.request(`/operator/units/${id}/merge`, {
+      method: 'POST',
+      body: JSON.stringify({ into: intoId })
+    });
+  }
+
+  // Public holidays, skipped when due dates are computed
+  async getHolidays(year) {
+    return this.request(year ? `/operator/holidays?year=${year}` : '/operator/holidays');
+  }
+
+  async saveHoliday(holidayData) {
+    return this.request('/operator/holidays', {
+      method: 'POST',
+      body: JSON.stringify(holidayData)
+    });
+  }
+
+  // The .ics file is sent as the request body, which the backend accepts as well as a form upload
+  async importHolidays(file) {
+    return this.request('/operator/holidays/import', {
+      method: 'POST',
+      body: await file.text()
+    });
+  }
+
+  async deleteHoliday(date) {
+    return this.request(`/operator/holidays/${date}`, {
+      method: 'DELETE'
+    });
+  }
+
   // Request endpoints
   async createRequest(requestData) {
     return this.request('/requests', {
@@ -129,6 +351,78 @@ class ApiService {
     });
   }
 
+  // Multi-level approvals
+  async getPendingApprovals() {
+    return this.request('/requests/pending-approvals');
+  }
+
+  async decideApproval(id, decisionData) {
+    return this.request(`/requests/${id}/approvals`, {
+      method: 'POST',
+      body: JSON.stringify(decisionData)
+    });
+  }
+
+  // SLA targets per request type and status
+  async getSLATargets() {
+    return this.request('/sla-targets');
+  }
+
+  async saveSLATarget(targetData) {
+    return this.request('/sla-targets', {
+      method: 'PUT',
+      body: JSON.stringify(targetData)
+    });
+  }
+
+  async deleteSLATarget(jenisRequest, status) {
+    return this.request(`/sla-targets/${jenisRequest}/${status}`, {
+      method: 'DELETE'
+    });
+  }
+
+  // Substitutes for absent approvers
+  async getDelegations(includeExpired = false) {
+    return this.request(includeExpired ? '/delegations?include_expired=true' : '/delegations');
+  }
+
+  async createDelegation(delegationData) {
+    return this.request('/delegations', {
+      method: 'POST',
+      body: JSON.stringify(delegationData)
+    });
+  }
+
+  async deleteDelegation(id) {
+    return this.request(`/delegations/${id}`, {
+      method: 'DELETE'
+    });
+  }
+
+  async getApprovalChains(jenisRequest = '') {
+    return this.request(jenisRequest ? `/approval-chains?jenis_request=${encodeURIComponent(jenisRequest)}` : '/approval-chains');
+  }
+
+  async createApprovalChain(chainData) {
+    return this.request('/approval-chains', {
+      method: 'POST',
+      body: JSON.stringify(chainData)
+    });
+  }
+
+  async updateApprovalChain(id, chainData) {
+    return this.request(`/approval-chains/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(chainData)
+    });
+  }
+
+  async deleteApprovalChain(id) {
+    return this.request(`/approval-chains/${id}`, {
+      method: 'DELETE'
+    });
+  }
+
   // Health check
   async healthCheck() {
     return this.request('/health');